@@ -1,21 +1,33 @@
 package cli
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/kechako/wasmexec/mod"
-	"github.com/kechako/wasmexec/mod/instruction"
+	"github.com/kechako/wasmexec/mod/binary"
 	"github.com/kechako/wasmexec/mod/text"
+	"github.com/kechako/wasmexec/mod/types"
+	"github.com/kechako/wasmexec/mod/validate"
 	"github.com/kechako/wasmexec/runtime"
 )
 
 type App struct {
-	invoke string
-	input  string
+	invoke  string
+	output  string
+	print   bool
+	timeout time.Duration
+	input   string
+	// arguments of the invoked function
+	args []string
 }
 
 func (app *App) Run(ctx context.Context) error {
@@ -31,16 +43,45 @@ func (app *App) Run(ctx context.Context) error {
 		return err
 	}
 
-	vm := runtime.New(m)
-	results, err := vm.ExecFunc(ctx, app.invoke)
+	if app.output != "" {
+		return app.encode(app.output, m)
+	}
+
+	if app.print {
+		return text.NewEncoder(os.Stdout).Encode(m)
+	}
+
+	if err := validate.Validate(m); err != nil {
+		return fmt.Errorf("invalid module: %w", err)
+	}
+
+	inst, err := runtime.New(m)
+	if err != nil {
+		return withBacktrace(err)
+	}
+	typ, err := inst.FuncType(app.invoke)
+	if err != nil {
+		return err
+	}
+	args, err := parseValues(app.args, typ.Parameters)
 	if err != nil {
 		return err
 	}
 
+	if app.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, app.timeout)
+		defer cancel()
+	}
+
+	results, err := inst.ExecFunc(ctx, app.invoke, args...)
+	if err != nil {
+		return withBacktrace(err)
+	}
+
 	for _, result := range results {
 		fmt.Println(result)
 	}
-	//dumpModule(m)
 
 	return nil
 }
@@ -48,6 +89,9 @@ func (app *App) Run(ctx context.Context) error {
 func (app *App) parseArgs() error {
 	f := flag.NewFlagSet("wasmexec", flag.ContinueOnError)
 	f.StringVar(&app.invoke, "invoke", "main", "the name of the function to run")
+	f.StringVar(&app.output, "o", "", "write the module in the binary format to the file instead of running it")
+	f.BoolVar(&app.print, "print", false, "print the module in the text format instead of running it")
+	f.DurationVar(&app.timeout, "timeout", 0, "abort the function if it runs longer than the duration (e.g. 500ms)")
 
 	if err := f.Parse(os.Args[1:]); err != nil {
 		return err
@@ -59,6 +103,7 @@ func (app *App) parseArgs() error {
 	}
 
 	app.input = args[0]
+	app.args = args[1:]
 
 	return nil
 }
@@ -70,87 +115,121 @@ func (app *App) decode(name string) (*mod.Module, error) {
 	}
 	defer file.Close()
 
-	return text.NewDecoder(file).Decode()
-}
+	r := bufio.NewReader(file)
 
-func dumpModule(m *mod.Module) {
-	if m.ID.IsEmpty() {
-		fmt.Println("Module:")
+	var d mod.Decoder
+	if isBinary(r) {
+		d = binary.NewDecoder(r)
 	} else {
-		fmt.Printf("Module[%s]:\n", m.ID)
+		d = text.NewDecoder(r)
 	}
 
-	fmt.Println("  Functions:")
-	for i, f := range m.Functions {
-		dumpFunction(f, i, "    ")
-	}
-	fmt.Println("  Exports:")
-	for _, e := range m.Exports {
-		if e.Index.IsIndex() {
-			fmt.Printf("    %q: %s %d\n", e.Name, e.Target, e.Index.Index)
-		} else {
-			fmt.Printf("    %q: %s %s\n", e.Name, e.Target, e.Index.ID)
-		}
-	}
+	return d.Decode()
 }
 
-func dumpFunction(f *mod.Function, index int, indent string) {
-	if f.ID.IsEmpty() {
-		fmt.Printf("%sFunc[%d]:\n", indent, index)
-	} else {
-		fmt.Printf("%sFunc[%s]:\n", indent, f.ID)
-	}
-	fmt.Println("      Parameters:")
-	for i, p := range f.Parameters {
-		dumpParameter(p, i, indent+"    ")
+func (app *App) encode(name string, m *mod.Module) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create WASM file: %w", err)
 	}
-	fmt.Println("      Results:")
-	for i, r := range f.Results {
-		dumpResult(r, i, indent+"    ")
+	defer file.Close()
+
+	if err := binary.NewEncoder(file).Encode(m); err != nil {
+		return err
 	}
-	fmt.Println("      Instructions:")
-	for _, i := range f.Instructions {
-		dumpInstruction(i, indent+"    ")
+
+	return file.Close()
+}
+
+// trapError is a trap whose message has the backtrace.
+type trapError struct {
+	trap *runtime.Trap
+}
+
+// withBacktrace returns err with the backtrace if it is a trap.
+func withBacktrace(err error) error {
+	var trap *runtime.Trap
+	if !errors.As(err, &trap) {
+		return err
 	}
+
+	return &trapError{trap: trap}
 }
 
-func dumpParameter(p *mod.Local, index int, indent string) {
-	if p.ID.IsEmpty() {
-		fmt.Printf("%sParam[%d]: %s\n", indent, index, p.Type)
-	} else {
-		fmt.Printf("%sParam[%s]: %s\n", indent, p.ID, p.Type)
+func (e *trapError) Error() string {
+	var b strings.Builder
+	b.WriteString("trap: " + e.trap.Err.Error())
+	for _, f := range e.trap.Backtrace {
+		b.WriteString("\n    at " + f.String())
 	}
+
+	return b.String()
 }
 
-func dumpResult(r *mod.Result, index int, indent string) {
-	fmt.Printf("%sResult: %s\n", indent, r.Type)
+func (e *trapError) Unwrap() error {
+	return e.trap
 }
 
-func dumpInstruction(i instruction.Instruction, indent string) {
-	switch v := i.(type) {
-	case *instruction.I32Instruction:
-		dumpI32Instruction(v, indent)
-	case *instruction.ParametricInstruction:
-		dumpParametricInstruction(v, indent)
-	case *instruction.ControlInstruction:
-		dumpControlInstruction(v, indent)
-	default:
-		fmt.Printf("%s(unknown)\n", indent)
+// parseValues parses the arguments of the function as the parameter types.
+func parseValues(args []string, params []types.Type) ([]any, error) {
+	if len(args) != len(params) {
+		return nil, fmt.Errorf("the function takes %d arguments but got %d", len(params), len(args))
 	}
-}
 
-func dumpI32Instruction(i *instruction.I32Instruction, indent string) {
-	fmt.Printf("%s%s", indent, i.Instruction)
-	for _, v := range i.Values {
-		fmt.Printf(" %d", v)
+	values := make([]any, len(args))
+	for i, arg := range args {
+		v, err := parseValue(arg, params[i])
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		values[i] = v
 	}
-	fmt.Println()
+
+	return values, nil
+}
+
+// parseValue parses s as a value of typ. Integers can be written in the
+// range of either the signed or the unsigned integers.
+func parseValue(s string, typ types.Type) (any, error) {
+	switch typ {
+	case types.I32:
+		v, err := parseInt(s, 32)
+		return int32(v), err
+	case types.I64:
+		return parseInt(s, 64)
+	case types.F32:
+		v, err := strconv.ParseFloat(s, 32)
+		return float32(v), err
+	case types.F64:
+		return strconv.ParseFloat(s, 64)
+	}
+
+	return nil, fmt.Errorf("unsupported parameter type %s", typ)
 }
 
-func dumpParametricInstruction(i *instruction.ParametricInstruction, indent string) {
-	fmt.Printf("%s%s\n", indent, i.Instruction)
+func parseInt(s string, bitSize int) (int64, error) {
+	v, err := strconv.ParseInt(s, 0, bitSize)
+	if err == nil {
+		return v, nil
+	}
+
+	u, uerr := strconv.ParseUint(s, 0, bitSize)
+	if uerr != nil {
+		return 0, err
+	}
+
+	return int64(u), nil
 }
 
-func dumpControlInstruction(i *instruction.ControlInstruction, indent string) {
-	fmt.Printf("%s%s\n", indent, i.Instruction)
+var binaryMagic = []byte{0x00, 0x61, 0x73, 0x6d}
+
+// isBinary reports whether the input starts with the magic header of the
+// binary format.
+func isBinary(r *bufio.Reader) bool {
+	b, err := r.Peek(len(binaryMagic))
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(b, binaryMagic)
 }
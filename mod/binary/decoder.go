@@ -0,0 +1,1186 @@
+package binary
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/kechako/wasmexec/mod"
+	"github.com/kechako/wasmexec/mod/instruction"
+	"github.com/kechako/wasmexec/mod/types"
+)
+
+var (
+	magic   = []byte{0x00, 0x61, 0x73, 0x6d}
+	version = []byte{0x01, 0x00, 0x00, 0x00}
+)
+
+type Decoder struct {
+	r io.Reader
+}
+
+var _ mod.Decoder = (*Decoder)(nil)
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r: r,
+	}
+}
+
+func (d *Decoder) Decode() (*mod.Module, error) {
+	buf, err := io.ReadAll(d.r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wasm: %w", err)
+	}
+
+	p := &moduleParser{
+		r:         newReader(buf),
+		dataCount: -1,
+	}
+	m, err := p.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode wasm: %w", err)
+	}
+
+	return m, nil
+}
+
+type moduleParser struct {
+	r *reader
+	m *mod.Module
+
+	// indices of the types of the functions in the function section
+	funcTypes []int
+	// number of imported functions, which precede the functions of the code
+	// section
+	funcImports int
+
+	// number of data segments in the data count section, or -1 if the
+	// section is absent
+	dataCount int
+}
+
+func (p *moduleParser) Parse() (*mod.Module, error) {
+	p.m = &mod.Module{}
+
+	if err := p.parseHeader(); err != nil {
+		return nil, err
+	}
+
+	var last sectionID
+	for p.r.len() > 0 {
+		b, err := p.r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		id := sectionID(b)
+
+		if id != sectionCustom {
+			if sectionOrder(id) <= sectionOrder(last) {
+				return nil, p.r.errorf("unexpected section %d", id)
+			}
+			last = id
+		}
+
+		size, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		content, err := p.r.readBytes(int(size))
+		if err != nil {
+			return nil, err
+		}
+
+		// read the section with the offset of the whole input for error messages
+		sr := &reader{
+			buf: p.r.buf[:p.r.pos],
+			pos: p.r.pos - len(content),
+		}
+		if err := p.parseSection(id, sr); err != nil {
+			return nil, err
+		}
+		if sr.len() != 0 {
+			return nil, sr.errorf("section size mismatch")
+		}
+	}
+
+	if len(p.funcTypes) != len(p.m.Functions)-p.funcImports {
+		return nil, p.r.errorf("function and code section have inconsistent lengths")
+	}
+	if p.dataCount >= 0 && p.dataCount != len(p.m.Data) {
+		return nil, p.r.errorf("data count and data section have inconsistent lengths")
+	}
+
+	return p.m, nil
+}
+
+// sectionOrder returns the position of the section in a module, which is
+// not the order of the IDs because the data count section precedes the code
+// section.
+func sectionOrder(id sectionID) int {
+	switch id {
+	case sectionCustom:
+		return 0
+	case sectionDataCount:
+		return int(sectionElement) + 1
+	case sectionCode, sectionData:
+		return int(id) + 1
+	}
+
+	return int(id)
+}
+
+func (p *moduleParser) parseHeader() error {
+	b, err := p.r.readBytes(len(magic))
+	if err != nil || !bytes.Equal(b, magic) {
+		return p.r.errorf("magic header not detected")
+	}
+
+	b, err = p.r.readBytes(len(version))
+	if err != nil || !bytes.Equal(b, version) {
+		return p.r.errorf("unknown binary version")
+	}
+
+	return nil
+}
+
+func (p *moduleParser) parseSection(id sectionID, r *reader) error {
+	switch id {
+	case sectionCustom:
+		// custom sections have no semantics, so they are ignored.
+		if _, err := r.readName(); err != nil {
+			return err
+		}
+		r.pos = len(r.buf)
+		return nil
+	case sectionType:
+		return p.parseTypeSection(r)
+	case sectionImport:
+		return p.parseImportSection(r)
+	case sectionFunction:
+		return p.parseFunctionSection(r)
+	case sectionTable:
+		return p.parseTableSection(r)
+	case sectionMemory:
+		return p.parseMemorySection(r)
+	case sectionGlobal:
+		return p.parseGlobalSection(r)
+	case sectionExport:
+		return p.parseExportSection(r)
+	case sectionElement:
+		return p.parseElementSection(r)
+	case sectionCode:
+		return p.parseCodeSection(r)
+	case sectionData:
+		return p.parseDataSection(r)
+	case sectionDataCount:
+		n, err := r.readU32()
+		if err != nil {
+			return err
+		}
+		p.dataCount = int(n)
+		return nil
+	case sectionStart:
+		idx, err := r.readU32()
+		if err != nil {
+			return err
+		}
+		start := types.NewIndex(int(idx))
+		p.m.Start = &start
+		return nil
+	}
+
+	return r.errorf("malformed section id %d", id)
+}
+
+func (p *moduleParser) parseTypeSection(r *reader) error {
+	n, err := r.readU32()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < n; i++ {
+		b, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		if b != funcTypeForm {
+			return r.errorf("malformed function type 0x%02x", b)
+		}
+
+		params, err := parseValueTypes(r)
+		if err != nil {
+			return err
+		}
+		results, err := parseValueTypes(r)
+		if err != nil {
+			return err
+		}
+
+		p.m.Types = append(p.m.Types, &mod.FuncType{
+			Parameters: params,
+			Results:    results,
+		})
+	}
+
+	return nil
+}
+
+func (p *moduleParser) parseImportSection(r *reader) error {
+	n, err := r.readU32()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < n; i++ {
+		module, err := r.readName()
+		if err != nil {
+			return err
+		}
+		name, err := r.readName()
+		if err != nil {
+			return err
+		}
+		imp := &mod.Import{
+			Module: module,
+			Name:   name,
+		}
+
+		b, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		switch b {
+		case importFunction:
+			idx, err := r.readU32()
+			if err != nil {
+				return err
+			}
+			if int(idx) >= len(p.m.Types) {
+				return r.errorf("unknown type %d", idx)
+			}
+			f := newFunction(p.m.Types, int(idx))
+			f.Import = imp
+			p.m.Functions = append(p.m.Functions, f)
+			p.funcImports++
+		case importTable:
+			t, err := parseTableType(r)
+			if err != nil {
+				return err
+			}
+			t.Import = imp
+			p.m.Tables = append(p.m.Tables, t)
+		case importMemory:
+			limits, err := parseLimits(r)
+			if err != nil {
+				return err
+			}
+			p.m.Memories = append(p.m.Memories, &mod.Memory{
+				Import: imp,
+				Limits: limits,
+			})
+		case importGlobal:
+			g, err := parseGlobalType(r)
+			if err != nil {
+				return err
+			}
+			g.Import = imp
+			p.m.Globals = append(p.m.Globals, g)
+		default:
+			return r.errorf("malformed import kind 0x%02x", b)
+		}
+	}
+
+	return nil
+}
+
+func parseValueTypes(r *reader) ([]types.Type, error) {
+	n, err := r.readU32()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []types.Type
+	for i := uint32(0); i < n; i++ {
+		typ, err := parseValueType(r)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, typ)
+	}
+
+	return list, nil
+}
+
+func parseValueType(r *reader) (types.Type, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return types.Unkown, err
+	}
+
+	typ := decodeValueType(b)
+	if typ == types.Unkown {
+		return types.Unkown, r.errorf("malformed value type 0x%02x", b)
+	}
+
+	return typ, nil
+}
+
+func (p *moduleParser) parseFunctionSection(r *reader) error {
+	n, err := r.readU32()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < n; i++ {
+		idx, err := r.readU32()
+		if err != nil {
+			return err
+		}
+		if int(idx) >= len(p.m.Types) {
+			return r.errorf("unknown type %d", idx)
+		}
+
+		p.funcTypes = append(p.funcTypes, int(idx))
+	}
+
+	return nil
+}
+
+func (p *moduleParser) parseTableSection(r *reader) error {
+	n, err := r.readU32()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < n; i++ {
+		t, err := parseTableType(r)
+		if err != nil {
+			return err
+		}
+
+		p.m.Tables = append(p.m.Tables, t)
+	}
+
+	return nil
+}
+
+func parseTableType(r *reader) (*mod.Table, error) {
+	typ, err := parseRefType(r)
+	if err != nil {
+		return nil, err
+	}
+	limits, err := parseLimits(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mod.Table{
+		Limits: limits,
+		Type:   typ,
+	}, nil
+}
+
+func parseRefType(r *reader) (types.Type, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return types.Unkown, err
+	}
+
+	typ := decodeValueType(b)
+	if !typ.IsReference() {
+		return types.Unkown, r.errorf("malformed reference type 0x%02x", b)
+	}
+
+	return typ, nil
+}
+
+func (p *moduleParser) parseMemorySection(r *reader) error {
+	n, err := r.readU32()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < n; i++ {
+		limits, err := parseLimits(r)
+		if err != nil {
+			return err
+		}
+
+		p.m.Memories = append(p.m.Memories, &mod.Memory{
+			Limits: limits,
+		})
+	}
+
+	return nil
+}
+
+func (p *moduleParser) parseGlobalSection(r *reader) error {
+	n, err := r.readU32()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < n; i++ {
+		g, err := parseGlobalType(r)
+		if err != nil {
+			return err
+		}
+		g.Init, err = p.parseConstExpr(r)
+		if err != nil {
+			return err
+		}
+
+		p.m.Globals = append(p.m.Globals, g)
+	}
+
+	return nil
+}
+
+func parseGlobalType(r *reader) (*mod.Global, error) {
+	typ, err := parseValueType(r)
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if b != globalConst && b != globalVar {
+		return nil, r.errorf("malformed mutability 0x%02x", b)
+	}
+
+	return &mod.Global{
+		Type:    typ,
+		Mutable: b == globalVar,
+	}, nil
+}
+
+func parseLimits(r *reader) (mod.Limits, error) {
+	var limits mod.Limits
+
+	b, err := r.readByte()
+	if err != nil {
+		return limits, err
+	}
+	if b != limitsMin && b != limitsMinMax {
+		return limits, r.errorf("malformed limits flag 0x%02x", b)
+	}
+
+	limits.Min, err = r.readU32()
+	if err != nil {
+		return limits, err
+	}
+	if b == limitsMinMax {
+		limits.Max, err = r.readU32()
+		if err != nil {
+			return limits, err
+		}
+		limits.HasMax = true
+	}
+
+	return limits, nil
+}
+
+func (p *moduleParser) parseExportSection(r *reader) error {
+	n, err := r.readU32()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < n; i++ {
+		name, err := r.readName()
+		if err != nil {
+			return err
+		}
+
+		b, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		var target mod.ExportTarget
+		switch b {
+		case exportFunction:
+			target = mod.ExportFunction
+		case exportTable:
+			target = mod.ExportTable
+		case exportMemory:
+			target = mod.ExportMemory
+		case exportGlobal:
+			target = mod.ExportGlobal
+		default:
+			return r.errorf("malformed export kind 0x%02x", b)
+		}
+
+		idx, err := r.readU32()
+		if err != nil {
+			return err
+		}
+
+		p.m.Exports = append(p.m.Exports, &mod.Export{
+			Name:   name,
+			Target: target,
+			Index:  types.NewIndex(int(idx)),
+		})
+	}
+
+	return nil
+}
+
+func (p *moduleParser) parseCodeSection(r *reader) error {
+	n, err := r.readU32()
+	if err != nil {
+		return err
+	}
+	if int(n) != len(p.funcTypes) {
+		return r.errorf("function and code section have inconsistent lengths")
+	}
+
+	for i := uint32(0); i < n; i++ {
+		size, err := r.readU32()
+		if err != nil {
+			return err
+		}
+		end := r.pos + int(size)
+		if end > len(r.buf) {
+			return r.errorf("unexpected end")
+		}
+
+		fp := &functionParser{
+			r:         &reader{buf: r.buf[:end], pos: r.pos},
+			types:     p.m.Types,
+			dataCount: p.dataCount,
+		}
+		f, err := fp.Parse(p.funcTypes[i])
+		if err != nil {
+			return err
+		}
+		if fp.r.len() != 0 {
+			return fp.r.errorf("section size mismatch")
+		}
+		r.pos = end
+
+		p.m.Functions = append(p.m.Functions, f)
+	}
+
+	return nil
+}
+
+func (p *moduleParser) parseElementSection(r *reader) error {
+	n, err := r.readU32()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < n; i++ {
+		flag, err := r.readU32()
+		if err != nil {
+			return err
+		}
+		if flag > elemPassive|elemTableIndex|elemExprs {
+			return r.errorf("malformed element segment flag %d", flag)
+		}
+
+		e := &mod.Element{}
+		switch {
+		case flag&elemPassive == 0:
+			e.Mode = mod.ElementActive
+			if flag&elemTableIndex != 0 {
+				idx, err := r.readU32()
+				if err != nil {
+					return err
+				}
+				e.Table = types.NewIndex(int(idx))
+			}
+			e.Offset, err = p.parseConstExpr(r)
+			if err != nil {
+				return err
+			}
+		case flag&elemTableIndex == 0:
+			e.Mode = mod.ElementPassive
+		default:
+			e.Mode = mod.ElementDeclarative
+		}
+
+		// the type is implicitly funcref for the active segments without
+		// the table index
+		e.Type = types.FuncRef
+		if flag&(elemPassive|elemTableIndex) != 0 {
+			if flag&elemExprs != 0 {
+				e.Type, err = parseRefType(r)
+				if err != nil {
+					return err
+				}
+			} else {
+				b, err := r.readByte()
+				if err != nil {
+					return err
+				}
+				if b != elemKindFuncRef {
+					return r.errorf("malformed element kind 0x%02x", b)
+				}
+			}
+		}
+
+		count, err := r.readU32()
+		if err != nil {
+			return err
+		}
+		// every element takes at least a byte
+		if count > uint32(r.len()) {
+			return r.errorf("unexpected end")
+		}
+		for j := uint32(0); j < count; j++ {
+			var init []instruction.Instruction
+			if flag&elemExprs != 0 {
+				init, err = p.parseConstExpr(r)
+				if err != nil {
+					return err
+				}
+			} else {
+				idx, err := r.readU32()
+				if err != nil {
+					return err
+				}
+				init = []instruction.Instruction{
+					&instruction.ReferenceInstruction{
+						Instruction: instruction.RefFunc,
+						Function:    types.NewIndex(int(idx)),
+					},
+				}
+			}
+			e.Init = append(e.Init, init)
+		}
+
+		p.m.Elements = append(p.m.Elements, e)
+	}
+
+	return nil
+}
+
+func (p *moduleParser) parseDataSection(r *reader) error {
+	n, err := r.readU32()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < n; i++ {
+		flag, err := r.readU32()
+		if err != nil {
+			return err
+		}
+
+		d := &mod.Data{}
+		switch flag {
+		case dataActive, dataActiveMemory:
+			d.Mode = mod.DataActive
+			if flag == dataActiveMemory {
+				idx, err := r.readU32()
+				if err != nil {
+					return err
+				}
+				d.Memory = types.NewIndex(int(idx))
+			}
+			d.Offset, err = p.parseConstExpr(r)
+			if err != nil {
+				return err
+			}
+		case dataPassive:
+			d.Mode = mod.DataPassive
+		default:
+			return r.errorf("malformed data segment flag %d", flag)
+		}
+
+		size, err := r.readU32()
+		if err != nil {
+			return err
+		}
+		init, err := r.readBytes(int(size))
+		if err != nil {
+			return err
+		}
+		if size > 0 {
+			d.Init = append([]byte{}, init...)
+		}
+
+		p.m.Data = append(p.m.Data, d)
+	}
+
+	return nil
+}
+
+// parseConstExpr parses a constant expression terminated by end.
+func (p *moduleParser) parseConstExpr(r *reader) ([]instruction.Instruction, error) {
+	fp := &functionParser{
+		r:         r,
+		types:     p.m.Types,
+		f:         &mod.Function{},
+		dataCount: p.dataCount,
+	}
+
+	return fp.parseInstructions()
+}
+
+type functionParser struct {
+	r     *reader
+	types []*mod.FuncType
+	f     *mod.Function
+
+	// number of data segments, or -1 if the data count section is absent
+	dataCount int
+}
+
+// newFunction returns a function of the type at typeIdx without the code.
+func newFunction(typs []*mod.FuncType, typeIdx int) *mod.Function {
+	f := &mod.Function{
+		Type: types.NewIndex(typeIdx),
+	}
+
+	typ := typs[typeIdx]
+	for _, t := range typ.Parameters {
+		f.Parameters = append(f.Parameters, &mod.Local{Type: t})
+	}
+	for _, t := range typ.Results {
+		f.Results = append(f.Results, &mod.Result{Type: t})
+	}
+
+	return f
+}
+
+// Parse parses the code of a function of the type at typeIdx.
+func (p *functionParser) Parse(typeIdx int) (*mod.Function, error) {
+	f := newFunction(p.types, typeIdx)
+	p.f = f
+
+	if err := p.parseLocals(); err != nil {
+		return nil, err
+	}
+
+	instructions, err := p.parseInstructions()
+	if err != nil {
+		return nil, err
+	}
+	f.Instructions = instructions
+
+	return f, nil
+}
+
+// maxLocals is the maximum number of the local variables of a function,
+// which keeps a few bytes from declaring billions of locals.
+const maxLocals = 50000
+
+func (p *functionParser) parseLocals() error {
+	n, err := p.r.readU32()
+	if err != nil {
+		return err
+	}
+
+	var total uint64
+	for i := uint32(0); i < n; i++ {
+		count, err := p.r.readU32()
+		if err != nil {
+			return err
+		}
+		// the locals are run-length encoded, so their number is limited
+		// rather than the count of a declaration
+		total += uint64(count)
+		if total > maxLocals {
+			return p.r.errorf("too many locals")
+		}
+
+		typ, err := parseValueType(p.r)
+		if err != nil {
+			return err
+		}
+
+		for j := uint32(0); j < count; j++ {
+			p.f.Locals = append(p.f.Locals, &mod.Local{Type: typ})
+		}
+	}
+
+	return nil
+}
+
+// parseInstructions reads instructions until the end opcode.
+func (p *functionParser) parseInstructions() ([]instruction.Instruction, error) {
+	instructions, _, err := p.parseInstructionsUntil(opEnd)
+	return instructions, err
+}
+
+// parseInstructionsUntil reads instructions until one of the terminator
+// opcodes, and returns the terminator read.
+func (p *functionParser) parseInstructionsUntil(terminators ...byte) ([]instruction.Instruction, byte, error) {
+	var instructions []instruction.Instruction
+	for {
+		op, err := p.r.readByte()
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, t := range terminators {
+			if op == t {
+				return instructions, op, nil
+			}
+		}
+
+		i, err := p.parseInstruction(op)
+		if err != nil {
+			return nil, 0, err
+		}
+		instructions = append(instructions, i)
+	}
+}
+
+func (p *functionParser) parseInstruction(op byte) (instruction.Instruction, error) {
+	iname, ok := instructionNames[op]
+	if op == opPrefix {
+		sub, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		iname, ok = prefixedInstructionNames[sub]
+		if !ok {
+			return nil, p.r.errorf("illegal opcode 0x%02x %d", op, sub)
+		}
+	}
+	if !ok {
+		return nil, p.r.errorf("illegal opcode 0x%02x", op)
+	}
+
+	switch iname {
+	case instruction.I32Const:
+		n, err := p.r.readS32()
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.I32Instruction{
+			Instruction: iname,
+			Values:      []int32{n},
+		}, nil
+	case instruction.I64Const:
+		n, err := p.r.readS64()
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.I64Instruction{
+			Instruction: iname,
+			Values:      []int64{n},
+		}, nil
+	case instruction.F32Const:
+		f, err := p.r.readF32()
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.F32Instruction{
+			Instruction: iname,
+			Values:      []float32{f},
+		}, nil
+	case instruction.F64Const:
+		f, err := p.r.readF64()
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.F64Instruction{
+			Instruction: iname,
+			Values:      []float64{f},
+		}, nil
+	case instruction.LocalGet, instruction.LocalSet, instruction.LocalTee,
+		instruction.GlobalGet, instruction.GlobalSet:
+		idx, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.VariableInstruction{
+			Instruction: iname,
+			Index:       types.NewIndex(int(idx)),
+		}, nil
+	case instruction.Call:
+		idx, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.CallInstruction{
+			Instruction: iname,
+			Index:       types.NewIndex(int(idx)),
+		}, nil
+	case instruction.CallIndirect:
+		idx, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		if int(idx) >= len(p.types) {
+			return nil, p.r.errorf("unknown type %d", idx)
+		}
+		table, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.CallIndirectInstruction{
+			Instruction: iname,
+			Table:       types.NewIndex(int(table)),
+			Type:        types.NewIndex(int(idx)),
+		}, nil
+	case instruction.RefNull:
+		typ, err := parseRefType(p.r)
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.ReferenceInstruction{
+			Instruction: iname,
+			Type:        typ,
+		}, nil
+	case instruction.RefFunc:
+		idx, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.ReferenceInstruction{
+			Instruction: iname,
+			Function:    types.NewIndex(int(idx)),
+		}, nil
+	case instruction.RefIsNull:
+		return &instruction.ReferenceInstruction{
+			Instruction: iname,
+		}, nil
+	case instruction.TableInit:
+		elem, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		table, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.TableInstruction{
+			Instruction: iname,
+			Table:       types.NewIndex(int(table)),
+			Elem:        types.NewIndex(int(elem)),
+		}, nil
+	case instruction.ElemDrop:
+		elem, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.TableInstruction{
+			Instruction: iname,
+			Elem:        types.NewIndex(int(elem)),
+		}, nil
+	case instruction.TableCopy:
+		dst, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		src, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.TableInstruction{
+			Instruction: iname,
+			Table:       types.NewIndex(int(dst)),
+			Source:      types.NewIndex(int(src)),
+		}, nil
+	case instruction.TableGet, instruction.TableSet, instruction.TableSize,
+		instruction.TableGrow, instruction.TableFill:
+		table, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.TableInstruction{
+			Instruction: iname,
+			Table:       types.NewIndex(int(table)),
+		}, nil
+	case instruction.Block, instruction.Loop, instruction.If:
+		return p.parseBlockInstruction(iname)
+	case instruction.Br, instruction.BrIf:
+		label, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.BranchInstruction{
+			Instruction: iname,
+			Labels:      []types.Index{types.NewIndex(int(label))},
+		}, nil
+	case instruction.BrTable:
+		return p.parseBrTable(iname)
+	case instruction.MemoryInit, instruction.DataDrop:
+		// data indices are allowed only with the data count section
+		if p.dataCount < 0 {
+			return nil, p.r.errorf("data count section required")
+		}
+		idx, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		if iname == instruction.MemoryInit {
+			b, err := p.r.readByte()
+			if err != nil {
+				return nil, err
+			}
+			if b != 0x00 {
+				return nil, p.r.errorf("zero byte expected")
+			}
+		}
+		return &instruction.MemoryInstruction{
+			Instruction: iname,
+			Data:        types.NewIndex(int(idx)),
+		}, nil
+	case instruction.MemorySize, instruction.MemoryGrow:
+		// the memory index is reserved to be zero
+		b, err := p.r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != 0x00 {
+			return nil, p.r.errorf("zero byte expected")
+		}
+		return &instruction.MemoryInstruction{
+			Instruction: iname,
+		}, nil
+	}
+
+	if iname.IsMemory() {
+		align, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		offset, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		return &instruction.MemoryInstruction{
+			Instruction: iname,
+			Offset:      offset,
+			Align:       align,
+		}, nil
+	}
+
+	switch {
+	case iname.IsI32():
+		return &instruction.I32Instruction{
+			Instruction: iname,
+		}, nil
+	case iname.IsI64():
+		return &instruction.I64Instruction{
+			Instruction: iname,
+		}, nil
+	case iname.IsF32():
+		return &instruction.F32Instruction{
+			Instruction: iname,
+		}, nil
+	case iname.IsF64():
+		return &instruction.F64Instruction{
+			Instruction: iname,
+		}, nil
+	case iname.IsConversion():
+		return &instruction.ConversionInstruction{
+			Instruction: iname,
+		}, nil
+	case iname.IsParametric():
+		return &instruction.ParametricInstruction{
+			Instruction: iname,
+		}, nil
+	}
+
+	return &instruction.ControlInstruction{
+		Instruction: iname,
+	}, nil
+}
+
+func (p *functionParser) parseBrTable(iname instruction.InstructionName) (instruction.Instruction, error) {
+	n, err := p.r.readU32()
+	if err != nil {
+		return nil, err
+	}
+	// every label takes at least a byte
+	if n >= uint32(p.r.len()) {
+		return nil, p.r.errorf("too many labels")
+	}
+
+	// the labels are followed by the default label
+	labels := make([]types.Index, n+1)
+	for i := range labels {
+		label, err := p.r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		labels[i] = types.NewIndex(int(label))
+	}
+
+	return &instruction.BranchInstruction{
+		Instruction: iname,
+		Labels:      labels,
+	}, nil
+}
+
+func (p *functionParser) parseBlockInstruction(iname instruction.InstructionName) (instruction.Instruction, error) {
+	// the block is added before the nested blocks to be numbered in the
+	// order the block instructions appear
+	block := &mod.Block{}
+	p.f.Blocks = append(p.f.Blocks, block)
+	n := len(p.f.Blocks) - 1
+
+	if err := p.parseBlockType(block); err != nil {
+		return nil, err
+	}
+
+	if iname == instruction.If {
+		instructions, op, err := p.parseInstructionsUntil(opElse, opEnd)
+		if err != nil {
+			return nil, err
+		}
+		block.Instructions = instructions
+
+		if op == opElse {
+			instructions, err := p.parseInstructions()
+			if err != nil {
+				return nil, err
+			}
+			block.Else = instructions
+		}
+	} else {
+		instructions, err := p.parseInstructions()
+		if err != nil {
+			return nil, err
+		}
+		block.Instructions = instructions
+	}
+
+	return &instruction.BlockInstruction{
+		Instruction: iname,
+		Block:       n,
+	}, nil
+}
+
+func (p *functionParser) parseBlockType(block *mod.Block) error {
+	if p.r.len() == 0 {
+		return p.r.errorf("unexpected end")
+	}
+
+	b := p.r.buf[p.r.pos]
+	if b == blockTypeEmpty {
+		p.r.pos++
+		return nil
+	}
+	if typ := decodeValueType(b); typ != types.Unkown {
+		p.r.pos++
+		block.Results = []*mod.Result{{Type: typ}}
+		return nil
+	}
+
+	idx, err := p.r.readS33()
+	if err != nil {
+		return err
+	}
+	if idx < 0 || idx >= int64(len(p.types)) {
+		return p.r.errorf("unknown type %d", idx)
+	}
+
+	typ := p.types[idx]
+	for _, t := range typ.Parameters {
+		block.Parameters = append(block.Parameters, &mod.Local{Type: t})
+	}
+	for _, t := range typ.Results {
+		block.Results = append(block.Results, &mod.Result{Type: t})
+	}
+
+	return nil
+}
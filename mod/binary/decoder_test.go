@@ -0,0 +1,814 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kechako/wasmexec/mod"
+	"github.com/kechako/wasmexec/mod/instruction"
+	"github.com/kechako/wasmexec/mod/types"
+)
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+var header = concat(magic, version)
+
+var tests = map[string]struct {
+	input []byte
+	mod   *mod.Module
+	err   error
+}{
+	"success 01": {
+		input: concat(
+			header,
+			// custom section "name"
+			[]byte{0x00, 0x05, 0x04, 'n', 'a', 'm', 'e'},
+			// type section
+			[]byte{0x01, 0x0b, 0x02,
+				0x60, 0x00, 0x01, 0x7f, // () -> (i32)
+				0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f, // (i32, i32) -> (i32)
+			},
+			// function section
+			[]byte{0x03, 0x03, 0x02, 0x00, 0x01},
+			// export section
+			[]byte{0x07, 0x08, 0x01, 0x04, 'm', 'a', 'i', 'n', 0x00, 0x00},
+			// code section
+			[]byte{0x0a, 0x1f, 0x02,
+				0x08, 0x00,
+				0x41, 0x14, // i32.const 20
+				0x41, 0x0a, // i32.const 10
+				0x10, 0x01, // call 1
+				0x0b, // end
+				0x14, 0x01, 0x02, 0x7f,
+				0x20, 0x00, // local.get 0
+				0x20, 0x01, // local.get 1
+				0x6a,       // i32.add
+				0x21, 0x02, // local.set 2
+				0x02, 0x7f, // block (result i32)
+				0x20, 0x02, // local.get 2
+				0x41, 0x7f, // i32.const -1
+				0x6c, // i32.mul
+				0x0b, // end
+				0x0f, // return
+				0x0b, // end
+			},
+		),
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{Results: []types.Type{types.I32}},
+				{Parameters: []types.Type{types.I32, types.I32}, Results: []types.Type{types.I32}},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Results: []*mod.Result{
+						{Type: types.I32},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{20}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{10}},
+						&instruction.CallInstruction{Instruction: instruction.Call, Index: types.NewIndex(1)},
+					},
+				},
+				{
+					Type: types.NewIndex(1),
+					Parameters: []*mod.Local{
+						{Type: types.I32},
+						{Type: types.I32},
+					},
+					Results: []*mod.Result{
+						{Type: types.I32},
+					},
+					Locals: []*mod.Local{
+						{Type: types.I32},
+						{Type: types.I32},
+					},
+					Blocks: []*mod.Block{
+						{
+							Results: []*mod.Result{
+								{Type: types.I32},
+							},
+							Instructions: []instruction.Instruction{
+								&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(2)},
+								&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{-1}},
+								&instruction.I32Instruction{Instruction: instruction.I32Mul},
+							},
+						},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(1)},
+						&instruction.I32Instruction{Instruction: instruction.I32Add},
+						&instruction.VariableInstruction{Instruction: instruction.LocalSet, Index: types.NewIndex(2)},
+						&instruction.BlockInstruction{Instruction: instruction.Block, Block: 0},
+						&instruction.ControlInstruction{Instruction: instruction.Return},
+					},
+				},
+			},
+			Exports: []*mod.Export{
+				{Name: "main", Target: mod.ExportFunction, Index: types.NewIndex(0)},
+			},
+		},
+		err: nil,
+	},
+	"success 02": {
+		input: concat(
+			header,
+			// type section
+			[]byte{0x01, 0x05, 0x01, 0x60, 0x00, 0x01, 0x7e},
+			// function section
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			// code section
+			[]byte{0x0a, 0x12, 0x01,
+				0x10, 0x00,
+				0x42, 0x7f, // i64.const -1
+				0x42, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x7f, // i64.const -0x8000000000000000
+				0x89, // i64.rotl
+				0x0b, // end
+			},
+		),
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{Results: []types.Type{types.I64}},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Results: []*mod.Result{
+						{Type: types.I64},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.I64Instruction{Instruction: instruction.I64Const, Values: []int64{-1}},
+						&instruction.I64Instruction{Instruction: instruction.I64Const, Values: []int64{math.MinInt64}},
+						&instruction.I64Instruction{Instruction: instruction.I64Rotl},
+					},
+				},
+			},
+		},
+		err: nil,
+	},
+	"success 03": {
+		input: concat(
+			header,
+			// type section
+			[]byte{0x01, 0x05, 0x01, 0x60, 0x00, 0x01, 0x7d},
+			// function section
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			// code section
+			[]byte{0x0a, 0x0f, 0x01,
+				0x0d, 0x00,
+				0x43, 0x00, 0x00, 0xc0, 0x3f, // f32.const 1.5
+				0x43, 0x00, 0x00, 0x00, 0x80, // f32.const -0.0
+				0x98, // f32.copysign
+				0x0b, // end
+			},
+		),
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{Results: []types.Type{types.F32}},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Results: []*mod.Result{
+						{Type: types.F32},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.F32Instruction{Instruction: instruction.F32Const, Values: []float32{1.5}},
+						&instruction.F32Instruction{Instruction: instruction.F32Const, Values: []float32{float32(math.Copysign(0, -1))}},
+						&instruction.F32Instruction{Instruction: instruction.F32Copysign},
+					},
+				},
+			},
+		},
+		err: nil,
+	},
+	"success 04": {
+		input: concat(
+			header,
+			// type section
+			[]byte{0x01, 0x06, 0x01, 0x60, 0x01, 0x7c, 0x01, 0x7e},
+			// function section
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			// code section
+			[]byte{0x0a, 0x0a, 0x01,
+				0x08, 0x00,
+				0x20, 0x00, // local.get 0
+				0xfc, 0x02, // i32.trunc_sat_f64_s
+				0xc0, // i32.extend8_s
+				0xad, // i64.extend_i32_u
+				0x0b, // end
+			},
+		),
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{Parameters: []types.Type{types.F64}, Results: []types.Type{types.I64}},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Parameters: []*mod.Local{
+						{Type: types.F64},
+					},
+					Results: []*mod.Result{
+						{Type: types.I64},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+						&instruction.ConversionInstruction{Instruction: instruction.I32TruncSatF64S},
+						&instruction.ConversionInstruction{Instruction: instruction.I32Extend8S},
+						&instruction.ConversionInstruction{Instruction: instruction.I64ExtendI32U},
+					},
+				},
+			},
+		},
+		err: nil,
+	},
+	"success 05": {
+		input: concat(
+			header,
+			// type section
+			[]byte{0x01, 0x06, 0x01, 0x60, 0x01, 0x7f, 0x01, 0x7f},
+			// function section
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			// code section
+			[]byte{0x0a, 0x15, 0x01,
+				0x13, 0x00,
+				0x03, 0x40, // loop
+				0x20, 0x00, // local.get 0
+				0x0d, 0x00, // br_if 0
+				0x0b,       // end
+				0x20, 0x00, // local.get 0
+				0x04, 0x7f, // if (result i32)
+				0x41, 0x01, // i32.const 1
+				0x05,       // else
+				0x41, 0x02, // i32.const 2
+				0x0b, // end
+				0x0b, // end
+			},
+		),
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{Parameters: []types.Type{types.I32}, Results: []types.Type{types.I32}},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Parameters: []*mod.Local{
+						{Type: types.I32},
+					},
+					Results: []*mod.Result{
+						{Type: types.I32},
+					},
+					Blocks: []*mod.Block{
+						{
+							Instructions: []instruction.Instruction{
+								&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+								&instruction.BranchInstruction{Instruction: instruction.BrIf, Labels: []types.Index{types.NewIndex(0)}},
+							},
+						},
+						{
+							Results: []*mod.Result{
+								{Type: types.I32},
+							},
+							Instructions: []instruction.Instruction{
+								&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+							},
+							Else: []instruction.Instruction{
+								&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{2}},
+							},
+						},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.BlockInstruction{Instruction: instruction.Loop, Block: 0},
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+						&instruction.BlockInstruction{Instruction: instruction.If, Block: 1},
+					},
+				},
+			},
+		},
+		err: nil,
+	},
+	"success 06": {
+		input: concat(
+			header,
+			// type section
+			[]byte{0x01, 0x05, 0x01, 0x60, 0x00, 0x01, 0x7f},
+			// function section
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			// memory section
+			[]byte{0x05, 0x04, 0x01, 0x01, 0x01, 0x02},
+			// export section
+			[]byte{0x07, 0x07, 0x01, 0x03, 'm', 'e', 'm', 0x02, 0x00},
+			// code section
+			[]byte{0x0a, 0x0c, 0x01,
+				0x0a, 0x00,
+				0x41, 0x00, // i32.const 0
+				0x28, 0x02, 0x08, // i32.load offset=8
+				0x3f, 0x00, // memory.size
+				0x6a, // i32.add
+				0x0b, // end
+			},
+		),
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{Results: []types.Type{types.I32}},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Results: []*mod.Result{
+						{Type: types.I32},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.MemoryInstruction{Instruction: instruction.I32Load, Offset: 8, Align: 2},
+						&instruction.MemoryInstruction{Instruction: instruction.MemorySize},
+						&instruction.I32Instruction{Instruction: instruction.I32Add},
+					},
+				},
+			},
+			Memories: []*mod.Memory{
+				{Limits: mod.Limits{Min: 1, Max: 2, HasMax: true}},
+			},
+			Exports: []*mod.Export{
+				{Name: "mem", Target: mod.ExportMemory, Index: types.NewIndex(0)},
+			},
+		},
+		err: nil,
+	},
+	"success 07": {
+		input: concat(
+			header,
+			// type section
+			[]byte{0x01, 0x04, 0x01, 0x60, 0x00, 0x00},
+			// function section
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			// memory section
+			[]byte{0x05, 0x03, 0x01, 0x00, 0x01},
+			// data count section
+			[]byte{0x0c, 0x01, 0x02},
+			// code section
+			[]byte{0x0a, 0x11, 0x01,
+				0x0f, 0x00,
+				0x41, 0x00, // i32.const 0
+				0x41, 0x00, // i32.const 0
+				0x41, 0x01, // i32.const 1
+				0xfc, 0x08, 0x01, 0x00, // memory.init 1
+				0xfc, 0x09, 0x00, // data.drop 0
+				0x0b, // end
+			},
+			// data section
+			[]byte{0x0b, 0x0b, 0x02,
+				0x00, 0x41, 0x08, 0x0b, 0x02, 'h', 'i', // (data (i32.const 8) "hi")
+				0x01, 0x01, 0xff, // (data "\ff")
+			},
+		),
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Instructions: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+						&instruction.MemoryInstruction{Instruction: instruction.MemoryInit, Data: types.NewIndex(1)},
+						&instruction.MemoryInstruction{Instruction: instruction.DataDrop, Data: types.NewIndex(0)},
+					},
+				},
+			},
+			Memories: []*mod.Memory{
+				{Limits: mod.Limits{Min: 1}},
+			},
+			Data: []*mod.Data{
+				{
+					Mode: mod.DataActive,
+					Offset: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{8}},
+					},
+					Init: []byte("hi"),
+				},
+				{
+					Mode: mod.DataPassive,
+					Init: []byte{0xff},
+				},
+			},
+		},
+		err: nil,
+	},
+	"success 08": {
+		input: concat(
+			header,
+			// type section
+			[]byte{0x01, 0x04, 0x01, 0x60, 0x00, 0x00},
+			// function section
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			// global section
+			[]byte{0x06, 0x12, 0x02,
+				0x7f, 0x01, 0x41, 0x7f, 0x0b, // (global (mut i32) (i32.const -1))
+				0x7c, 0x00, 0x44, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xe0, 0x3f, 0x0b, // (global f64 (f64.const 0.5))
+			},
+			// export section
+			[]byte{0x07, 0x05, 0x01, 0x01, 'g', 0x03, 0x00},
+			// code section
+			[]byte{0x0a, 0x08, 0x01,
+				0x06, 0x00,
+				0x23, 0x00, // global.get 0
+				0x24, 0x00, // global.set 0
+				0x0b, // end
+			},
+		),
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Instructions: []instruction.Instruction{
+						&instruction.VariableInstruction{Instruction: instruction.GlobalGet, Index: types.NewIndex(0)},
+						&instruction.VariableInstruction{Instruction: instruction.GlobalSet, Index: types.NewIndex(0)},
+					},
+				},
+			},
+			Globals: []*mod.Global{
+				{
+					Type:    types.I32,
+					Mutable: true,
+					Init: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{-1}},
+					},
+				},
+				{
+					Type: types.F64,
+					Init: []instruction.Instruction{
+						&instruction.F64Instruction{Instruction: instruction.F64Const, Values: []float64{0.5}},
+					},
+				},
+			},
+			Exports: []*mod.Export{
+				{Name: "g", Target: mod.ExportGlobal, Index: types.NewIndex(0)},
+			},
+		},
+		err: nil,
+	},
+	"success 09": {
+		input: concat(
+			header,
+			// type section
+			[]byte{0x01, 0x09, 0x02, 0x60, 0x00, 0x00, 0x60, 0x01, 0x7f, 0x01, 0x7f},
+			// function section
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			// table section
+			[]byte{0x04, 0x05, 0x01, 0x70, 0x01, 0x01, 0x0a},
+			// element section
+			[]byte{0x09, 0x11, 0x03,
+				0x00, 0x41, 0x00, 0x0b, 0x01, 0x00, // (elem (i32.const 0) func 0)
+				0x05, 0x70, 0x01, 0xd0, 0x70, 0x0b, // (elem funcref (ref.null func))
+				0x03, 0x00, 0x01, 0x00, // (elem declare func 0)
+			},
+			// code section
+			[]byte{0x0a, 0x44, 0x01,
+				0x42, 0x00,
+				0x41, 0x00, 0x41, 0x00, // i32.const 0, i32.const 0
+				0x11, 0x01, 0x00, // call_indirect 0 (type 1)
+				0x1a,                               // drop
+				0x41, 0x00, 0xd2, 0x00, 0x26, 0x00, // table.set 0 (i32.const 0) (ref.func 0)
+				0x41, 0x00, 0x25, 0x00, 0xd1, 0x1a, // drop (ref.is_null (table.get 0 (i32.const 0)))
+				0xfc, 0x10, 0x00, 0x1a, // drop (table.size 0)
+				0xd0, 0x70, 0x41, 0x01, 0xfc, 0x0f, 0x00, 0x1a, // drop (table.grow 0 (ref.null func) (i32.const 1))
+				0x41, 0x00, 0xd0, 0x70, 0x41, 0x01, 0xfc, 0x11, 0x00, // table.fill 0 (i32.const 0) (ref.null func) (i32.const 1)
+				0x41, 0x00, 0x41, 0x00, 0x41, 0x00, 0xfc, 0x0e, 0x00, 0x00, // table.copy 0 0 ...
+				0x41, 0x00, 0x41, 0x00, 0x41, 0x01, 0xfc, 0x0c, 0x01, 0x00, // table.init 0 1 ...
+				0xfc, 0x0d, 0x01, // elem.drop 1
+				0x0b, // end
+			},
+		),
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{},
+				{Parameters: []types.Type{types.I32}, Results: []types.Type{types.I32}},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Instructions: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.CallIndirectInstruction{
+							Instruction: instruction.CallIndirect,
+							Table:       types.NewIndex(0),
+							Type:        types.NewIndex(1),
+						},
+						&instruction.ParametricInstruction{Instruction: instruction.Drop},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.ReferenceInstruction{Instruction: instruction.RefFunc, Function: types.NewIndex(0)},
+						&instruction.TableInstruction{Instruction: instruction.TableSet, Table: types.NewIndex(0)},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.TableInstruction{Instruction: instruction.TableGet, Table: types.NewIndex(0)},
+						&instruction.ReferenceInstruction{Instruction: instruction.RefIsNull},
+						&instruction.ParametricInstruction{Instruction: instruction.Drop},
+						&instruction.TableInstruction{Instruction: instruction.TableSize, Table: types.NewIndex(0)},
+						&instruction.ParametricInstruction{Instruction: instruction.Drop},
+						&instruction.ReferenceInstruction{Instruction: instruction.RefNull, Type: types.FuncRef},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+						&instruction.TableInstruction{Instruction: instruction.TableGrow, Table: types.NewIndex(0)},
+						&instruction.ParametricInstruction{Instruction: instruction.Drop},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.ReferenceInstruction{Instruction: instruction.RefNull, Type: types.FuncRef},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+						&instruction.TableInstruction{Instruction: instruction.TableFill, Table: types.NewIndex(0)},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.TableInstruction{Instruction: instruction.TableCopy, Table: types.NewIndex(0), Source: types.NewIndex(0)},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+						&instruction.TableInstruction{Instruction: instruction.TableInit, Table: types.NewIndex(0), Elem: types.NewIndex(1)},
+						&instruction.TableInstruction{Instruction: instruction.ElemDrop, Elem: types.NewIndex(1)},
+					},
+				},
+			},
+			Tables: []*mod.Table{
+				{Limits: mod.Limits{Min: 1, Max: 10, HasMax: true}, Type: types.FuncRef},
+			},
+			Elements: []*mod.Element{
+				{
+					Mode:  mod.ElementActive,
+					Table: types.NewIndex(0),
+					Offset: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+					},
+					Type: types.FuncRef,
+					Init: [][]instruction.Instruction{
+						{&instruction.ReferenceInstruction{Instruction: instruction.RefFunc, Function: types.NewIndex(0)}},
+					},
+				},
+				{
+					Mode: mod.ElementPassive,
+					Type: types.FuncRef,
+					Init: [][]instruction.Instruction{
+						{&instruction.ReferenceInstruction{Instruction: instruction.RefNull, Type: types.FuncRef}},
+					},
+				},
+				{
+					Mode: mod.ElementDeclarative,
+					Type: types.FuncRef,
+					Init: [][]instruction.Instruction{
+						{&instruction.ReferenceInstruction{Instruction: instruction.RefFunc, Function: types.NewIndex(0)}},
+					},
+				},
+			},
+		},
+		err: nil,
+	},
+	"success 10": {
+		input: concat(
+			header,
+			// type section
+			[]byte{0x01, 0x08, 0x02, 0x60, 0x01, 0x7f, 0x00, 0x60, 0x00, 0x00},
+			// import section
+			[]byte{0x02, 0x29, 0x04,
+				0x03, 'e', 'n', 'v', 0x03, 'l', 'o', 'g', 0x00, 0x00, // (func (type 0))
+				0x03, 'e', 'n', 'v', 0x01, 't', 0x01, 0x70, 0x00, 0x02, // (table 2 funcref)
+				0x03, 'e', 'n', 'v', 0x03, 'm', 'e', 'm', 0x02, 0x00, 0x01, // (memory 1)
+				0x03, 'e', 'n', 'v', 0x01, 'g', 0x03, 0x7e, 0x01, // (global (mut i64))
+			},
+			// function section
+			[]byte{0x03, 0x02, 0x01, 0x01},
+			// code section
+			[]byte{0x0a, 0x08, 0x01, 0x06, 0x00, 0x41, 0x01, 0x10, 0x00, 0x0b},
+		),
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{Parameters: []types.Type{types.I32}},
+				{},
+			},
+			Functions: []*mod.Function{
+				{
+					Import:     &mod.Import{Module: "env", Name: "log"},
+					Type:       types.NewIndex(0),
+					Parameters: []*mod.Local{{Type: types.I32}},
+				},
+				{
+					Type: types.NewIndex(1),
+					Instructions: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+						&instruction.CallInstruction{Instruction: instruction.Call, Index: types.NewIndex(0)},
+					},
+				},
+			},
+			Tables: []*mod.Table{
+				{Import: &mod.Import{Module: "env", Name: "t"}, Limits: mod.Limits{Min: 2}, Type: types.FuncRef},
+			},
+			Memories: []*mod.Memory{
+				{Import: &mod.Import{Module: "env", Name: "mem"}, Limits: mod.Limits{Min: 1}},
+			},
+			Globals: []*mod.Global{
+				{Import: &mod.Import{Module: "env", Name: "g"}, Type: types.I64, Mutable: true},
+			},
+		},
+		err: nil,
+	},
+	"success 11": {
+		input: concat(
+			header,
+			// type section
+			[]byte{0x01, 0x04, 0x01, 0x60, 0x00, 0x00},
+			// function section
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			// start section
+			[]byte{0x08, 0x01, 0x00},
+			// code section
+			[]byte{0x0a, 0x06, 0x01, 0x04, 0x00, 0x01, 0x00, 0x0b},
+		),
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Instructions: []instruction.Instruction{
+						&instruction.ControlInstruction{Instruction: instruction.Nop},
+						&instruction.ControlInstruction{Instruction: instruction.Unreachable},
+					},
+				},
+			},
+			Start: indexPtr(types.NewIndex(0)),
+		},
+		err: nil,
+	},
+	"success 12": {
+		input: concat(
+			header,
+			// type section
+			[]byte{0x01, 0x04, 0x01, 0x60, 0x00, 0x00},
+			// function section
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			// code section
+			[]byte{0x0a, 0x07, 0x01,
+				0x05, 0x01, 0xc8, 0x01, 0x7f, // (local i32) * 200
+				0x0b,
+			},
+		),
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{},
+			},
+			Functions: []*mod.Function{
+				{
+					Type:   types.NewIndex(0),
+					Locals: makeLocals(200, types.I32),
+				},
+			},
+		},
+		err: nil,
+	},
+	"invalid import kind": {
+		input: concat(header,
+			[]byte{0x02, 0x06, 0x01, 0x01, 'm', 0x01, 'n', 0x04},
+		),
+		err: mod.ErrInvalidFormat,
+	},
+	"invalid element segment flag": {
+		input: concat(header,
+			[]byte{0x09, 0x02, 0x01, 0x08},
+		),
+		err: mod.ErrInvalidFormat,
+	},
+	"invalid global mutability": {
+		input: concat(header,
+			[]byte{0x06, 0x06, 0x01, 0x7f, 0x02, 0x41, 0x00, 0x0b},
+		),
+		err: mod.ErrInvalidFormat,
+	},
+	"empty module": {
+		input: header,
+		mod:   &mod.Module{},
+		err:   nil,
+	},
+	"invalid magic": {
+		input: []byte{0x00, 0x61, 0x73, 0x6e, 0x01, 0x00, 0x00, 0x00},
+		err:   mod.ErrInvalidFormat,
+	},
+	"invalid version": {
+		input: []byte{0x00, 0x61, 0x73, 0x6d, 0x02, 0x00, 0x00, 0x00},
+		err:   mod.ErrInvalidFormat,
+	},
+	"truncated section": {
+		input: concat(header, []byte{0x01, 0x05, 0x01, 0x60}),
+		err:   mod.ErrInvalidFormat,
+	},
+	"section size mismatch": {
+		input: concat(header, []byte{0x01, 0x05, 0x01, 0x60, 0x00, 0x00, 0x00}),
+		err:   mod.ErrInvalidFormat,
+	},
+	"section out of order": {
+		input: concat(header,
+			[]byte{0x03, 0x01, 0x00},
+			[]byte{0x01, 0x01, 0x00},
+		),
+		err: mod.ErrInvalidFormat,
+	},
+	"integer too large": {
+		input: concat(header, []byte{0x01, 0x06, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}),
+		err:   mod.ErrInvalidFormat,
+	},
+	"function without code": {
+		input: concat(header,
+			[]byte{0x01, 0x04, 0x01, 0x60, 0x00, 0x00},
+			[]byte{0x03, 0x02, 0x01, 0x00},
+		),
+		err: mod.ErrInvalidFormat,
+	},
+	"illegal opcode": {
+		input: concat(header,
+			[]byte{0x01, 0x04, 0x01, 0x60, 0x00, 0x00},
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			[]byte{0x0a, 0x05, 0x01, 0x03, 0x00, 0xff, 0x0b},
+		),
+		err: mod.ErrInvalidFormat,
+	},
+	"illegal prefixed opcode": {
+		input: concat(header,
+			[]byte{0x01, 0x04, 0x01, 0x60, 0x00, 0x00},
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			[]byte{0x0a, 0x06, 0x01, 0x04, 0x00, 0xfc, 0x7f, 0x0b},
+		),
+		err: mod.ErrInvalidFormat,
+	},
+	"data index without data count": {
+		input: concat(header,
+			[]byte{0x01, 0x04, 0x01, 0x60, 0x00, 0x00},
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			[]byte{0x0a, 0x07, 0x01, 0x05, 0x00, 0xfc, 0x09, 0x00, 0x0b},
+		),
+		err: mod.ErrInvalidFormat,
+	},
+	"data count mismatch": {
+		input: concat(header,
+			[]byte{0x0c, 0x01, 0x01},
+		),
+		err: mod.ErrInvalidFormat,
+	},
+	"too many locals": {
+		input: concat(header,
+			[]byte{0x01, 0x04, 0x01, 0x60, 0x00, 0x00},
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			[]byte{0x0a, 0x0b, 0x01, 0x09, 0x02,
+				0xa8, 0xc3, 0x01, 0x7f, // (local i32) * 25000
+				0xa9, 0xc3, 0x01, 0x7e, // (local i64) * 25001
+				0x0b,
+			},
+		),
+		err: mod.ErrInvalidFormat,
+	},
+	"missing end": {
+		input: concat(header,
+			[]byte{0x01, 0x04, 0x01, 0x60, 0x00, 0x00},
+			[]byte{0x03, 0x02, 0x01, 0x00},
+			[]byte{0x0a, 0x05, 0x01, 0x03, 0x00, 0x41, 0x00},
+		),
+		err: mod.ErrInvalidFormat,
+	},
+}
+
+func Test_Decode(t *testing.T) {
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			d := NewDecoder(bytes.NewReader(tt.input))
+			m, err := d.Decode()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Decoder.Decode(): err: want: %v, got:%v", tt.err, err)
+			}
+
+			if diff := cmp.Diff(m, tt.mod); diff != "" {
+				t.Errorf("Decoder.Decode(), differs: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func makeLocals(n int, typ types.Type) []*mod.Local {
+	locals := make([]*mod.Local, n)
+	for i := range locals {
+		locals[i] = &mod.Local{Type: typ}
+	}
+
+	return locals
+}
+
+func indexPtr(idx types.Index) *types.Index {
+	return &idx
+}
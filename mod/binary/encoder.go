@@ -0,0 +1,874 @@
+package binary
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/kechako/wasmexec/mod"
+	"github.com/kechako/wasmexec/mod/instruction"
+	"github.com/kechako/wasmexec/mod/types"
+)
+
+var (
+	errUnsupportedType        = errors.New("unsupported type")
+	errUnsupportedInstruction = errors.New("unsupported instruction")
+	errUnsupportedExport      = errors.New("unsupported export")
+	errTypeNotFound           = errors.New("type is not found")
+	errFunctionNotFound       = errors.New("function is not found")
+	errTableNotFound          = errors.New("table is not found")
+	errMemoryNotFound         = errors.New("memory is not found")
+	errGlobalNotFound         = errors.New("global is not found")
+	errElementNotFound        = errors.New("element segment is not found")
+	errDataNotFound           = errors.New("data segment is not found")
+	errLocalNotFound          = errors.New("local variable is not found")
+	errBlockNotFound          = errors.New("block is not found")
+	errLabelNotFound          = errors.New("label is not found")
+	errInvalidInstruction     = errors.New("invalid instruction")
+	errImportAfterDefinition  = errors.New("import after definition")
+)
+
+type Encoder struct {
+	w io.Writer
+}
+
+var _ mod.Encoder = (*Encoder)(nil)
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w: w,
+	}
+}
+
+func (e *Encoder) Encode(m *mod.Module) error {
+	enc := &moduleEncoder{
+		m:       m,
+		typeIdx: make(map[string]int),
+	}
+	b, err := enc.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode wasm: %w", err)
+	}
+
+	if _, err := e.w.Write(b); err != nil {
+		return fmt.Errorf("failed to write wasm: %w", err)
+	}
+
+	return nil
+}
+
+type moduleEncoder struct {
+	m *mod.Module
+
+	// encoded function types and their indices keyed by the encoded bytes
+	types   [][]byte
+	typeIdx map[string]int
+}
+
+func (e *moduleEncoder) Encode() ([]byte, error) {
+	// the types of the module keep their indices, and the types only used
+	// by blocks or functions without a type follow them.
+	for i, t := range e.m.Types {
+		if _, err := e.appendType(t); err != nil {
+			return nil, fmt.Errorf("type %d: %w", i, err)
+		}
+	}
+
+	imports, err := e.encodeImports()
+	if err != nil {
+		return nil, err
+	}
+
+	defined := definedFunctions(e.m.Functions)
+	var funcs []byte
+	funcs = appendU32(funcs, uint32(len(defined)))
+	for _, f := range defined {
+		idx, err := e.funcTypeIndex(f)
+		if err != nil {
+			return nil, err
+		}
+		funcs = appendU32(funcs, uint32(idx))
+	}
+
+	code, err := e.encodeCode()
+	if err != nil {
+		return nil, err
+	}
+
+	exports, err := e.encodeExports()
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := e.encodeTables()
+	if err != nil {
+		return nil, err
+	}
+
+	var mems []byte
+	var nmems int
+	for _, mem := range e.m.Memories {
+		if mem.Import == nil {
+			mems = appendLimits(mems, mem.Limits)
+			nmems++
+		}
+	}
+	mems = append(appendU32(nil, uint32(nmems)), mems...)
+
+	globals, err := e.encodeGlobals()
+	if err != nil {
+		return nil, err
+	}
+
+	elems, err := e.encodeElements()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := e.encodeData()
+	if err != nil {
+		return nil, err
+	}
+
+	var typs []byte
+	typs = appendU32(typs, uint32(len(e.types)))
+	for _, t := range e.types {
+		typs = append(typs, t...)
+	}
+
+	b := append([]byte{}, magic...)
+	b = append(b, version...)
+	if len(e.types) > 0 {
+		b = appendSection(b, sectionType, typs)
+	}
+	if imports != nil {
+		b = appendSection(b, sectionImport, imports)
+	}
+	if len(defined) > 0 {
+		b = appendSection(b, sectionFunction, funcs)
+	}
+	if tables != nil {
+		b = appendSection(b, sectionTable, tables)
+	}
+	if nmems > 0 {
+		b = appendSection(b, sectionMemory, mems)
+	}
+	if globals != nil {
+		b = appendSection(b, sectionGlobal, globals)
+	}
+	if len(e.m.Exports) > 0 {
+		b = appendSection(b, sectionExport, exports)
+	}
+	if e.m.Start != nil {
+		idx, ok := e.m.FunctionIndex(*e.m.Start)
+		if !ok {
+			return nil, fmt.Errorf("start: %w", errFunctionNotFound)
+		}
+		b = appendSection(b, sectionStart, appendU32(nil, uint32(idx)))
+	}
+	if len(e.m.Elements) > 0 {
+		b = appendSection(b, sectionElement, elems)
+	}
+	if len(e.m.Data) > 0 {
+		// the data count section allows data indices in the code
+		b = appendSection(b, sectionDataCount, appendU32(nil, uint32(len(e.m.Data))))
+	}
+	if len(defined) > 0 {
+		b = appendSection(b, sectionCode, code)
+	}
+	if len(e.m.Data) > 0 {
+		b = appendSection(b, sectionData, data)
+	}
+
+	return b, nil
+}
+
+// definedFunctions returns the functions which are not imported.
+func definedFunctions(funcs []*mod.Function) []*mod.Function {
+	var defined []*mod.Function
+	for _, f := range funcs {
+		if f.Import == nil {
+			defined = append(defined, f)
+		}
+	}
+
+	return defined
+}
+
+// encodeImports returns the import section, or nil if the module has no
+// imports. The imports must precede the definitions of the same kind, since
+// they take the first indices.
+func (e *moduleEncoder) encodeImports() ([]byte, error) {
+	var b []byte
+	var n int
+	appendImport := func(imp *mod.Import, kind byte) {
+		b = appendName(b, imp.Module)
+		b = appendName(b, imp.Name)
+		b = append(b, kind)
+		n++
+	}
+
+	defined := false
+	for i, f := range e.m.Functions {
+		if f.Import == nil {
+			defined = true
+			continue
+		}
+		if defined {
+			return nil, fmt.Errorf("func %d: %w", i, errImportAfterDefinition)
+		}
+		idx, err := e.funcTypeIndex(f)
+		if err != nil {
+			return nil, fmt.Errorf("func %d: %w", i, err)
+		}
+		appendImport(f.Import, importFunction)
+		b = appendU32(b, uint32(idx))
+	}
+
+	defined = false
+	for i, t := range e.m.Tables {
+		if t.Import == nil {
+			defined = true
+			continue
+		}
+		if defined {
+			return nil, fmt.Errorf("table %d: %w", i, errImportAfterDefinition)
+		}
+		typ, ok := encodeValueType(t.Type)
+		if !ok || !t.Type.IsReference() {
+			return nil, fmt.Errorf("table %d: %w", i, errUnsupportedType)
+		}
+		appendImport(t.Import, importTable)
+		b = append(b, typ)
+		b = appendLimits(b, t.Limits)
+	}
+
+	defined = false
+	for i, mem := range e.m.Memories {
+		if mem.Import == nil {
+			defined = true
+			continue
+		}
+		if defined {
+			return nil, fmt.Errorf("memory %d: %w", i, errImportAfterDefinition)
+		}
+		appendImport(mem.Import, importMemory)
+		b = appendLimits(b, mem.Limits)
+	}
+
+	defined = false
+	for i, g := range e.m.Globals {
+		if g.Import == nil {
+			defined = true
+			continue
+		}
+		if defined {
+			return nil, fmt.Errorf("global %d: %w", i, errImportAfterDefinition)
+		}
+		t, ok := encodeValueType(g.Type)
+		if !ok {
+			return nil, fmt.Errorf("global %d: %w", i, errUnsupportedType)
+		}
+		appendImport(g.Import, importGlobal)
+		b = append(b, t)
+		if g.Mutable {
+			b = append(b, globalVar)
+		} else {
+			b = append(b, globalConst)
+		}
+	}
+
+	if n == 0 {
+		return nil, nil
+	}
+
+	return append(appendU32(nil, uint32(n)), b...), nil
+}
+
+// funcTypeIndex returns the index of the type of the function, which is the
+// type of the function if it matches the signature.
+func (e *moduleEncoder) funcTypeIndex(f *mod.Function) (int, error) {
+	sig := f.Signature()
+	if idx, ok := e.m.TypeIndex(f.Type); ok && e.m.Types[idx].Equal(sig) {
+		return idx, nil
+	}
+
+	return e.typeIndex(sig)
+}
+
+// typeIndex returns the index of the first function type of the signature
+// in the type section, adding the type if it is not yet registered.
+func (e *moduleEncoder) typeIndex(t *mod.FuncType) (int, error) {
+	b, err := encodeFuncType(t)
+	if err != nil {
+		return 0, err
+	}
+
+	if idx, ok := e.typeIdx[string(b)]; ok {
+		return idx, nil
+	}
+
+	return e.appendType(t)
+}
+
+// appendType adds the function type to the type section even if the same
+// type is already registered, and returns its index.
+func (e *moduleEncoder) appendType(t *mod.FuncType) (int, error) {
+	b, err := encodeFuncType(t)
+	if err != nil {
+		return 0, err
+	}
+
+	idx := len(e.types)
+	e.types = append(e.types, b)
+	if _, ok := e.typeIdx[string(b)]; !ok {
+		e.typeIdx[string(b)] = idx
+	}
+
+	return idx, nil
+}
+
+func encodeFuncType(ft *mod.FuncType) ([]byte, error) {
+	b := []byte{funcTypeForm}
+	b = appendU32(b, uint32(len(ft.Parameters)))
+	for _, p := range ft.Parameters {
+		t, ok := encodeValueType(p)
+		if !ok {
+			return nil, errUnsupportedType
+		}
+		b = append(b, t)
+	}
+	b = appendU32(b, uint32(len(ft.Results)))
+	for _, r := range ft.Results {
+		t, ok := encodeValueType(r)
+		if !ok {
+			return nil, errUnsupportedType
+		}
+		b = append(b, t)
+	}
+
+	return b, nil
+}
+
+func (e *moduleEncoder) encodeExports() ([]byte, error) {
+	var b []byte
+	b = appendU32(b, uint32(len(e.m.Exports)))
+	for _, export := range e.m.Exports {
+		b = appendName(b, export.Name)
+
+		switch export.Target {
+		case mod.ExportFunction:
+			idx, ok := e.m.FunctionIndex(export.Index)
+			if !ok {
+				return nil, fmt.Errorf("export %q: %w", export.Name, errFunctionNotFound)
+			}
+			b = append(b, exportFunction)
+			b = appendU32(b, uint32(idx))
+		case mod.ExportTable:
+			idx, ok := e.m.TableIndex(export.Index)
+			if !ok {
+				return nil, fmt.Errorf("export %q: %w", export.Name, errTableNotFound)
+			}
+			b = append(b, exportTable)
+			b = appendU32(b, uint32(idx))
+		case mod.ExportMemory:
+			idx, ok := e.m.MemoryIndex(export.Index)
+			if !ok {
+				return nil, fmt.Errorf("export %q: %w", export.Name, errMemoryNotFound)
+			}
+			b = append(b, exportMemory)
+			b = appendU32(b, uint32(idx))
+		case mod.ExportGlobal:
+			idx, ok := e.m.GlobalIndex(export.Index)
+			if !ok {
+				return nil, fmt.Errorf("export %q: %w", export.Name, errGlobalNotFound)
+			}
+			b = append(b, exportGlobal)
+			b = appendU32(b, uint32(idx))
+		default:
+			return nil, fmt.Errorf("export %q: %w", export.Name, errUnsupportedExport)
+		}
+	}
+
+	return b, nil
+}
+
+// encodeTables returns the table section, or nil if all the tables are
+// imported.
+func (e *moduleEncoder) encodeTables() ([]byte, error) {
+	var b []byte
+	var n int
+	for i, t := range e.m.Tables {
+		if t.Import != nil {
+			continue
+		}
+		n++
+		typ, ok := encodeValueType(t.Type)
+		if !ok || !t.Type.IsReference() {
+			return nil, fmt.Errorf("table %d: %w", i, errUnsupportedType)
+		}
+		b = append(b, typ)
+		b = appendLimits(b, t.Limits)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	return append(appendU32(nil, uint32(n)), b...), nil
+}
+
+// encodeGlobals returns the global section, or nil if all the globals are
+// imported.
+func (e *moduleEncoder) encodeGlobals() ([]byte, error) {
+	var b []byte
+	var n int
+	for i, g := range e.m.Globals {
+		if g.Import != nil {
+			continue
+		}
+		n++
+		t, ok := encodeValueType(g.Type)
+		if !ok {
+			return nil, fmt.Errorf("global %d: %w", i, errUnsupportedType)
+		}
+		b = append(b, t)
+		if g.Mutable {
+			b = append(b, globalVar)
+		} else {
+			b = append(b, globalConst)
+		}
+
+		var err error
+		b, err = e.appendConstExpr(b, g.Init)
+		if err != nil {
+			return nil, fmt.Errorf("global %d: %w", i, err)
+		}
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	return append(appendU32(nil, uint32(n)), b...), nil
+}
+
+func (e *moduleEncoder) encodeElements() ([]byte, error) {
+	var b []byte
+	b = appendU32(b, uint32(len(e.m.Elements)))
+	for i, elem := range e.m.Elements {
+		b2, err := e.appendElement(b, elem)
+		if err != nil {
+			return nil, fmt.Errorf("elem %d: %w", i, err)
+		}
+		b = b2
+	}
+
+	return b, nil
+}
+
+// appendElement appends an element segment, whose elements are written as
+// function indices if all of them are ref.func.
+func (e *moduleEncoder) appendElement(b []byte, elem *mod.Element) ([]byte, error) {
+	funcs := elem.Type == types.FuncRef
+	for _, init := range elem.Init {
+		if len(init) != 1 || init[0].Name() != instruction.RefFunc {
+			funcs = false
+		}
+	}
+
+	var flag uint32
+	if !funcs {
+		flag |= elemExprs
+	}
+	table := 0
+	switch elem.Mode {
+	case mod.ElementActive:
+		idx, ok := e.m.TableIndex(elem.Table)
+		if !ok {
+			return nil, errTableNotFound
+		}
+		// the table index and the type can be omitted only for table 0
+		// and funcref
+		if idx != 0 || elem.Type != types.FuncRef {
+			flag |= elemTableIndex
+		}
+		table = idx
+	case mod.ElementPassive:
+		flag |= elemPassive
+	case mod.ElementDeclarative:
+		flag |= elemPassive | elemTableIndex
+	default:
+		return nil, fmt.Errorf("unknown mode %d", elem.Mode)
+	}
+	b = appendU32(b, flag)
+
+	if elem.Mode == mod.ElementActive {
+		if flag&elemTableIndex != 0 {
+			b = appendU32(b, uint32(table))
+		}
+		var err error
+		b, err = e.appendConstExpr(b, elem.Offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if flag&(elemPassive|elemTableIndex) != 0 {
+		if funcs {
+			b = append(b, elemKindFuncRef)
+		} else {
+			typ, ok := encodeValueType(elem.Type)
+			if !ok || !elem.Type.IsReference() {
+				return nil, errUnsupportedType
+			}
+			b = append(b, typ)
+		}
+	}
+
+	b = appendU32(b, uint32(len(elem.Init)))
+	for _, init := range elem.Init {
+		if !funcs {
+			var err error
+			b, err = e.appendConstExpr(b, init)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		idx, ok := e.m.FunctionIndex(init[0].(*instruction.ReferenceInstruction).Function)
+		if !ok {
+			return nil, errFunctionNotFound
+		}
+		b = appendU32(b, uint32(idx))
+	}
+
+	return b, nil
+}
+
+func (e *moduleEncoder) encodeData() ([]byte, error) {
+	var b []byte
+	b = appendU32(b, uint32(len(e.m.Data)))
+	for i, d := range e.m.Data {
+		switch d.Mode {
+		case mod.DataActive:
+			idx, ok := e.m.MemoryIndex(d.Memory)
+			if !ok {
+				return nil, fmt.Errorf("data %d: %w", i, errMemoryNotFound)
+			}
+			if idx == 0 {
+				b = appendU32(b, dataActive)
+			} else {
+				b = appendU32(b, dataActiveMemory)
+				b = appendU32(b, uint32(idx))
+			}
+
+			var err error
+			b, err = e.appendConstExpr(b, d.Offset)
+			if err != nil {
+				return nil, fmt.Errorf("data %d: %w", i, err)
+			}
+		case mod.DataPassive:
+			b = appendU32(b, dataPassive)
+		default:
+			return nil, fmt.Errorf("data %d: unknown mode %d", i, d.Mode)
+		}
+
+		b = appendU32(b, uint32(len(d.Init)))
+		b = append(b, d.Init...)
+	}
+
+	return b, nil
+}
+
+// appendConstExpr appends a constant expression terminated by end.
+func (e *moduleEncoder) appendConstExpr(b []byte, expr []instruction.Instruction) ([]byte, error) {
+	fe := &functionEncoder{
+		m: e,
+		f: &mod.Function{},
+	}
+
+	return fe.encodeInstructions(b, expr, opEnd)
+}
+
+func (e *moduleEncoder) encodeCode() ([]byte, error) {
+	var b []byte
+	b = appendU32(b, uint32(len(definedFunctions(e.m.Functions))))
+	for i, f := range e.m.Functions {
+		if f.Import != nil {
+			continue
+		}
+		fe := &functionEncoder{
+			m: e,
+			f: f,
+		}
+		body, err := fe.Encode()
+		if err != nil {
+			return nil, fmt.Errorf("func %d: %w", i, err)
+		}
+
+		b = appendU32(b, uint32(len(body)))
+		b = append(b, body...)
+	}
+
+	return b, nil
+}
+
+type functionEncoder struct {
+	m *moduleEncoder
+	f *mod.Function
+
+	// labels of the blocks being encoded, from the outermost one
+	labels []types.ID
+}
+
+func (e *functionEncoder) Encode() ([]byte, error) {
+	var b []byte
+
+	// locals are compressed into runs of the same type
+	type run struct {
+		count uint32
+		typ   byte
+	}
+	var runs []run
+	for _, l := range e.f.Locals {
+		t, ok := encodeValueType(l.Type)
+		if !ok {
+			return nil, errUnsupportedType
+		}
+		if len(runs) > 0 && runs[len(runs)-1].typ == t {
+			runs[len(runs)-1].count++
+		} else {
+			runs = append(runs, run{count: 1, typ: t})
+		}
+	}
+	b = appendU32(b, uint32(len(runs)))
+	for _, r := range runs {
+		b = appendU32(b, r.count)
+		b = append(b, r.typ)
+	}
+
+	return e.encodeInstructions(b, e.f.Instructions, opEnd)
+}
+
+// encodeInstructions appends the instructions followed by the terminator
+// opcode.
+func (e *functionEncoder) encodeInstructions(b []byte, instructions []instruction.Instruction, terminator byte) ([]byte, error) {
+	for _, i := range instructions {
+		var err error
+		b, err = e.encodeInstruction(b, i)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return append(b, terminator), nil
+}
+
+func (e *functionEncoder) encodeInstruction(b []byte, i instruction.Instruction) ([]byte, error) {
+	if op, ok := opcodes[i.Name()]; ok {
+		b = append(b, op)
+	} else if op, ok := prefixedOpcodes[i.Name()]; ok {
+		b = appendU32(append(b, opPrefix), op)
+	} else {
+		return nil, fmt.Errorf("%s: %w", i.Name(), errUnsupportedInstruction)
+	}
+
+	switch i := i.(type) {
+	case *instruction.I32Instruction:
+		if i.Instruction == instruction.I32Const {
+			if len(i.Values) != 1 {
+				return nil, fmt.Errorf("%s: %w", i.Name(), errInvalidInstruction)
+			}
+			b = appendS32(b, i.Values[0])
+		}
+	case *instruction.I64Instruction:
+		if i.Instruction == instruction.I64Const {
+			if len(i.Values) != 1 {
+				return nil, fmt.Errorf("%s: %w", i.Name(), errInvalidInstruction)
+			}
+			b = appendSigned(b, i.Values[0])
+		}
+	case *instruction.F32Instruction:
+		if i.Instruction == instruction.F32Const {
+			if len(i.Values) != 1 {
+				return nil, fmt.Errorf("%s: %w", i.Name(), errInvalidInstruction)
+			}
+			b = appendF32(b, i.Values[0])
+		}
+	case *instruction.F64Instruction:
+		if i.Instruction == instruction.F64Const {
+			if len(i.Values) != 1 {
+				return nil, fmt.Errorf("%s: %w", i.Name(), errInvalidInstruction)
+			}
+			b = appendF64(b, i.Values[0])
+		}
+	case *instruction.VariableInstruction:
+		if i.Instruction == instruction.GlobalGet || i.Instruction == instruction.GlobalSet {
+			idx, ok := e.m.m.GlobalIndex(i.Index)
+			if !ok {
+				return nil, fmt.Errorf("%s: %w", i.Name(), errGlobalNotFound)
+			}
+			b = appendU32(b, uint32(idx))
+			break
+		}
+		idx, ok := e.f.LocalIndex(i.Index)
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", i.Name(), errLocalNotFound)
+		}
+		b = appendU32(b, uint32(idx))
+	case *instruction.ReferenceInstruction:
+		switch i.Instruction {
+		case instruction.RefNull:
+			typ, ok := encodeValueType(i.Type)
+			if !ok || !i.Type.IsReference() {
+				return nil, fmt.Errorf("%s: %w", i.Name(), errUnsupportedType)
+			}
+			b = append(b, typ)
+		case instruction.RefFunc:
+			idx, ok := e.m.m.FunctionIndex(i.Function)
+			if !ok {
+				return nil, fmt.Errorf("%s: %w", i.Name(), errFunctionNotFound)
+			}
+			b = appendU32(b, uint32(idx))
+		}
+	case *instruction.TableInstruction:
+		if i.Instruction == instruction.TableInit || i.Instruction == instruction.ElemDrop {
+			idx, ok := e.m.m.ElementIndex(i.Elem)
+			if !ok {
+				return nil, fmt.Errorf("%s: %w", i.Name(), errElementNotFound)
+			}
+			b = appendU32(b, uint32(idx))
+		}
+		if i.Instruction != instruction.ElemDrop {
+			idx, ok := e.m.m.TableIndex(i.Table)
+			if !ok {
+				return nil, fmt.Errorf("%s: %w", i.Name(), errTableNotFound)
+			}
+			b = appendU32(b, uint32(idx))
+		}
+		if i.Instruction == instruction.TableCopy {
+			idx, ok := e.m.m.TableIndex(i.Source)
+			if !ok {
+				return nil, fmt.Errorf("%s: %w", i.Name(), errTableNotFound)
+			}
+			b = appendU32(b, uint32(idx))
+		}
+	case *instruction.MemoryInstruction:
+		switch i.Instruction {
+		case instruction.MemorySize, instruction.MemoryGrow:
+			b = append(b, 0x00)
+		case instruction.MemoryInit, instruction.DataDrop:
+			idx, ok := e.m.m.DataIndex(i.Data)
+			if !ok {
+				return nil, fmt.Errorf("%s: %w", i.Name(), errDataNotFound)
+			}
+			b = appendU32(b, uint32(idx))
+			if i.Instruction == instruction.MemoryInit {
+				b = append(b, 0x00)
+			}
+		default:
+			b = appendU32(b, i.Align)
+			b = appendU32(b, i.Offset)
+		}
+	case *instruction.CallInstruction:
+		idx, ok := e.m.m.FunctionIndex(i.Index)
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", i.Name(), errFunctionNotFound)
+		}
+		b = appendU32(b, uint32(idx))
+	case *instruction.CallIndirectInstruction:
+		typeIdx, ok := e.m.m.TypeIndex(i.Type)
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", i.Name(), errTypeNotFound)
+		}
+		idx, ok := e.m.m.TableIndex(i.Table)
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", i.Name(), errTableNotFound)
+		}
+		b = appendU32(b, uint32(typeIdx))
+		b = appendU32(b, uint32(idx))
+	case *instruction.BlockInstruction:
+		block, ok := e.f.Block(i.Block)
+		if !ok {
+			return nil, fmt.Errorf("%s %d: %w", i.Name(), i.Block, errBlockNotFound)
+		}
+
+		var err error
+		b, err = e.encodeBlockType(b, block)
+		if err != nil {
+			return nil, err
+		}
+
+		e.labels = append(e.labels, block.Label)
+		defer func() {
+			e.labels = e.labels[:len(e.labels)-1]
+		}()
+
+		if len(block.Else) > 0 {
+			if i.Instruction != instruction.If {
+				return nil, fmt.Errorf("%s %d: %w", i.Name(), i.Block, errInvalidInstruction)
+			}
+			b, err = e.encodeInstructions(b, block.Instructions, opElse)
+			if err != nil {
+				return nil, err
+			}
+			return e.encodeInstructions(b, block.Else, opEnd)
+		}
+
+		return e.encodeInstructions(b, block.Instructions, opEnd)
+	case *instruction.BranchInstruction:
+		if len(i.Labels) == 0 || (i.Instruction != instruction.BrTable && len(i.Labels) != 1) {
+			return nil, fmt.Errorf("%s: %w", i.Name(), errInvalidInstruction)
+		}
+		if i.Instruction == instruction.BrTable {
+			b = appendU32(b, uint32(len(i.Labels)-1))
+		}
+		for _, label := range i.Labels {
+			depth, ok := e.labelDepth(label)
+			if !ok {
+				return nil, fmt.Errorf("%s: %w", i.Name(), errLabelNotFound)
+			}
+			b = appendU32(b, uint32(depth))
+		}
+	}
+
+	return b, nil
+}
+
+// labelDepth returns the depth of the label counted from the innermost block.
+// The depth of the function body is the number of the enclosing blocks.
+func (e *functionEncoder) labelDepth(label types.Index) (int, bool) {
+	if label.IsIndex() {
+		return label.Index, label.Index >= 0 && label.Index <= len(e.labels)
+	}
+
+	for depth := 0; depth < len(e.labels); depth++ {
+		if e.labels[len(e.labels)-1-depth] == label.ID {
+			return depth, true
+		}
+	}
+
+	return 0, false
+}
+
+func (e *functionEncoder) encodeBlockType(b []byte, block *mod.Block) ([]byte, error) {
+	if len(block.Parameters) == 0 {
+		switch len(block.Results) {
+		case 0:
+			return append(b, blockTypeEmpty), nil
+		case 1:
+			t, ok := encodeValueType(block.Results[0].Type)
+			if !ok {
+				return nil, errUnsupportedType
+			}
+			return append(b, t), nil
+		}
+	}
+
+	idx, err := e.m.typeIndex(block.Signature())
+	if err != nil {
+		return nil, err
+	}
+
+	return appendSigned(b, int64(idx)), nil
+}
@@ -0,0 +1,150 @@
+package binary
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kechako/wasmexec/mod"
+	"github.com/kechako/wasmexec/mod/instruction"
+	"github.com/kechako/wasmexec/mod/types"
+)
+
+var encodeTests = map[string]struct {
+	mod    *mod.Module
+	output []byte
+}{
+	"success 01": {
+		mod: &mod.Module{
+			ID: "$testmod",
+			Functions: []*mod.Function{
+				{
+					ID: "$add",
+					Parameters: []*mod.Local{
+						{ID: "$a", Type: types.I32},
+						{ID: "$b", Type: types.I32},
+					},
+					Results: []*mod.Result{
+						{Type: types.I32},
+					},
+					Locals: []*mod.Local{
+						{ID: "$c", Type: types.I32},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndexWithID("$a")},
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndexWithID("$b")},
+						&instruction.I32Instruction{Instruction: instruction.I32Add},
+						&instruction.VariableInstruction{Instruction: instruction.LocalTee, Index: types.NewIndexWithID("$c")},
+					},
+				},
+				{
+					ID: "$main",
+					Results: []*mod.Result{
+						{Type: types.I32},
+					},
+					Blocks: []*mod.Block{
+						{
+							Label: "$block1",
+							Parameters: []*mod.Local{
+								{Type: types.I32},
+							},
+							Results: []*mod.Result{
+								{Type: types.I32},
+							},
+							Instructions: []instruction.Instruction{
+								&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{300}},
+								&instruction.I32Instruction{Instruction: instruction.I32Add},
+							},
+						},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{2}},
+						&instruction.CallInstruction{Instruction: instruction.Call, Index: types.NewIndexWithID("$add")},
+						&instruction.BlockInstruction{Instruction: instruction.Block, Block: 0},
+					},
+				},
+			},
+			Exports: []*mod.Export{
+				{Name: "main", Target: mod.ExportFunction, Index: types.NewIndexWithID("$main")},
+			},
+		},
+		output: concat(
+			header,
+			// type section
+			[]byte{0x01, 0x10, 0x03,
+				0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f, // (i32, i32) -> (i32)
+				0x60, 0x00, 0x01, 0x7f, // () -> (i32)
+				0x60, 0x01, 0x7f, 0x01, 0x7f, // (i32) -> (i32)
+			},
+			// function section
+			[]byte{0x03, 0x03, 0x02, 0x00, 0x01},
+			// export section
+			[]byte{0x07, 0x08, 0x01, 0x04, 'm', 'a', 'i', 'n', 0x00, 0x01},
+			// code section
+			[]byte{0x0a, 0x1d, 0x02,
+				0x0b, 0x01, 0x01, 0x7f,
+				0x20, 0x00, // local.get 0
+				0x20, 0x01, // local.get 1
+				0x6a,       // i32.add
+				0x22, 0x02, // local.tee 2
+				0x0b, // end
+				0x0f, 0x00,
+				0x41, 0x01, // i32.const 1
+				0x41, 0x02, // i32.const 2
+				0x10, 0x00, // call 0
+				0x02, 0x02, // block (type 2)
+				0x41, 0xac, 0x02, // i32.const 300
+				0x6a, // i32.add
+				0x0b, // end
+				0x0b, // end
+			},
+		),
+	},
+	"empty module": {
+		mod:    &mod.Module{},
+		output: header,
+	},
+}
+
+func Test_Encode(t *testing.T) {
+	for name, tt := range encodeTests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := NewEncoder(&buf).Encode(tt.mod)
+			if err != nil {
+				t.Fatalf("Encoder.Encode(): %v", err)
+			}
+
+			if diff := cmp.Diff(buf.Bytes(), tt.output); diff != "" {
+				t.Errorf("Encoder.Encode(), differs: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Encode_RoundTrip(t *testing.T) {
+	for name, tt := range tests {
+		if tt.err != nil {
+			continue
+		}
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := NewEncoder(&buf).Encode(tt.mod)
+			if err != nil {
+				t.Fatalf("Encoder.Encode(): %v", err)
+			}
+
+			m, err := NewDecoder(&buf).Decode()
+			if err != nil {
+				t.Fatalf("Decoder.Decode(): %v", err)
+			}
+
+			if diff := cmp.Diff(m, tt.mod); diff != "" {
+				t.Errorf("Decoder.Decode(), differs: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package binary
+
+import (
+	"github.com/kechako/wasmexec/mod/instruction"
+	"github.com/kechako/wasmexec/mod/types"
+)
+
+const (
+	opElse byte = 0x05
+	opEnd  byte = 0x0b
+
+	// opPrefix is the prefix of instructions whose opcode follows as a u32.
+	opPrefix byte = 0xfc
+)
+
+var opcodes = map[instruction.InstructionName]byte{
+	// Control instructions
+	instruction.Unreachable: 0x00,
+	instruction.Nop:         0x01,
+	instruction.Block:       0x02,
+	instruction.Loop:        0x03,
+	instruction.If:          0x04,
+	instruction.Br:          0x0c,
+	instruction.BrIf:        0x0d,
+	instruction.BrTable:     0x0e,
+	instruction.Return:      0x0f,
+	instruction.Call:        0x10,
+
+	instruction.CallIndirect: 0x11,
+
+	// Parametric instructions
+	instruction.Drop: 0x1a,
+
+	// Variable instructions
+	instruction.LocalGet:  0x20,
+	instruction.LocalSet:  0x21,
+	instruction.LocalTee:  0x22,
+	instruction.GlobalGet: 0x23,
+	instruction.GlobalSet: 0x24,
+
+	// Table instructions
+	instruction.TableGet: 0x25,
+	instruction.TableSet: 0x26,
+
+	// Memory instructions
+	instruction.I32Load:    0x28,
+	instruction.I64Load:    0x29,
+	instruction.F32Load:    0x2a,
+	instruction.F64Load:    0x2b,
+	instruction.I32Load8S:  0x2c,
+	instruction.I32Load8U:  0x2d,
+	instruction.I32Load16S: 0x2e,
+	instruction.I32Load16U: 0x2f,
+	instruction.I64Load8S:  0x30,
+	instruction.I64Load8U:  0x31,
+	instruction.I64Load16S: 0x32,
+	instruction.I64Load16U: 0x33,
+	instruction.I64Load32S: 0x34,
+	instruction.I64Load32U: 0x35,
+	instruction.I32Store:   0x36,
+	instruction.I64Store:   0x37,
+	instruction.F32Store:   0x38,
+	instruction.F64Store:   0x39,
+	instruction.I32Store8:  0x3a,
+	instruction.I32Store16: 0x3b,
+	instruction.I64Store8:  0x3c,
+	instruction.I64Store16: 0x3d,
+	instruction.I64Store32: 0x3e,
+	instruction.MemorySize: 0x3f,
+	instruction.MemoryGrow: 0x40,
+
+	// Numeric instructions
+	instruction.I32Const:  0x41,
+	instruction.I32Eqz:    0x45,
+	instruction.I32Eq:     0x46,
+	instruction.I32Ne:     0x47,
+	instruction.I32LtS:    0x48,
+	instruction.I32LtU:    0x49,
+	instruction.I32GtS:    0x4a,
+	instruction.I32GtU:    0x4b,
+	instruction.I32LeS:    0x4c,
+	instruction.I32LeU:    0x4d,
+	instruction.I32GeS:    0x4e,
+	instruction.I32GeU:    0x4f,
+	instruction.I32Clz:    0x67,
+	instruction.I32Ctz:    0x68,
+	instruction.I32Popcnt: 0x69,
+	instruction.I32Add:    0x6a,
+	instruction.I32Sub:    0x6b,
+	instruction.I32Mul:    0x6c,
+	instruction.I32DivS:   0x6d,
+	instruction.I32DivU:   0x6e,
+	instruction.I32RemS:   0x6f,
+	instruction.I32RemU:   0x70,
+	instruction.I32And:    0x71,
+	instruction.I32Or:     0x72,
+	instruction.I32Xor:    0x73,
+	instruction.I32Shl:    0x74,
+	instruction.I32ShrS:   0x75,
+	instruction.I32ShrU:   0x76,
+	instruction.I32Rotl:   0x77,
+	instruction.I32Rotr:   0x78,
+
+	instruction.I64Const:  0x42,
+	instruction.I64Eqz:    0x50,
+	instruction.I64Eq:     0x51,
+	instruction.I64Ne:     0x52,
+	instruction.I64LtS:    0x53,
+	instruction.I64LtU:    0x54,
+	instruction.I64GtS:    0x55,
+	instruction.I64GtU:    0x56,
+	instruction.I64LeS:    0x57,
+	instruction.I64LeU:    0x58,
+	instruction.I64GeS:    0x59,
+	instruction.I64GeU:    0x5a,
+	instruction.I64Clz:    0x79,
+	instruction.I64Ctz:    0x7a,
+	instruction.I64Popcnt: 0x7b,
+	instruction.I64Add:    0x7c,
+	instruction.I64Sub:    0x7d,
+	instruction.I64Mul:    0x7e,
+	instruction.I64DivS:   0x7f,
+	instruction.I64DivU:   0x80,
+	instruction.I64RemS:   0x81,
+	instruction.I64RemU:   0x82,
+	instruction.I64And:    0x83,
+	instruction.I64Or:     0x84,
+	instruction.I64Xor:    0x85,
+	instruction.I64Shl:    0x86,
+	instruction.I64ShrS:   0x87,
+	instruction.I64ShrU:   0x88,
+	instruction.I64Rotl:   0x89,
+	instruction.I64Rotr:   0x8a,
+
+	instruction.F32Const:    0x43,
+	instruction.F32Eq:       0x5b,
+	instruction.F32Ne:       0x5c,
+	instruction.F32Lt:       0x5d,
+	instruction.F32Gt:       0x5e,
+	instruction.F32Le:       0x5f,
+	instruction.F32Ge:       0x60,
+	instruction.F32Abs:      0x8b,
+	instruction.F32Neg:      0x8c,
+	instruction.F32Ceil:     0x8d,
+	instruction.F32Floor:    0x8e,
+	instruction.F32Trunc:    0x8f,
+	instruction.F32Nearest:  0x90,
+	instruction.F32Sqrt:     0x91,
+	instruction.F32Add:      0x92,
+	instruction.F32Sub:      0x93,
+	instruction.F32Mul:      0x94,
+	instruction.F32Div:      0x95,
+	instruction.F32Min:      0x96,
+	instruction.F32Max:      0x97,
+	instruction.F32Copysign: 0x98,
+
+	instruction.F64Const:    0x44,
+	instruction.F64Eq:       0x61,
+	instruction.F64Ne:       0x62,
+	instruction.F64Lt:       0x63,
+	instruction.F64Gt:       0x64,
+	instruction.F64Le:       0x65,
+	instruction.F64Ge:       0x66,
+	instruction.F64Abs:      0x99,
+	instruction.F64Neg:      0x9a,
+	instruction.F64Ceil:     0x9b,
+	instruction.F64Floor:    0x9c,
+	instruction.F64Trunc:    0x9d,
+	instruction.F64Nearest:  0x9e,
+	instruction.F64Sqrt:     0x9f,
+	instruction.F64Add:      0xa0,
+	instruction.F64Sub:      0xa1,
+	instruction.F64Mul:      0xa2,
+	instruction.F64Div:      0xa3,
+	instruction.F64Min:      0xa4,
+	instruction.F64Max:      0xa5,
+	instruction.F64Copysign: 0xa6,
+
+	// Conversion instructions
+	instruction.I32WrapI64:        0xa7,
+	instruction.I32TruncF32S:      0xa8,
+	instruction.I32TruncF32U:      0xa9,
+	instruction.I32TruncF64S:      0xaa,
+	instruction.I32TruncF64U:      0xab,
+	instruction.I64ExtendI32S:     0xac,
+	instruction.I64ExtendI32U:     0xad,
+	instruction.I64TruncF32S:      0xae,
+	instruction.I64TruncF32U:      0xaf,
+	instruction.I64TruncF64S:      0xb0,
+	instruction.I64TruncF64U:      0xb1,
+	instruction.F32ConvertI32S:    0xb2,
+	instruction.F32ConvertI32U:    0xb3,
+	instruction.F32ConvertI64S:    0xb4,
+	instruction.F32ConvertI64U:    0xb5,
+	instruction.F32DemoteF64:      0xb6,
+	instruction.F64ConvertI32S:    0xb7,
+	instruction.F64ConvertI32U:    0xb8,
+	instruction.F64ConvertI64S:    0xb9,
+	instruction.F64ConvertI64U:    0xba,
+	instruction.F64PromoteF32:     0xbb,
+	instruction.I32ReinterpretF32: 0xbc,
+	instruction.I64ReinterpretF64: 0xbd,
+	instruction.F32ReinterpretI32: 0xbe,
+	instruction.F64ReinterpretI64: 0xbf,
+	instruction.I32Extend8S:       0xc0,
+	instruction.I32Extend16S:      0xc1,
+	instruction.I64Extend8S:       0xc2,
+	instruction.I64Extend16S:      0xc3,
+	instruction.I64Extend32S:      0xc4,
+
+	// Reference instructions
+	instruction.RefNull:   0xd0,
+	instruction.RefIsNull: 0xd1,
+	instruction.RefFunc:   0xd2,
+}
+
+// prefixedOpcodes holds the opcodes following opPrefix.
+var prefixedOpcodes = map[instruction.InstructionName]uint32{
+	instruction.I32TruncSatF32S: 0,
+	instruction.I32TruncSatF32U: 1,
+	instruction.I32TruncSatF64S: 2,
+	instruction.I32TruncSatF64U: 3,
+	instruction.I64TruncSatF32S: 4,
+	instruction.I64TruncSatF32U: 5,
+	instruction.I64TruncSatF64S: 6,
+	instruction.I64TruncSatF64U: 7,
+	instruction.MemoryInit:      8,
+	instruction.DataDrop:        9,
+	instruction.TableInit:       12,
+	instruction.ElemDrop:        13,
+	instruction.TableCopy:       14,
+	instruction.TableGrow:       15,
+	instruction.TableSize:       16,
+	instruction.TableFill:       17,
+}
+
+var instructionNames = func() map[byte]instruction.InstructionName {
+	names := make(map[byte]instruction.InstructionName, len(opcodes))
+	for name, op := range opcodes {
+		names[op] = name
+	}
+	return names
+}()
+
+var prefixedInstructionNames = func() map[uint32]instruction.InstructionName {
+	names := make(map[uint32]instruction.InstructionName, len(prefixedOpcodes))
+	for name, op := range prefixedOpcodes {
+		names[op] = name
+	}
+	return names
+}()
+
+const (
+	valueTypeI32 byte = 0x7f
+	valueTypeI64 byte = 0x7e
+	valueTypeF32 byte = 0x7d
+	valueTypeF64 byte = 0x7c
+
+	refTypeFuncRef byte = 0x70
+
+	// elemKindFuncRef is the element kind of funcref in element segments
+	// of function indices.
+	elemKindFuncRef byte = 0x00
+
+	blockTypeEmpty byte = 0x40
+
+	funcTypeForm byte = 0x60
+
+	limitsMin    byte = 0x00
+	limitsMinMax byte = 0x01
+
+	globalConst byte = 0x00
+	globalVar   byte = 0x01
+)
+
+// flags of element segments, which are combinations of the bits
+const (
+	// elemPassive is set for passive and declarative segments.
+	elemPassive uint32 = 1 << iota
+	// elemTableIndex is set for active segments with a table index, or
+	// declarative segments with elemPassive.
+	elemTableIndex
+	// elemExprs is set for segments of expressions instead of function
+	// indices.
+	elemExprs
+)
+
+// flags of data segments
+const (
+	dataActive       uint32 = 0
+	dataPassive      uint32 = 1
+	dataActiveMemory uint32 = 2
+)
+
+func decodeValueType(b byte) types.Type {
+	switch b {
+	case valueTypeI32:
+		return types.I32
+	case valueTypeI64:
+		return types.I64
+	case valueTypeF32:
+		return types.F32
+	case valueTypeF64:
+		return types.F64
+	case refTypeFuncRef:
+		return types.FuncRef
+	}
+
+	return types.Unkown
+}
+
+func encodeValueType(typ types.Type) (byte, bool) {
+	switch typ {
+	case types.I32:
+		return valueTypeI32, true
+	case types.I64:
+		return valueTypeI64, true
+	case types.F32:
+		return valueTypeF32, true
+	case types.F64:
+		return valueTypeF64, true
+	case types.FuncRef:
+		return refTypeFuncRef, true
+	}
+
+	return 0, false
+}
+
+type sectionID byte
+
+const (
+	sectionCustom sectionID = iota
+	sectionType
+	sectionImport
+	sectionFunction
+	sectionTable
+	sectionMemory
+	sectionGlobal
+	sectionExport
+	sectionStart
+	sectionElement
+	sectionCode
+	sectionData
+	sectionDataCount
+)
+
+const (
+	exportFunction byte = 0x00
+	exportTable    byte = 0x01
+	exportMemory   byte = 0x02
+	exportGlobal   byte = 0x03
+)
+
+const (
+	importFunction byte = 0x00
+	importTable    byte = 0x01
+	importMemory   byte = 0x02
+	importGlobal   byte = 0x03
+)
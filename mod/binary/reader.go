@@ -0,0 +1,159 @@
+package binary
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/kechako/wasmexec/mod"
+)
+
+// reader reads the primitive values of the binary format from a byte slice
+// and keeps track of the offset for error messages.
+type reader struct {
+	buf []byte
+	pos int
+}
+
+func newReader(buf []byte) *reader {
+	return &reader{
+		buf: buf,
+	}
+}
+
+func (r *reader) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s at offset 0x%x", mod.ErrInvalidFormat, fmt.Sprintf(format, args...), r.pos)
+}
+
+func (r *reader) len() int {
+	return len(r.buf) - r.pos
+}
+
+func (r *reader) readByte() (byte, error) {
+	if r.pos >= len(r.buf) {
+		return 0, r.errorf("unexpected end")
+	}
+
+	b := r.buf[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *reader) readBytes(n int) ([]byte, error) {
+	if n < 0 || n > r.len() {
+		return nil, r.errorf("unexpected end")
+	}
+
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// readUnsigned reads an unsigned LEB128 integer of at most bits bits.
+func (r *reader) readUnsigned(bits int) (uint64, error) {
+	var result uint64
+	var shift int
+	for {
+		b, err := r.readByte()
+		if err != nil {
+			return 0, err
+		}
+
+		if shift+7 > bits {
+			// the last byte must not have bits beyond the value size
+			if b&0x80 != 0 || b>>(bits-shift) != 0 {
+				return 0, r.errorf("integer too large")
+			}
+		}
+
+		result |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return result, nil
+		}
+		shift += 7
+	}
+}
+
+// readSigned reads a signed LEB128 integer of at most bits bits.
+func (r *reader) readSigned(bits int) (int64, error) {
+	var result int64
+	var shift int
+	for {
+		b, err := r.readByte()
+		if err != nil {
+			return 0, err
+		}
+
+		if shift+7 > bits {
+			// the unused bits of the last byte must be a sign extension
+			if b&0x80 != 0 {
+				return 0, r.errorf("integer too large")
+			}
+			rest := int8(b<<1) >> (bits - shift)
+			if rest != 0 && rest != -1 {
+				return 0, r.errorf("integer too large")
+			}
+		}
+
+		result |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				result |= -1 << shift
+			}
+			return result, nil
+		}
+	}
+}
+
+func (r *reader) readU32() (uint32, error) {
+	n, err := r.readUnsigned(32)
+	return uint32(n), err
+}
+
+func (r *reader) readS32() (int32, error) {
+	n, err := r.readSigned(32)
+	return int32(n), err
+}
+
+func (r *reader) readS64() (int64, error) {
+	return r.readSigned(64)
+}
+
+func (r *reader) readS33() (int64, error) {
+	return r.readSigned(33)
+}
+
+func (r *reader) readF32() (float32, error) {
+	b, err := r.readBytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+}
+
+func (r *reader) readF64() (float64, error) {
+	b, err := r.readBytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+func (r *reader) readName() (string, error) {
+	n, err := r.readU32()
+	if err != nil {
+		return "", err
+	}
+
+	b, err := r.readBytes(int(n))
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(b) {
+		return "", r.errorf("malformed UTF-8 encoding")
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,68 @@
+package binary
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/kechako/wasmexec/mod"
+)
+
+func appendUnsigned(b []byte, n uint64) []byte {
+	for {
+		c := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func appendSigned(b []byte, n int64) []byte {
+	for {
+		c := byte(n & 0x7f)
+		n >>= 7
+		if (n == 0 && c&0x40 == 0) || (n == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func appendU32(b []byte, n uint32) []byte {
+	return appendUnsigned(b, uint64(n))
+}
+
+func appendF32(b []byte, f float32) []byte {
+	return binary.LittleEndian.AppendUint32(b, math.Float32bits(f))
+}
+
+func appendF64(b []byte, f float64) []byte {
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+}
+
+func appendS32(b []byte, n int32) []byte {
+	return appendSigned(b, int64(n))
+}
+
+func appendName(b []byte, s string) []byte {
+	b = appendU32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func appendLimits(b []byte, limits mod.Limits) []byte {
+	if !limits.HasMax {
+		b = append(b, limitsMin)
+		return appendU32(b, limits.Min)
+	}
+
+	b = append(b, limitsMinMax)
+	b = appendU32(b, limits.Min)
+	return appendU32(b, limits.Max)
+}
+
+func appendSection(b []byte, id sectionID, content []byte) []byte {
+	b = append(b, byte(id))
+	b = appendU32(b, uint32(len(content)))
+	return append(b, content...)
+}
@@ -0,0 +1,5 @@
+package mod
+
+type Encoder interface {
+	Encode(m *Module) error
+}
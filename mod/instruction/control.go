@@ -19,11 +19,39 @@ func (i *CallInstruction) Name() InstructionName {
 	return i.Instruction
 }
 
+type CallIndirectInstruction struct {
+	Instruction InstructionName
+	// Table is the table of the functions to call.
+	Table types.Index
+	// Type is the index of the type of the function to call, which is
+	// checked at runtime.
+	Type types.Index
+}
+
+func (i *CallIndirectInstruction) Name() InstructionName {
+	return i.Instruction
+}
+
 type BlockInstruction struct {
 	Instruction InstructionName
-	Label       types.ID
+	// Block is the number of the block in the function, which is unique
+	// even if the labels of the blocks are reused.
+	Block int
 }
 
 func (i *BlockInstruction) Name() InstructionName {
 	return i.Instruction
 }
+
+type BranchInstruction struct {
+	Instruction InstructionName
+	// Labels are the target labels, which are indices of the enclosing
+	// blocks counted from the innermost one, or their IDs. br_table has the
+	// labels of each operand followed by the default label, and the others
+	// have a single label.
+	Labels []types.Index
+}
+
+func (i *BranchInstruction) Name() InstructionName {
+	return i.Instruction
+}
@@ -0,0 +1,9 @@
+package instruction
+
+type ConversionInstruction struct {
+	Instruction InstructionName
+}
+
+func (i *ConversionInstruction) Name() InstructionName {
+	return i.Instruction
+}
@@ -4,41 +4,284 @@ type InstructionName string
 
 const (
 	// Numeric instruction
-	I32Const InstructionName = "i32.const"
-	I32Add   InstructionName = "i32.add"
-	I32Sub   InstructionName = "i32.sub"
-	I32Mul   InstructionName = "i32.mul"
-	I32DivS  InstructionName = "i32.div_s"
-	I32Eqz   InstructionName = "i32.eqz"
-	I32Eq    InstructionName = "i32.eq"
-	I32Ne    InstructionName = "i32.ne"
-	I32LtS   InstructionName = "i32.lt_s"
-	I32GtS   InstructionName = "i32.gt_s"
-	I32LeS   InstructionName = "i32.le_s"
-	I32GeS   InstructionName = "i32.ge_s"
+	I32Const  InstructionName = "i32.const"
+	I32Clz    InstructionName = "i32.clz"
+	I32Ctz    InstructionName = "i32.ctz"
+	I32Popcnt InstructionName = "i32.popcnt"
+	I32Add    InstructionName = "i32.add"
+	I32Sub    InstructionName = "i32.sub"
+	I32Mul    InstructionName = "i32.mul"
+	I32DivS   InstructionName = "i32.div_s"
+	I32DivU   InstructionName = "i32.div_u"
+	I32RemS   InstructionName = "i32.rem_s"
+	I32RemU   InstructionName = "i32.rem_u"
+	I32And    InstructionName = "i32.and"
+	I32Or     InstructionName = "i32.or"
+	I32Xor    InstructionName = "i32.xor"
+	I32Shl    InstructionName = "i32.shl"
+	I32ShrS   InstructionName = "i32.shr_s"
+	I32ShrU   InstructionName = "i32.shr_u"
+	I32Rotl   InstructionName = "i32.rotl"
+	I32Rotr   InstructionName = "i32.rotr"
+	I32Eqz    InstructionName = "i32.eqz"
+	I32Eq     InstructionName = "i32.eq"
+	I32Ne     InstructionName = "i32.ne"
+	I32LtS    InstructionName = "i32.lt_s"
+	I32LtU    InstructionName = "i32.lt_u"
+	I32GtS    InstructionName = "i32.gt_s"
+	I32GtU    InstructionName = "i32.gt_u"
+	I32LeS    InstructionName = "i32.le_s"
+	I32LeU    InstructionName = "i32.le_u"
+	I32GeS    InstructionName = "i32.ge_s"
+	I32GeU    InstructionName = "i32.ge_u"
+
+	I64Const  InstructionName = "i64.const"
+	I64Clz    InstructionName = "i64.clz"
+	I64Ctz    InstructionName = "i64.ctz"
+	I64Popcnt InstructionName = "i64.popcnt"
+	I64Add    InstructionName = "i64.add"
+	I64Sub    InstructionName = "i64.sub"
+	I64Mul    InstructionName = "i64.mul"
+	I64DivS   InstructionName = "i64.div_s"
+	I64DivU   InstructionName = "i64.div_u"
+	I64RemS   InstructionName = "i64.rem_s"
+	I64RemU   InstructionName = "i64.rem_u"
+	I64And    InstructionName = "i64.and"
+	I64Or     InstructionName = "i64.or"
+	I64Xor    InstructionName = "i64.xor"
+	I64Shl    InstructionName = "i64.shl"
+	I64ShrS   InstructionName = "i64.shr_s"
+	I64ShrU   InstructionName = "i64.shr_u"
+	I64Rotl   InstructionName = "i64.rotl"
+	I64Rotr   InstructionName = "i64.rotr"
+	I64Eqz    InstructionName = "i64.eqz"
+	I64Eq     InstructionName = "i64.eq"
+	I64Ne     InstructionName = "i64.ne"
+	I64LtS    InstructionName = "i64.lt_s"
+	I64LtU    InstructionName = "i64.lt_u"
+	I64GtS    InstructionName = "i64.gt_s"
+	I64GtU    InstructionName = "i64.gt_u"
+	I64LeS    InstructionName = "i64.le_s"
+	I64LeU    InstructionName = "i64.le_u"
+	I64GeS    InstructionName = "i64.ge_s"
+	I64GeU    InstructionName = "i64.ge_u"
+
+	F32Const    InstructionName = "f32.const"
+	F32Abs      InstructionName = "f32.abs"
+	F32Neg      InstructionName = "f32.neg"
+	F32Ceil     InstructionName = "f32.ceil"
+	F32Floor    InstructionName = "f32.floor"
+	F32Trunc    InstructionName = "f32.trunc"
+	F32Nearest  InstructionName = "f32.nearest"
+	F32Sqrt     InstructionName = "f32.sqrt"
+	F32Add      InstructionName = "f32.add"
+	F32Sub      InstructionName = "f32.sub"
+	F32Mul      InstructionName = "f32.mul"
+	F32Div      InstructionName = "f32.div"
+	F32Min      InstructionName = "f32.min"
+	F32Max      InstructionName = "f32.max"
+	F32Copysign InstructionName = "f32.copysign"
+	F32Eq       InstructionName = "f32.eq"
+	F32Ne       InstructionName = "f32.ne"
+	F32Lt       InstructionName = "f32.lt"
+	F32Gt       InstructionName = "f32.gt"
+	F32Le       InstructionName = "f32.le"
+	F32Ge       InstructionName = "f32.ge"
+
+	F64Const    InstructionName = "f64.const"
+	F64Abs      InstructionName = "f64.abs"
+	F64Neg      InstructionName = "f64.neg"
+	F64Ceil     InstructionName = "f64.ceil"
+	F64Floor    InstructionName = "f64.floor"
+	F64Trunc    InstructionName = "f64.trunc"
+	F64Nearest  InstructionName = "f64.nearest"
+	F64Sqrt     InstructionName = "f64.sqrt"
+	F64Add      InstructionName = "f64.add"
+	F64Sub      InstructionName = "f64.sub"
+	F64Mul      InstructionName = "f64.mul"
+	F64Div      InstructionName = "f64.div"
+	F64Min      InstructionName = "f64.min"
+	F64Max      InstructionName = "f64.max"
+	F64Copysign InstructionName = "f64.copysign"
+	F64Eq       InstructionName = "f64.eq"
+	F64Ne       InstructionName = "f64.ne"
+	F64Lt       InstructionName = "f64.lt"
+	F64Gt       InstructionName = "f64.gt"
+	F64Le       InstructionName = "f64.le"
+	F64Ge       InstructionName = "f64.ge"
+
+	// Conversion instruction
+	I32WrapI64        InstructionName = "i32.wrap_i64"
+	I32TruncF32S      InstructionName = "i32.trunc_f32_s"
+	I32TruncF32U      InstructionName = "i32.trunc_f32_u"
+	I32TruncF64S      InstructionName = "i32.trunc_f64_s"
+	I32TruncF64U      InstructionName = "i32.trunc_f64_u"
+	I64ExtendI32S     InstructionName = "i64.extend_i32_s"
+	I64ExtendI32U     InstructionName = "i64.extend_i32_u"
+	I64TruncF32S      InstructionName = "i64.trunc_f32_s"
+	I64TruncF32U      InstructionName = "i64.trunc_f32_u"
+	I64TruncF64S      InstructionName = "i64.trunc_f64_s"
+	I64TruncF64U      InstructionName = "i64.trunc_f64_u"
+	F32ConvertI32S    InstructionName = "f32.convert_i32_s"
+	F32ConvertI32U    InstructionName = "f32.convert_i32_u"
+	F32ConvertI64S    InstructionName = "f32.convert_i64_s"
+	F32ConvertI64U    InstructionName = "f32.convert_i64_u"
+	F32DemoteF64      InstructionName = "f32.demote_f64"
+	F64ConvertI32S    InstructionName = "f64.convert_i32_s"
+	F64ConvertI32U    InstructionName = "f64.convert_i32_u"
+	F64ConvertI64S    InstructionName = "f64.convert_i64_s"
+	F64ConvertI64U    InstructionName = "f64.convert_i64_u"
+	F64PromoteF32     InstructionName = "f64.promote_f32"
+	I32ReinterpretF32 InstructionName = "i32.reinterpret_f32"
+	I64ReinterpretF64 InstructionName = "i64.reinterpret_f64"
+	F32ReinterpretI32 InstructionName = "f32.reinterpret_i32"
+	F64ReinterpretI64 InstructionName = "f64.reinterpret_i64"
+	I32Extend8S       InstructionName = "i32.extend8_s"
+	I32Extend16S      InstructionName = "i32.extend16_s"
+	I64Extend8S       InstructionName = "i64.extend8_s"
+	I64Extend16S      InstructionName = "i64.extend16_s"
+	I64Extend32S      InstructionName = "i64.extend32_s"
+	I32TruncSatF32S   InstructionName = "i32.trunc_sat_f32_s"
+	I32TruncSatF32U   InstructionName = "i32.trunc_sat_f32_u"
+	I32TruncSatF64S   InstructionName = "i32.trunc_sat_f64_s"
+	I32TruncSatF64U   InstructionName = "i32.trunc_sat_f64_u"
+	I64TruncSatF32S   InstructionName = "i64.trunc_sat_f32_s"
+	I64TruncSatF32U   InstructionName = "i64.trunc_sat_f32_u"
+	I64TruncSatF64S   InstructionName = "i64.trunc_sat_f64_s"
+	I64TruncSatF64U   InstructionName = "i64.trunc_sat_f64_u"
 
 	// Parametric instruction
 	Drop InstructionName = "drop"
 
+	// Reference Instructions
+	RefNull   InstructionName = "ref.null"
+	RefIsNull InstructionName = "ref.is_null"
+	RefFunc   InstructionName = "ref.func"
+
 	// Variable Instructions
-	LocalGet InstructionName = "local.get"
-	LocalSet InstructionName = "local.set"
-	LocalTee InstructionName = "local.tee"
+	LocalGet  InstructionName = "local.get"
+	LocalSet  InstructionName = "local.set"
+	LocalTee  InstructionName = "local.tee"
+	GlobalGet InstructionName = "global.get"
+	GlobalSet InstructionName = "global.set"
+
+	// Table Instructions
+	TableGet  InstructionName = "table.get"
+	TableSet  InstructionName = "table.set"
+	TableSize InstructionName = "table.size"
+	TableGrow InstructionName = "table.grow"
+	TableFill InstructionName = "table.fill"
+	TableCopy InstructionName = "table.copy"
+	TableInit InstructionName = "table.init"
+	ElemDrop  InstructionName = "elem.drop"
+
+	// Memory instruction
+	I32Load    InstructionName = "i32.load"
+	I64Load    InstructionName = "i64.load"
+	F32Load    InstructionName = "f32.load"
+	F64Load    InstructionName = "f64.load"
+	I32Load8S  InstructionName = "i32.load8_s"
+	I32Load8U  InstructionName = "i32.load8_u"
+	I32Load16S InstructionName = "i32.load16_s"
+	I32Load16U InstructionName = "i32.load16_u"
+	I64Load8S  InstructionName = "i64.load8_s"
+	I64Load8U  InstructionName = "i64.load8_u"
+	I64Load16S InstructionName = "i64.load16_s"
+	I64Load16U InstructionName = "i64.load16_u"
+	I64Load32S InstructionName = "i64.load32_s"
+	I64Load32U InstructionName = "i64.load32_u"
+	I32Store   InstructionName = "i32.store"
+	I64Store   InstructionName = "i64.store"
+	F32Store   InstructionName = "f32.store"
+	F64Store   InstructionName = "f64.store"
+	I32Store8  InstructionName = "i32.store8"
+	I32Store16 InstructionName = "i32.store16"
+	I64Store8  InstructionName = "i64.store8"
+	I64Store16 InstructionName = "i64.store16"
+	I64Store32 InstructionName = "i64.store32"
+	MemorySize InstructionName = "memory.size"
+	MemoryGrow InstructionName = "memory.grow"
+	MemoryInit InstructionName = "memory.init"
+	DataDrop   InstructionName = "data.drop"
 
 	// ControlInstruction
-	Block  InstructionName = "block"
-	Return InstructionName = "return"
-	Call   InstructionName = "call"
+	Unreachable InstructionName = "unreachable"
+	Nop         InstructionName = "nop"
+	Block       InstructionName = "block"
+	Loop        InstructionName = "loop"
+	If          InstructionName = "if"
+	Br          InstructionName = "br"
+	BrIf        InstructionName = "br_if"
+	BrTable     InstructionName = "br_table"
+	Return      InstructionName = "return"
+	Call        InstructionName = "call"
+
+	CallIndirect InstructionName = "call_indirect"
 )
 
 func (name InstructionName) IsValid() bool {
-	return name.IsI32() || name.IsParametric() || name.IsControl()
+	return name.IsI32() || name.IsI64() || name.IsF32() || name.IsF64() || name.IsConversion() || name.IsParametric() || name.IsReference() || name.IsVariable() || name.IsTable() || name.IsMemory() || name.IsControl()
 }
 
 func (name InstructionName) IsI32() bool {
 	switch name {
-	case I32Const, I32Add, I32Sub, I32Mul, I32DivS,
-		I32Eqz, I32Eq, I32Ne, I32LtS, I32GtS, I32LeS, I32GeS:
+	case I32Const, I32Clz, I32Ctz, I32Popcnt,
+		I32Add, I32Sub, I32Mul, I32DivS, I32DivU, I32RemS, I32RemU,
+		I32And, I32Or, I32Xor, I32Shl, I32ShrS, I32ShrU, I32Rotl, I32Rotr,
+		I32Eqz, I32Eq, I32Ne, I32LtS, I32LtU, I32GtS, I32GtU,
+		I32LeS, I32LeU, I32GeS, I32GeU:
+		return true
+	}
+
+	return false
+}
+
+func (name InstructionName) IsI64() bool {
+	switch name {
+	case I64Const, I64Clz, I64Ctz, I64Popcnt,
+		I64Add, I64Sub, I64Mul, I64DivS, I64DivU, I64RemS, I64RemU,
+		I64And, I64Or, I64Xor, I64Shl, I64ShrS, I64ShrU, I64Rotl, I64Rotr,
+		I64Eqz, I64Eq, I64Ne, I64LtS, I64LtU, I64GtS, I64GtU,
+		I64LeS, I64LeU, I64GeS, I64GeU:
+		return true
+	}
+
+	return false
+}
+
+func (name InstructionName) IsF32() bool {
+	switch name {
+	case F32Const,
+		F32Abs, F32Neg, F32Ceil, F32Floor, F32Trunc, F32Nearest, F32Sqrt,
+		F32Add, F32Sub, F32Mul, F32Div, F32Min, F32Max, F32Copysign,
+		F32Eq, F32Ne, F32Lt, F32Gt, F32Le, F32Ge:
+		return true
+	}
+
+	return false
+}
+
+func (name InstructionName) IsF64() bool {
+	switch name {
+	case F64Const,
+		F64Abs, F64Neg, F64Ceil, F64Floor, F64Trunc, F64Nearest, F64Sqrt,
+		F64Add, F64Sub, F64Mul, F64Div, F64Min, F64Max, F64Copysign,
+		F64Eq, F64Ne, F64Lt, F64Gt, F64Le, F64Ge:
+		return true
+	}
+
+	return false
+}
+
+func (name InstructionName) IsConversion() bool {
+	switch name {
+	case I32WrapI64, I32TruncF32S, I32TruncF32U, I32TruncF64S, I32TruncF64U,
+		I64ExtendI32S, I64ExtendI32U, I64TruncF32S, I64TruncF32U, I64TruncF64S, I64TruncF64U,
+		F32ConvertI32S, F32ConvertI32U, F32ConvertI64S, F32ConvertI64U, F32DemoteF64, F64ConvertI32S, F64ConvertI32U, F64ConvertI64S, F64ConvertI64U, F64PromoteF32,
+		I32ReinterpretF32, I64ReinterpretF64, F32ReinterpretI32, F64ReinterpretI64,
+		I32Extend8S, I32Extend16S, I64Extend8S, I64Extend16S, I64Extend32S,
+		I32TruncSatF32S, I32TruncSatF32U, I32TruncSatF64S, I32TruncSatF64U,
+		I64TruncSatF32S, I64TruncSatF32U, I64TruncSatF64S, I64TruncSatF64U:
 		return true
 	}
 
@@ -54,9 +297,41 @@ func (name InstructionName) IsParametric() bool {
 	return false
 }
 
+func (name InstructionName) IsReference() bool {
+	switch name {
+	case RefNull, RefIsNull, RefFunc:
+		return true
+	}
+
+	return false
+}
+
 func (name InstructionName) IsVariable() bool {
 	switch name {
-	case LocalGet, LocalSet, LocalTee:
+	case LocalGet, LocalSet, LocalTee, GlobalGet, GlobalSet:
+		return true
+	}
+
+	return false
+}
+
+func (name InstructionName) IsTable() bool {
+	switch name {
+	case TableGet, TableSet, TableSize, TableGrow, TableFill, TableCopy, TableInit, ElemDrop:
+		return true
+	}
+
+	return false
+}
+
+func (name InstructionName) IsMemory() bool {
+	switch name {
+	case I32Load, I64Load, F32Load, F64Load,
+		I32Load8S, I32Load8U, I32Load16S, I32Load16U,
+		I64Load8S, I64Load8U, I64Load16S, I64Load16U, I64Load32S, I64Load32U,
+		I32Store, I64Store, F32Store, F64Store,
+		I32Store8, I32Store16, I64Store8, I64Store16, I64Store32,
+		MemorySize, MemoryGrow, MemoryInit, DataDrop:
 		return true
 	}
 
@@ -65,7 +340,7 @@ func (name InstructionName) IsVariable() bool {
 
 func (name InstructionName) IsControl() bool {
 	switch name {
-	case Block, Return, Call:
+	case Unreachable, Nop, Block, Loop, If, Br, BrIf, BrTable, Return, Call, CallIndirect:
 		return true
 	}
 
@@ -0,0 +1,46 @@
+package instruction
+
+import "github.com/kechako/wasmexec/mod/types"
+
+type MemoryInstruction struct {
+	Instruction InstructionName
+	// Offset is added to the address operand of a load or a store.
+	Offset uint32
+	// Align is the alignment hint of a load or a store as an exponent of 2,
+	// like the binary format.
+	Align uint32
+	// Data is the data segment of memory.init and data.drop.
+	Data types.Index
+}
+
+func (i *MemoryInstruction) Name() InstructionName {
+	return i.Instruction
+}
+
+// AccessSize returns the number of bytes a load or a store accesses, or 0 for
+// the other instructions.
+func (name InstructionName) AccessSize() uint32 {
+	switch name {
+	case I32Load8S, I32Load8U, I64Load8S, I64Load8U, I32Store8, I64Store8:
+		return 1
+	case I32Load16S, I32Load16U, I64Load16S, I64Load16U, I32Store16, I64Store16:
+		return 2
+	case I32Load, F32Load, I64Load32S, I64Load32U, I32Store, F32Store, I64Store32:
+		return 4
+	case I64Load, F64Load, I64Store, F64Store:
+		return 8
+	}
+
+	return 0
+}
+
+// NaturalAlignment returns the alignment of a load or a store as an exponent
+// of 2, which is the default and the maximum of MemoryInstruction.Align.
+func (name InstructionName) NaturalAlignment() uint32 {
+	var align uint32
+	for size := name.AccessSize(); size > 1; size >>= 1 {
+		align++
+	}
+
+	return align
+}
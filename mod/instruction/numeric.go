@@ -8,3 +8,30 @@ type I32Instruction struct {
 func (i32 *I32Instruction) Name() InstructionName {
 	return i32.Instruction
 }
+
+type I64Instruction struct {
+	Instruction InstructionName
+	Values      []int64
+}
+
+func (i64 *I64Instruction) Name() InstructionName {
+	return i64.Instruction
+}
+
+type F32Instruction struct {
+	Instruction InstructionName
+	Values      []float32
+}
+
+func (f32 *F32Instruction) Name() InstructionName {
+	return f32.Instruction
+}
+
+type F64Instruction struct {
+	Instruction InstructionName
+	Values      []float64
+}
+
+func (f64 *F64Instruction) Name() InstructionName {
+	return f64.Instruction
+}
@@ -0,0 +1,15 @@
+package instruction
+
+import "github.com/kechako/wasmexec/mod/types"
+
+type ReferenceInstruction struct {
+	Instruction InstructionName
+	// Type is the reference type of ref.null.
+	Type types.Type
+	// Function is the function of ref.func.
+	Function types.Index
+}
+
+func (i *ReferenceInstruction) Name() InstructionName {
+	return i.Instruction
+}
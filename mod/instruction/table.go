@@ -0,0 +1,18 @@
+package instruction
+
+import "github.com/kechako/wasmexec/mod/types"
+
+type TableInstruction struct {
+	Instruction InstructionName
+	// Table is the table the instruction operates on, which is the
+	// destination of table.copy and table.init.
+	Table types.Index
+	// Source is the source table of table.copy.
+	Source types.Index
+	// Elem is the element segment of table.init and elem.drop.
+	Elem types.Index
+}
+
+func (i *TableInstruction) Name() InstructionName {
+	return i.Instruction
+}
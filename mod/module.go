@@ -1,25 +1,250 @@
 package mod
 
 import (
+	"math"
+
 	"github.com/kechako/wasmexec/mod/instruction"
 	"github.com/kechako/wasmexec/mod/types"
 )
 
 type Module struct {
 	ID        types.ID
+	Types     []*FuncType
 	Functions []*Function
+	Tables    []*Table
+	Memories  []*Memory
+	Globals   []*Global
 	Exports   []*Export
+	// Start is the function called at instantiation, or nil if the module
+	// has no start function.
+	Start    *types.Index
+	Elements []*Element
+	Data     []*Data
+}
+
+// TypeIndex resolves idx to the position of a type in m.Types.
+func (m *Module) TypeIndex(idx types.Index) (int, bool) {
+	if idx.IsIndex() {
+		if idx.Index < 0 || idx.Index >= len(m.Types) {
+			return 0, false
+		}
+		return idx.Index, true
+	}
+
+	for i, t := range m.Types {
+		if t.ID == idx.ID {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// FunctionIndex resolves idx to the position of a function in m.Functions.
+func (m *Module) FunctionIndex(idx types.Index) (int, bool) {
+	if idx.IsIndex() {
+		if idx.Index < 0 || idx.Index >= len(m.Functions) {
+			return 0, false
+		}
+		return idx.Index, true
+	}
+
+	for i, f := range m.Functions {
+		if f.ID == idx.ID {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// TableIndex resolves idx to the position of a table in m.Tables.
+func (m *Module) TableIndex(idx types.Index) (int, bool) {
+	if idx.IsIndex() {
+		if idx.Index < 0 || idx.Index >= len(m.Tables) {
+			return 0, false
+		}
+		return idx.Index, true
+	}
+
+	for i, t := range m.Tables {
+		if t.ID == idx.ID {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// MemoryIndex resolves idx to the position of a memory in m.Memories.
+func (m *Module) MemoryIndex(idx types.Index) (int, bool) {
+	if idx.IsIndex() {
+		if idx.Index < 0 || idx.Index >= len(m.Memories) {
+			return 0, false
+		}
+		return idx.Index, true
+	}
+
+	for i, mem := range m.Memories {
+		if mem.ID == idx.ID {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// GlobalIndex resolves idx to the position of a global in m.Globals.
+func (m *Module) GlobalIndex(idx types.Index) (int, bool) {
+	if idx.IsIndex() {
+		if idx.Index < 0 || idx.Index >= len(m.Globals) {
+			return 0, false
+		}
+		return idx.Index, true
+	}
+
+	for i, g := range m.Globals {
+		if g.ID == idx.ID {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// ElementIndex resolves idx to the position of an element segment in
+// m.Elements.
+func (m *Module) ElementIndex(idx types.Index) (int, bool) {
+	if idx.IsIndex() {
+		if idx.Index < 0 || idx.Index >= len(m.Elements) {
+			return 0, false
+		}
+		return idx.Index, true
+	}
+
+	for i, e := range m.Elements {
+		if e.ID == idx.ID {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// DataIndex resolves idx to the position of a data segment in m.Data.
+func (m *Module) DataIndex(idx types.Index) (int, bool) {
+	if idx.IsIndex() {
+		if idx.Index < 0 || idx.Index >= len(m.Data) {
+			return 0, false
+		}
+		return idx.Index, true
+	}
+
+	for i, d := range m.Data {
+		if d.ID == idx.ID {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// FuncType is the signature of functions, which is also used by blocks and
+// call_indirect.
+type FuncType struct {
+	ID         types.ID
+	Parameters []types.Type
+	Results    []types.Type
+}
+
+// Equal reports whether t and other have the same parameters and results.
+// The IDs are not compared.
+func (t *FuncType) Equal(other *FuncType) bool {
+	return equalTypes(t.Parameters, other.Parameters) && equalTypes(t.Results, other.Results)
+}
+
+func equalTypes(a, b []types.Type) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newFuncType returns the type of the parameters and the results.
+func newFuncType(params []*Local, results []*Result) *FuncType {
+	t := &FuncType{}
+	for _, p := range params {
+		t.Parameters = append(t.Parameters, p.Type)
+	}
+	for _, r := range results {
+		t.Results = append(t.Results, r.Type)
+	}
+
+	return t
 }
 
 type Function struct {
-	ID           types.ID
-	Parameters   []*Local
-	Results      []*Result
-	Locals       []*Local
+	ID types.ID
+	// Import is the name of an imported function, which has neither locals
+	// nor instructions.
+	Import *Import
+	// Type is the index of the type of the function in Module.Types, which
+	// Parameters and Results match.
+	Type       types.Index
+	Parameters []*Local
+	Results    []*Result
+	Locals     []*Local
+	// Blocks are the blocks of the function in the order the block
+	// instructions appear in the flat form, which the block instructions
+	// refer to by the numbers.
 	Blocks       []*Block
 	Instructions []instruction.Instruction
 }
 
+// Signature returns the type of the parameters and the results.
+func (f *Function) Signature() *FuncType {
+	return newFuncType(f.Parameters, f.Results)
+}
+
+// LocalIndex resolves idx to the index of a local variable, where the
+// parameters come first and the declared locals follow them.
+func (f *Function) LocalIndex(idx types.Index) (int, bool) {
+	if idx.IsIndex() {
+		if idx.Index < 0 || idx.Index >= len(f.Parameters)+len(f.Locals) {
+			return 0, false
+		}
+		return idx.Index, true
+	}
+
+	for i, p := range f.Parameters {
+		if p.ID == idx.ID {
+			return i, true
+		}
+	}
+	for i, l := range f.Locals {
+		if l.ID == idx.ID {
+			return len(f.Parameters) + i, true
+		}
+	}
+
+	return 0, false
+}
+
+// Block returns the n-th block.
+func (f *Function) Block(n int) (*Block, bool) {
+	if n < 0 || n >= len(f.Blocks) {
+		return nil, false
+	}
+
+	return f.Blocks[n], true
+}
+
 type Local struct {
 	ID   types.ID
 	Type types.Type
@@ -32,10 +257,115 @@ type Result struct {
 type BlockResult Result
 
 type Block struct {
+	// Label is the label of the block in the source, which is empty for an
+	// anonymous block and every block of the binary format.
 	Label        types.ID
 	Parameters   []*Local
 	Results      []*Result
 	Instructions []instruction.Instruction
+	// Else is the else branch of an if block, whose then branch is
+	// Instructions.
+	Else []instruction.Instruction
+}
+
+// Signature returns the type of the parameters and the results.
+func (b *Block) Signature() *FuncType {
+	return newFuncType(b.Parameters, b.Results)
+}
+
+type Table struct {
+	ID types.ID
+	// Import is the name of an imported table.
+	Import *Import
+	// Limits is the number of elements of the table.
+	Limits Limits
+	// Type is the reference type of the elements.
+	Type types.Type
+}
+
+// MaxTableSize is the maximum number of elements of a table.
+const MaxTableSize = math.MaxUint32
+
+// PageSize is the size of a page of linear memories in bytes.
+const PageSize = 65536
+
+// MaxPages is the maximum number of pages of a linear memory, which makes a
+// memory of 4 GiB addressable with i32.
+const MaxPages = 65536
+
+type Memory struct {
+	ID types.ID
+	// Import is the name of an imported memory.
+	Import *Import
+	// Limits is the size of the memory in pages.
+	Limits Limits
+}
+
+// Limits is the range of the size of a memory or a table.
+type Limits struct {
+	Min uint32
+	// Max is the maximum size, which is valid only if HasMax is true.
+	Max    uint32
+	HasMax bool
+}
+
+type Global struct {
+	ID types.ID
+	// Import is the name of an imported global, which has no initial value.
+	Import  *Import
+	Type    types.Type
+	Mutable bool
+	// Init is the constant expression of the initial value.
+	Init []instruction.Instruction
+}
+
+type ElementMode int
+
+const (
+	// ElementActive is the mode of an element segment copied into a table
+	// at instantiation.
+	ElementActive ElementMode = iota
+	// ElementPassive is the mode of an element segment copied by
+	// table.init.
+	ElementPassive
+	// ElementDeclarative is the mode of an element segment which only
+	// declares the functions referred by ref.func.
+	ElementDeclarative
+)
+
+type Element struct {
+	ID   types.ID
+	Mode ElementMode
+	// Table is the table an active segment is copied into.
+	Table types.Index
+	// Offset is the constant expression of the offset an active segment is
+	// copied to.
+	Offset []instruction.Instruction
+	// Type is the reference type of the elements.
+	Type types.Type
+	// Init is the constant expressions of the elements.
+	Init [][]instruction.Instruction
+}
+
+type DataMode int
+
+const (
+	// DataActive is the mode of a data segment copied into a memory at
+	// instantiation.
+	DataActive DataMode = iota
+	// DataPassive is the mode of a data segment copied by memory.init.
+	DataPassive
+)
+
+type Data struct {
+	ID   types.ID
+	Mode DataMode
+	// Memory is the memory an active segment is copied into.
+	Memory types.Index
+	// Offset is the constant expression of the offset an active segment is
+	// copied to.
+	Offset []instruction.Instruction
+	Init   []byte
 }
 
 type ExportTarget string
@@ -52,3 +382,10 @@ type Export struct {
 	Target ExportTarget
 	Index  types.Index
 }
+
+// Import is the name of an imported function, table, memory or global. The
+// imports of each kind precede the definitions in the index space.
+type Import struct {
+	Module string
+	Name   string
+}
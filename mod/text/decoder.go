@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/bits"
+	"strings"
 
 	"github.com/kechako/wasmexec/mod"
 	"github.com/kechako/wasmexec/mod/instruction"
@@ -26,12 +29,12 @@ func NewDecoder(r io.Reader) *Decoder {
 func (d *Decoder) Decode() (*mod.Module, error) {
 	node, err := d.p.Parse()
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode wat: %v", err)
+		return nil, fmt.Errorf("failed to decode wat: %w", err)
 	}
 
 	m, err := parseModule(node)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode wat: %v", err)
+		return nil, fmt.Errorf("failed to decode wat: %w", err)
 	}
 
 	return m, nil
@@ -45,6 +48,7 @@ func parseModule(node *sexp.Node) (*mod.Module, error) {
 	}
 
 	m := &mod.Module{}
+	r := &typeResolver{}
 
 	first := true
 	for curr := node.Cdr; curr != nil; curr = curr.Cdr {
@@ -52,7 +56,7 @@ func parseModule(node *sexp.Node) (*mod.Module, error) {
 
 		switch car.Type {
 		case sexp.NodeCell:
-			err := parseModuleField(m, car)
+			err := parseModuleField(m, car, r)
 			if err != nil {
 				return nil, err
 			}
@@ -71,12 +75,23 @@ func parseModule(node *sexp.Node) (*mod.Module, error) {
 		first = false
 	}
 
+	if err := r.resolve(m); err != nil {
+		return nil, err
+	}
+
 	return m, nil
 }
 
-var errUnsupportedField = errors.New("unsupported module field")
+var (
+	errUnsupportedField = errors.New("unsupported module field")
+	errUnknownType      = errors.New("unknown type")
+	errTypeMismatch     = errors.New("inline function type does not match the type")
+	// errImportAfterDefinition is the error of an import after the
+	// definition of a function, a table, a memory or a global.
+	errImportAfterDefinition = errors.New("import after definition")
+)
 
-func parseModuleField(m *mod.Module, node *sexp.Node) error {
+func parseModuleField(m *mod.Module, node *sexp.Node, r *typeResolver) error {
 	car := node.Car
 
 	sym, ok := car.SymbolValue()
@@ -85,19 +100,86 @@ func parseModuleField(m *mod.Module, node *sexp.Node) error {
 	}
 
 	switch sym {
+	case "import":
+		return parseImport(m, node.Cdr, r)
+	case "type":
+		t, err := parseFuncType(node.Cdr)
+		if err != nil {
+			return err
+		}
+		m.Types = append(m.Types, t)
 	case "func":
-		p := &functionParser{}
-		f, err := p.Parse(node.Cdr)
+		p := &functionParser{
+			types: r,
+		}
+		f, err := p.Parse(node.Cdr, nil)
 		if err != nil {
 			return err
 		}
+		if f.Import != nil && hasDefinitions(m) {
+			return errImportAfterDefinition
+		}
 		m.Functions = append(m.Functions, f)
+	case "table":
+		t, elem, err := parseTable(node.Cdr, nil)
+		if err != nil {
+			return err
+		}
+		if t.Import != nil && hasDefinitions(m) {
+			return errImportAfterDefinition
+		}
+		m.Tables = append(m.Tables, t)
+		if elem != nil {
+			// the table is referred by the index, since it may have no ID
+			elem.Table = types.NewIndex(len(m.Tables) - 1)
+			m.Elements = append(m.Elements, elem)
+		}
+	case "memory":
+		mem, err := parseMemory(node.Cdr, nil)
+		if err != nil {
+			return err
+		}
+		if mem.Import != nil && hasDefinitions(m) {
+			return errImportAfterDefinition
+		}
+		m.Memories = append(m.Memories, mem)
+	case "global":
+		g, err := parseGlobal(node.Cdr, nil)
+		if err != nil {
+			return err
+		}
+		if g.Import != nil && hasDefinitions(m) {
+			return errImportAfterDefinition
+		}
+		m.Globals = append(m.Globals, g)
+	case "elem":
+		e, err := parseElement(node.Cdr)
+		if err != nil {
+			return err
+		}
+		m.Elements = append(m.Elements, e)
+	case "data":
+		d, err := parseData(node.Cdr)
+		if err != nil {
+			return err
+		}
+		m.Data = append(m.Data, d)
 	case "export":
 		e, err := parseExport(node.Cdr)
 		if err != nil {
 			return err
 		}
 		m.Exports = append(m.Exports, e)
+	case "start":
+		// a module has at most one start function
+		if m.Start != nil || node.Cdr == nil || node.Cdr.Cdr != nil {
+			return errInvalidModuleFormat
+		}
+		idx, err := parseIndex(node.Cdr)
+		if err != nil {
+			return err
+		}
+		m.Start = &idx
 	default:
 		return errUnsupportedField
 	}
@@ -105,35 +187,164 @@ func parseModuleField(m *mod.Module, node *sexp.Node) error {
 	return nil
 }
 
-type functionParser struct {
-	f *mod.Function
+// parseImport parses an import, whose description is the header of a
+// function, a table, a memory or a global.
+func parseImport(m *mod.Module, node *sexp.Node, r *typeResolver) error {
+	imp, node, err := parseImportName(node)
+	if err != nil {
+		return err
+	}
+	if node == nil || node.Cdr != nil || node.Car.Type != sexp.NodeCell {
+		return errInvalidModuleFormat
+	}
+	if hasDefinitions(m) {
+		return errImportAfterDefinition
+	}
+
+	desc := node.Car
+	kind, _ := desc.Car.SymbolValue()
+	switch kind {
+	case "func":
+		p := &functionParser{
+			types: r,
+		}
+		f, err := p.Parse(desc.Cdr, imp)
+		if err != nil {
+			return err
+		}
+		m.Functions = append(m.Functions, f)
+	case "table":
+		t, _, err := parseTable(desc.Cdr, imp)
+		if err != nil {
+			return err
+		}
+		m.Tables = append(m.Tables, t)
+	case "memory":
+		mem, err := parseMemory(desc.Cdr, imp)
+		if err != nil {
+			return err
+		}
+		m.Memories = append(m.Memories, mem)
+	case "global":
+		g, err := parseGlobal(desc.Cdr, imp)
+		if err != nil {
+			return err
+		}
+		m.Globals = append(m.Globals, g)
+	default:
+		return errInvalidModuleFormat
+	}
+
+	return nil
 }
 
-func (p *functionParser) Parse(node *sexp.Node) (*mod.Function, error) {
-	if node == nil {
-		return nil, errInvalidModuleFormat
+// parseImportName parses the module name and the name of an import, and
+// returns the rest of node.
+func parseImportName(node *sexp.Node) (*mod.Import, *sexp.Node, error) {
+	if node == nil || node.Cdr == nil {
+		return nil, nil, errInvalidModuleFormat
+	}
+	module, ok := node.Car.StringValue()
+	if !ok {
+		return nil, nil, errInvalidModuleFormat
+	}
+	name, ok := node.Cdr.Car.StringValue()
+	if !ok {
+		return nil, nil, errInvalidModuleFormat
+	}
+
+	return &mod.Import{
+		Module: module,
+		Name:   name,
+	}, node.Cdr.Cdr, nil
+}
+
+// parseInlineImport parses the (import "module" "name") clause if node
+// starts with it, and returns the import and the rest of node.
+func parseInlineImport(node *sexp.Node) (*mod.Import, *sexp.Node, error) {
+	if node == nil || !isClause(node.Car, "import") {
+		return nil, node, nil
+	}
+
+	imp, rest, err := parseImportName(node.Car.Cdr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rest != nil {
+		return nil, nil, errInvalidModuleFormat
+	}
+
+	return imp, node.Cdr, nil
+}
+
+// hasDefinitions reports whether the module has a function, a table, a
+// memory or a global which is not imported, after which no import can
+// appear.
+func hasDefinitions(m *mod.Module) bool {
+	for _, f := range m.Functions {
+		if f.Import == nil {
+			return true
+		}
+	}
+	for _, t := range m.Tables {
+		if t.Import == nil {
+			return true
+		}
+	}
+	for _, mem := range m.Memories {
+		if mem.Import == nil {
+			return true
+		}
+	}
+	for _, g := range m.Globals {
+		if g.Import == nil {
+			return true
+		}
 	}
 
+	return false
+}
+
+type functionParser struct {
+	f *mod.Function
+	// types resolves the type uses of the function, which is nil in
+	// constant expressions
+	types *typeResolver
+}
+
+// Parse parses a function after the keyword. An imported function, which is
+// imp or imported inline, has only the type.
+func (p *functionParser) Parse(node *sexp.Node, imp *mod.Import) (*mod.Function, error) {
 	// id (optional)
 	var id types.ID
-	if v, ok := node.Car.SymbolValue(); ok {
+	if v, ok := carSymbol(node); ok && strings.HasPrefix(v, "$") {
 		id = types.ID(v)
 		if !id.IsValid() {
 			return nil, errInvalidModuleFormat
 		}
 
 		node = node.Cdr
-		if node == nil {
-			return nil, errInvalidModuleFormat
+	}
+
+	if imp == nil {
+		var err error
+		imp, node, err = parseInlineImport(node)
+		if err != nil {
+			return nil, err
 		}
 	}
 
 	f := &mod.Function{
-		ID: id,
+		ID:     id,
+		Import: imp,
 	}
 	p.f = f
 
-	curr := node
+	// type (optional)
+	use, curr, err := parseTypeUse(node)
+	if err != nil {
+		return nil, err
+	}
 
 	// parse params
 	for curr != nil && isFunctionParam(curr.Car) {
@@ -177,6 +388,22 @@ func (p *functionParser) Parse(node *sexp.Node) (*mod.Function, error) {
 		curr = curr.Cdr
 	}
 
+	use.sig = f.Signature()
+	use.resolve = func(idx types.Index, t *mod.FuncType) {
+		f.Type = idx
+		if use.inlineEmpty() {
+			f.Parameters, f.Results = typeLocals(t)
+		}
+	}
+	p.types.add(use)
+
+	if imp != nil {
+		if curr != nil {
+			return nil, errInvalidModuleFormat
+		}
+		return f, nil
+	}
+
 	instructions, err := p.parseInstructions(curr)
 	if err != nil {
 		return nil, err
@@ -273,6 +500,8 @@ func parseType(s string) types.Type {
 		return types.F32
 	case "f64":
 		return types.F64
+	case "funcref":
+		return types.FuncRef
 	}
 
 	return types.Unkown
@@ -300,72 +529,188 @@ func (p *functionParser) parseResult(node *sexp.Node) (*mod.Result, error) {
 }
 
 func (p *functionParser) parseInstructions(node *sexp.Node) ([]instruction.Instruction, error) {
+	instructions, _, err := p.parseInstructionsUntil(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return instructions, nil
+}
+
+// parseInstructionsUntil parses instructions until one of the terminator
+// symbols of flat block instructions, and returns the node of the
+// terminator, or nil if it reaches the end of the list.
+func (p *functionParser) parseInstructionsUntil(node *sexp.Node, terminators ...string) ([]instruction.Instruction, *sexp.Node, error) {
 	var instructions []instruction.Instruction
 
 	curr := node
 	for curr != nil {
-		i, next, err := p.parseInstruction(curr)
+		if isSymbol(curr.Car, terminators...) {
+			return instructions, curr, nil
+		}
+
+		is, next, err := p.parseInstruction(curr)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		instructions = append(instructions, i)
+		instructions = append(instructions, is...)
 
 		curr = next
 	}
 
-	return instructions, nil
+	return instructions, nil, nil
+}
+
+// carSymbol returns the symbol at the head of the list.
+func carSymbol(node *sexp.Node) (string, bool) {
+	if node == nil {
+		return "", false
+	}
+
+	return node.Car.SymbolValue()
+}
+
+func isSymbol(node *sexp.Node, symbols ...string) bool {
+	v, ok := node.SymbolValue()
+	if !ok {
+		return false
+	}
+
+	for _, sym := range symbols {
+		if v == sym {
+			return true
+		}
+	}
+
+	return false
 }
 
 var errUnsupportedInstruction = errors.New("unsupported instruction")
 
-func (p *functionParser) parseInstruction(node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
+func (p *functionParser) parseInstruction(node *sexp.Node) ([]instruction.Instruction, *sexp.Node, error) {
 	if node == nil {
 		return nil, nil, errInvalidModuleFormat
 	}
 
 	if node.Car.Type == sexp.NodeCell {
-		i, err := p.parseNestedInstruction(node.Car)
+		is, err := p.parseFoldedInstruction(node.Car)
 		if err != nil {
 			return nil, nil, err
 		}
-		return i, node.Cdr, nil
-	} else {
-		iname, err := getInstructionName(node)
+		return is, node.Cdr, nil
+	}
+
+	iname, err := getInstructionName(node)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch iname {
+	case instruction.Block, instruction.Loop:
+		i, next, err := p.parseBlockInstruction(iname, node.Cdr, false)
 		if err != nil {
 			return nil, nil, err
 		}
+		return []instruction.Instruction{i}, next, nil
+	case instruction.If:
+		return p.parseIfInstruction(iname, node.Cdr, false)
+	}
 
-		i, next, err := p.parseI32Instruction(iname, node.Cdr)
-		if err == nil {
-			return i, next, nil
-		}
-		if err != nil && err != errUnsupportedInstruction {
-			return nil, nil, err
-		}
+	i, next, err := p.parsePlainInstruction(iname, node.Cdr)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		i, next, err = p.parseParametricInstruction(iname, node.Cdr)
-		if err == nil {
-			return i, next, nil
-		}
-		if err != nil && err != errUnsupportedInstruction {
-			return nil, nil, err
-		}
+	return []instruction.Instruction{i}, next, nil
+}
 
-		i, next, err = p.parseVariableInstruction(iname, node.Cdr)
-		if err == nil {
-			return i, next, nil
-		}
-		if err != nil && err != errUnsupportedInstruction {
-			return nil, nil, err
-		}
+func (p *functionParser) parsePlainInstruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
+	i, next, err := p.parseI32Instruction(iname, node)
+	if err == nil {
+		return i, next, nil
+	}
+	if err != nil && err != errUnsupportedInstruction {
+		return nil, nil, err
+	}
 
-		i, next, err = p.parseControlInstruction(iname, node.Cdr)
-		if err == nil {
-			return i, next, nil
-		}
-		if err != nil && err != errUnsupportedInstruction {
-			return nil, nil, err
-		}
+	i, next, err = p.parseI64Instruction(iname, node)
+	if err == nil {
+		return i, next, nil
+	}
+	if err != nil && err != errUnsupportedInstruction {
+		return nil, nil, err
+	}
+
+	i, next, err = p.parseF32Instruction(iname, node)
+	if err == nil {
+		return i, next, nil
+	}
+	if err != nil && err != errUnsupportedInstruction {
+		return nil, nil, err
+	}
+
+	i, next, err = p.parseF64Instruction(iname, node)
+	if err == nil {
+		return i, next, nil
+	}
+	if err != nil && err != errUnsupportedInstruction {
+		return nil, nil, err
+	}
+
+	i, next, err = p.parseConversionInstruction(iname, node)
+	if err == nil {
+		return i, next, nil
+	}
+	if err != nil && err != errUnsupportedInstruction {
+		return nil, nil, err
+	}
+
+	i, next, err = p.parseParametricInstruction(iname, node)
+	if err == nil {
+		return i, next, nil
+	}
+	if err != nil && err != errUnsupportedInstruction {
+		return nil, nil, err
+	}
+
+	i, next, err = p.parseReferenceInstruction(iname, node)
+	if err == nil {
+		return i, next, nil
+	}
+	if err != nil && err != errUnsupportedInstruction {
+		return nil, nil, err
+	}
+
+	i, next, err = p.parseVariableInstruction(iname, node)
+	if err == nil {
+		return i, next, nil
+	}
+	if err != nil && err != errUnsupportedInstruction {
+		return nil, nil, err
+	}
+
+	i, next, err = p.parseTableInstruction(iname, node)
+	if err == nil {
+		return i, next, nil
+	}
+	if err != nil && err != errUnsupportedInstruction {
+		return nil, nil, err
+	}
+
+	i, next, err = p.parseMemoryInstruction(iname, node)
+	if err == nil {
+		return i, next, nil
+	}
+	if err != nil && err != errUnsupportedInstruction {
+		return nil, nil, err
+	}
+
+	i, next, err = p.parseControlInstruction(iname, node)
+	if err == nil {
+		return i, next, nil
+	}
+	if err != nil && err != errUnsupportedInstruction {
+		return nil, nil, err
 	}
 
 	return nil, nil, errUnsupportedInstruction
@@ -391,8 +736,11 @@ func (p *functionParser) parseI32Instruction(iname instruction.InstructionName,
 
 	switch iname {
 	case instruction.I32Const:
+		if node == nil {
+			return nil, nil, errInvalidModuleFormat
+		}
 		n, ok := node.Car.IntValue()
-		if !ok {
+		if !ok || n < math.MinInt32 || n > math.MaxUint32 {
 			return nil, nil, errInvalidModuleFormat
 		}
 		return &instruction.I32Instruction{
@@ -406,40 +754,251 @@ func (p *functionParser) parseI32Instruction(iname instruction.InstructionName,
 	}, node, nil
 }
 
-func (p *functionParser) parseParametricInstruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
-	if !iname.IsParametric() {
+func (p *functionParser) parseI64Instruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
+	if !iname.IsI64() {
 		return nil, nil, errUnsupportedInstruction
 	}
 
-	return &instruction.ParametricInstruction{
+	switch iname {
+	case instruction.I64Const:
+		if node == nil {
+			return nil, nil, errInvalidModuleFormat
+		}
+		n, ok := node.Car.IntValue()
+		if !ok {
+			return nil, nil, errInvalidModuleFormat
+		}
+		return &instruction.I64Instruction{
+			Instruction: iname,
+			Values:      []int64{n},
+		}, node.Cdr, nil
+	}
+
+	return &instruction.I64Instruction{
 		Instruction: iname,
 	}, node, nil
 }
 
-func (p *functionParser) parseVariableInstruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
-	if !iname.IsVariable() {
+func (p *functionParser) parseF32Instruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
+	if !iname.IsF32() {
 		return nil, nil, errUnsupportedInstruction
 	}
 
-	index, err := parseIndex(node)
-	if err != nil {
-		return nil, nil, err
+	switch iname {
+	case instruction.F32Const:
+		if node == nil {
+			return nil, nil, errInvalidModuleFormat
+		}
+		f, ok := node.Car.Float32Value()
+		if !ok {
+			return nil, nil, errInvalidModuleFormat
+		}
+		return &instruction.F32Instruction{
+			Instruction: iname,
+			Values:      []float32{f},
+		}, node.Cdr, nil
 	}
-	return &instruction.VariableInstruction{
+
+	return &instruction.F32Instruction{
 		Instruction: iname,
-		Index:       index,
-	}, node.Cdr, nil
+	}, node, nil
 }
 
-func (p *functionParser) parseControlInstruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
-	if !iname.IsControl() {
+func (p *functionParser) parseF64Instruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
+	if !iname.IsF64() {
 		return nil, nil, errUnsupportedInstruction
 	}
 
 	switch iname {
-	case instruction.Block:
-	case instruction.Call:
-		index, err := parseIndex(node)
+	case instruction.F64Const:
+		if node == nil {
+			return nil, nil, errInvalidModuleFormat
+		}
+		f, ok := node.Car.Float64Value()
+		if !ok {
+			return nil, nil, errInvalidModuleFormat
+		}
+		return &instruction.F64Instruction{
+			Instruction: iname,
+			Values:      []float64{f},
+		}, node.Cdr, nil
+	}
+
+	return &instruction.F64Instruction{
+		Instruction: iname,
+	}, node, nil
+}
+
+func (p *functionParser) parseConversionInstruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
+	if !iname.IsConversion() {
+		return nil, nil, errUnsupportedInstruction
+	}
+
+	return &instruction.ConversionInstruction{
+		Instruction: iname,
+	}, node, nil
+}
+
+func (p *functionParser) parseParametricInstruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
+	if !iname.IsParametric() {
+		return nil, nil, errUnsupportedInstruction
+	}
+
+	return &instruction.ParametricInstruction{
+		Instruction: iname,
+	}, node, nil
+}
+
+func (p *functionParser) parseReferenceInstruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
+	if !iname.IsReference() {
+		return nil, nil, errUnsupportedInstruction
+	}
+
+	i := &instruction.ReferenceInstruction{
+		Instruction: iname,
+	}
+	switch iname {
+	case instruction.RefNull:
+		// the heap type
+		if v, ok := carSymbol(node); !ok || v != "func" {
+			return nil, nil, errInvalidModuleFormat
+		}
+		i.Type = types.FuncRef
+		return i, node.Cdr, nil
+	case instruction.RefFunc:
+		index, err := parseIndex(node)
+		if err != nil {
+			return nil, nil, err
+		}
+		i.Function = index
+		return i, node.Cdr, nil
+	}
+
+	return i, node, nil
+}
+
+func (p *functionParser) parseVariableInstruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
+	if !iname.IsVariable() {
+		return nil, nil, errUnsupportedInstruction
+	}
+
+	index, err := parseIndex(node)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &instruction.VariableInstruction{
+		Instruction: iname,
+		Index:       index,
+	}, node.Cdr, nil
+}
+
+func (p *functionParser) parseTableInstruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
+	if !iname.IsTable() {
+		return nil, nil, errUnsupportedInstruction
+	}
+
+	// the table indices are optional, and default to 0
+	var indices []types.Index
+	for ; isIndex(node); node = node.Cdr {
+		index, err := parseIndex(node)
+		if err != nil {
+			return nil, nil, err
+		}
+		indices = append(indices, index)
+	}
+
+	i := &instruction.TableInstruction{
+		Instruction: iname,
+	}
+	switch iname {
+	case instruction.TableCopy:
+		switch len(indices) {
+		case 0:
+			i.Table, i.Source = types.NewIndex(0), types.NewIndex(0)
+		case 2:
+			i.Table, i.Source = indices[0], indices[1]
+		default:
+			return nil, nil, errInvalidModuleFormat
+		}
+	case instruction.TableInit:
+		switch len(indices) {
+		case 1:
+			i.Table, i.Elem = types.NewIndex(0), indices[0]
+		case 2:
+			i.Table, i.Elem = indices[0], indices[1]
+		default:
+			return nil, nil, errInvalidModuleFormat
+		}
+	case instruction.ElemDrop:
+		if len(indices) != 1 {
+			return nil, nil, errInvalidModuleFormat
+		}
+		i.Elem = indices[0]
+	default:
+		switch len(indices) {
+		case 0:
+			i.Table = types.NewIndex(0)
+		case 1:
+			i.Table = indices[0]
+		default:
+			return nil, nil, errInvalidModuleFormat
+		}
+	}
+
+	return i, node, nil
+}
+
+func (p *functionParser) parseMemoryInstruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
+	if !iname.IsMemory() {
+		return nil, nil, errUnsupportedInstruction
+	}
+
+	i := &instruction.MemoryInstruction{
+		Instruction: iname,
+	}
+	switch iname {
+	case instruction.MemorySize, instruction.MemoryGrow:
+		return i, node, nil
+	case instruction.MemoryInit, instruction.DataDrop:
+		index, err := parseIndex(node)
+		if err != nil {
+			return nil, nil, err
+		}
+		i.Data = index
+		return i, node.Cdr, nil
+	}
+
+	// memarg
+	i.Align = iname.NaturalAlignment()
+	if v, ok := carSymbol(node); ok && strings.HasPrefix(v, "offset=") {
+		n, ok := parseU32(strings.TrimPrefix(v, "offset="))
+		if !ok {
+			return nil, nil, errInvalidModuleFormat
+		}
+		i.Offset = n
+		node = node.Cdr
+	}
+	if v, ok := carSymbol(node); ok && strings.HasPrefix(v, "align=") {
+		n, ok := parseU32(strings.TrimPrefix(v, "align="))
+		// the alignment is written in bytes, which is a power of 2
+		if !ok || n == 0 || n&(n-1) != 0 {
+			return nil, nil, errInvalidModuleFormat
+		}
+		i.Align = uint32(bits.TrailingZeros32(n))
+		node = node.Cdr
+	}
+
+	return i, node, nil
+}
+
+func (p *functionParser) parseControlInstruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
+	if !iname.IsControl() {
+		return nil, nil, errUnsupportedInstruction
+	}
+
+	switch iname {
+	case instruction.Call:
+		index, err := parseIndex(node)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -447,134 +1006,977 @@ func (p *functionParser) parseControlInstruction(iname instruction.InstructionNa
 			Instruction: iname,
 			Index:       index,
 		}, node.Cdr, nil
+	case instruction.CallIndirect:
+		return p.parseCallIndirectInstruction(iname, node)
+	case instruction.Br, instruction.BrIf:
+		label, err := parseIndex(node)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &instruction.BranchInstruction{
+			Instruction: iname,
+			Labels:      []types.Index{label},
+		}, node.Cdr, nil
+	case instruction.BrTable:
+		var labels []types.Index
+		for ; isIndex(node); node = node.Cdr {
+			label, err := parseIndex(node)
+			if err != nil {
+				return nil, nil, err
+			}
+			labels = append(labels, label)
+		}
+		if len(labels) == 0 {
+			return nil, nil, errInvalidModuleFormat
+		}
+		return &instruction.BranchInstruction{
+			Instruction: iname,
+			Labels:      labels,
+		}, node, nil
+	}
+
+	return &instruction.ControlInstruction{
+		Instruction: iname,
+	}, node, nil
+}
+
+// parseCallIndirectInstruction parses the optional table and the type use of
+// call_indirect. The parameters cannot have IDs.
+func (p *functionParser) parseCallIndirectInstruction(iname instruction.InstructionName, node *sexp.Node) (instruction.Instruction, *sexp.Node, error) {
+	i := &instruction.CallIndirectInstruction{
+		Instruction: iname,
+		Table:       types.NewIndex(0),
+	}
+	if isIndex(node) {
+		index, err := parseIndex(node)
+		if err != nil {
+			return nil, nil, err
+		}
+		i.Table = index
+		node = node.Cdr
+	}
+
+	use, node, err := parseTypeUse(node)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sig := &mod.FuncType{}
+	for ; node != nil && isFunctionParam(node.Car); node = node.Cdr {
+		typs, err := parseTypes(node.Car.Cdr)
+		if err != nil {
+			return nil, nil, err
+		}
+		sig.Parameters = append(sig.Parameters, typs...)
+	}
+	for ; node != nil && isFunctionResult(node.Car); node = node.Cdr {
+		typs, err := parseTypes(node.Car.Cdr)
+		if err != nil {
+			return nil, nil, err
+		}
+		sig.Results = append(sig.Results, typs...)
+	}
+
+	use.sig = sig
+	use.resolve = func(idx types.Index, t *mod.FuncType) {
+		i.Type = idx
+	}
+	p.types.add(use)
+
+	return i, node, nil
+}
+
+// parseFoldedInstruction parses an instruction written as an S-expression.
+// The operands of a folded plain instruction are executed before it.
+func (p *functionParser) parseFoldedInstruction(node *sexp.Node) ([]instruction.Instruction, error) {
+	iname, err := getInstructionName(node)
+	if err != nil {
+		return nil, err
+	}
+
+	switch iname {
+	case instruction.Block, instruction.Loop:
+		i, _, err := p.parseBlockInstruction(iname, node.Cdr, true)
+		if err != nil {
+			return nil, err
+		}
+		return []instruction.Instruction{i}, nil
+	case instruction.If:
+		instructions, _, err := p.parseIfInstruction(iname, node.Cdr, true)
+		return instructions, err
+	}
+
+	i, next, err := p.parsePlainInstruction(iname, node.Cdr)
+	if err != nil {
+		return nil, err
+	}
+
+	var instructions []instruction.Instruction
+	for curr := next; curr != nil; curr = curr.Cdr {
+		if curr.Car.Type != sexp.NodeCell {
+			return nil, errInvalidModuleFormat
+		}
+
+		operands, err := p.parseFoldedInstruction(curr.Car)
+		if err != nil {
+			return nil, err
+		}
+		instructions = append(instructions, operands...)
+	}
+
+	return append(instructions, i), nil
+}
+
+// parseBlockInstruction parses a block instruction after its name. A folded
+// block spans to the end of the list, and a flat block is terminated by end.
+func (p *functionParser) parseBlockInstruction(iname instruction.InstructionName, node *sexp.Node, folded bool) (instruction.Instruction, *sexp.Node, error) {
+	block, label, curr, err := p.parseBlockHeader(node)
+	if err != nil {
+		return nil, nil, err
+	}
+	n := p.numberBlock(block)
+
+	var next *sexp.Node
+	if folded {
+		instructions, err := p.parseInstructions(curr)
+		if err != nil {
+			return nil, nil, err
+		}
+		block.Instructions = instructions
+	} else {
+		instructions, end, err := p.parseInstructionsUntil(curr, "end")
+		if err != nil {
+			return nil, nil, err
+		}
+		if end == nil {
+			return nil, nil, errInvalidModuleFormat
+		}
+		block.Instructions = instructions
+
+		next, err = parseEndLabel(end.Cdr, label)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &instruction.BlockInstruction{
+		Instruction: iname,
+		Block:       n,
+	}, next, nil
+}
+
+// parseIfInstruction parses an if instruction. The folded form can have the
+// condition operands before the then branch, which are returned followed by
+// the if instruction.
+func (p *functionParser) parseIfInstruction(iname instruction.InstructionName, node *sexp.Node, folded bool) ([]instruction.Instruction, *sexp.Node, error) {
+	block, label, curr, err := p.parseBlockHeader(node)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var instructions []instruction.Instruction
+	var next *sexp.Node
+	var n int
+	if folded {
+		// the operands precede the if instruction, so their blocks are
+		// numbered first
+		for ; curr != nil && !isClause(curr.Car, "then"); curr = curr.Cdr {
+			if curr.Car.Type != sexp.NodeCell {
+				return nil, nil, errInvalidModuleFormat
+			}
+			operands, err := p.parseFoldedInstruction(curr.Car)
+			if err != nil {
+				return nil, nil, err
+			}
+			instructions = append(instructions, operands...)
+		}
+		if curr == nil {
+			return nil, nil, errInvalidModuleFormat
+		}
+		n = p.numberBlock(block)
+
+		block.Instructions, err = p.parseInstructions(curr.Car.Cdr)
+		if err != nil {
+			return nil, nil, err
+		}
+		curr = curr.Cdr
+
+		if curr != nil && isClause(curr.Car, "else") {
+			block.Else, err = p.parseInstructions(curr.Car.Cdr)
+			if err != nil {
+				return nil, nil, err
+			}
+			curr = curr.Cdr
+		}
+		if curr != nil {
+			return nil, nil, errInvalidModuleFormat
+		}
+	} else {
+		n = p.numberBlock(block)
+
+		then, end, err := p.parseInstructionsUntil(curr, "else", "end")
+		if err != nil {
+			return nil, nil, err
+		}
+		if end == nil {
+			return nil, nil, errInvalidModuleFormat
+		}
+		block.Instructions = then
+
+		if isSymbol(end.Car, "else") {
+			curr, err = parseEndLabel(end.Cdr, label)
+			if err != nil {
+				return nil, nil, err
+			}
+			block.Else, end, err = p.parseInstructionsUntil(curr, "end")
+			if err != nil {
+				return nil, nil, err
+			}
+			if end == nil {
+				return nil, nil, errInvalidModuleFormat
+			}
+		}
+
+		next, err = parseEndLabel(end.Cdr, label)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return append(instructions, &instruction.BlockInstruction{
+		Instruction: iname,
+		Block:       n,
+	}), next, nil
+}
+
+// parseBlockHeader parses the label and the block type of a block, and
+// returns the block, the label as written, and the rest of node.
+func (p *functionParser) parseBlockHeader(node *sexp.Node) (*mod.Block, types.ID, *sexp.Node, error) {
+	// label (optional)
+	var label types.ID
+	if v, ok := carSymbol(node); ok && strings.HasPrefix(v, "$") {
+		label = types.ID(v)
+		if !label.IsValid() {
+			return nil, "", nil, errInvalidModuleFormat
+		}
+
+		node = node.Cdr
+	}
+
+	block := &mod.Block{
+		Label: label,
+	}
+
+	// type (optional)
+	use, curr, err := parseTypeUse(node)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	// parse params
+	for curr != nil && isFunctionParam(curr.Car) {
+		car := curr.Car
+
+		p, err := p.parseBlockParam(car.Cdr)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		block.Parameters = append(block.Parameters, p)
+
+		curr = curr.Cdr
+	}
+
+	// parse results
+	for curr != nil && isFunctionResult(curr.Car) {
+		car := curr.Car
+
+		r, err := p.parseBlockResult(car.Cdr)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		block.Results = append(block.Results, r)
+
+		curr = curr.Cdr
+	}
+
+	use.sig = block.Signature()
+	use.block = true
+	use.resolve = func(idx types.Index, t *mod.FuncType) {
+		if use.inlineEmpty() {
+			block.Parameters, block.Results = typeLocals(t)
+		}
+	}
+	p.types.add(use)
+
+	return block, label, curr, nil
+}
+
+// typeUse is the type of a function, a block or call_indirect, which is
+// resolved after the whole module is parsed because a type can be defined
+// after its uses.
+type typeUse struct {
+	// index of the type, which is valid only if explicit is true
+	index    types.Index
+	explicit bool
+	// sig is the inline parameters and results.
+	sig *mod.FuncType
+	// block reports whether the type is of a block, which does not need a
+	// type unless it has parameters or multiple results.
+	block bool
+	// resolve is called with the index of the type and the type.
+	resolve func(idx types.Index, t *mod.FuncType)
+}
+
+// inlineEmpty reports whether the type use has neither parameters nor
+// results written inline, which are then given by the type.
+func (use *typeUse) inlineEmpty() bool {
+	return len(use.sig.Parameters) == 0 && len(use.sig.Results) == 0
+}
+
+// parseTypeUse parses the (type x) clause if node starts with it, and
+// returns the type use and the rest of node.
+func parseTypeUse(node *sexp.Node) (*typeUse, *sexp.Node, error) {
+	use := &typeUse{}
+	if node == nil || !isClause(node.Car, "type") {
+		return use, node, nil
+	}
+
+	clause := node.Car.Cdr
+	if clause == nil || clause.Cdr != nil {
+		return nil, nil, errInvalidModuleFormat
+	}
+	index, err := parseIndex(clause)
+	if err != nil {
+		return nil, nil, err
+	}
+	use.index = index
+	use.explicit = true
+
+	return use, node.Cdr, nil
+}
+
+// typeLocals returns the parameters and the results of t.
+func typeLocals(t *mod.FuncType) ([]*mod.Local, []*mod.Result) {
+	var params []*mod.Local
+	for _, typ := range t.Parameters {
+		params = append(params, &mod.Local{Type: typ})
+	}
+	var results []*mod.Result
+	for _, typ := range t.Results {
+		results = append(results, &mod.Result{Type: typ})
+	}
+
+	return params, results
+}
+
+// typeResolver resolves the type uses of a module.
+type typeResolver struct {
+	uses []*typeUse
+}
+
+// add adds the type use to resolve. The type uses in constant expressions,
+// where r is nil, are not resolved, since they are invalid anyway.
+func (r *typeResolver) add(use *typeUse) {
+	if r == nil {
+		return
+	}
+	r.uses = append(r.uses, use)
+}
+
+// resolve resolves the type uses in order. A type use without the type
+// refers to the first type of the same signature, which is inserted at the
+// end of m.Types if there is no such type.
+func (r *typeResolver) resolve(m *mod.Module) error {
+	for _, use := range r.uses {
+		if use.explicit {
+			n, ok := m.TypeIndex(use.index)
+			if !ok {
+				return fmt.Errorf("%w %s", errUnknownType, formatIndex(use.index))
+			}
+			t := m.Types[n]
+			if !use.inlineEmpty() && !use.sig.Equal(t) {
+				return errTypeMismatch
+			}
+			use.resolve(use.index, t)
+			continue
+		}
+
+		if use.block && len(use.sig.Parameters) == 0 && len(use.sig.Results) <= 1 {
+			continue
+		}
+
+		n := -1
+		for i, t := range m.Types {
+			if t.Equal(use.sig) {
+				n = i
+				break
+			}
+		}
+		if n < 0 {
+			m.Types = append(m.Types, use.sig)
+			n = len(m.Types) - 1
+		}
+		use.resolve(types.NewIndex(n), m.Types[n])
+	}
+
+	return nil
+}
+
+// parseFuncType parses a type definition, which is a function type with an
+// optional ID. The parameters can have IDs, which are not used.
+func parseFuncType(node *sexp.Node) (*mod.FuncType, error) {
+	t := &mod.FuncType{}
+
+	// id (optional)
+	if v, ok := carSymbol(node); ok && strings.HasPrefix(v, "$") {
+		t.ID = types.ID(v)
+		if !t.ID.IsValid() {
+			return nil, errInvalidModuleFormat
+		}
+		node = node.Cdr
+	}
+
+	if node == nil || node.Cdr != nil || !isClause(node.Car, "func") {
+		return nil, errInvalidModuleFormat
+	}
+
+	curr := node.Car.Cdr
+	for ; curr != nil && isFunctionParam(curr.Car); curr = curr.Cdr {
+		params := curr.Car.Cdr
+		if v, ok := carSymbol(params); ok && strings.HasPrefix(v, "$") {
+			// a parameter with an ID has a single type
+			if params.Cdr == nil || params.Cdr.Cdr != nil {
+				return nil, errInvalidModuleFormat
+			}
+			params = params.Cdr
+		}
+		typs, err := parseTypes(params)
+		if err != nil {
+			return nil, err
+		}
+		t.Parameters = append(t.Parameters, typs...)
+	}
+	for ; curr != nil && isFunctionResult(curr.Car); curr = curr.Cdr {
+		typs, err := parseTypes(curr.Car.Cdr)
+		if err != nil {
+			return nil, err
+		}
+		t.Results = append(t.Results, typs...)
+	}
+	if curr != nil {
+		return nil, errInvalidModuleFormat
+	}
+
+	return t, nil
+}
+
+// parseTypes parses the value types of node.
+func parseTypes(node *sexp.Node) ([]types.Type, error) {
+	var typs []types.Type
+	for ; node != nil; node = node.Cdr {
+		v, _ := node.Car.SymbolValue()
+		typ := parseType(v)
+		if typ == types.Unkown {
+			return nil, errInvalidModuleFormat
+		}
+		typs = append(typs, typ)
+	}
+
+	return typs, nil
+}
+
+// numberBlock adds the block to the function in the order the block
+// instructions appear in the flat form, and returns the number of the block.
+// The blocks are told apart by the numbers, since the labels can be omitted
+// or reused.
+func (p *functionParser) numberBlock(block *mod.Block) int {
+	p.f.Blocks = append(p.f.Blocks, block)
+
+	return len(p.f.Blocks) - 1
+}
+
+// isClause reports whether node is a list starting with the keyword.
+func isClause(node *sexp.Node, keyword string) bool {
+	if node == nil || node.Type != sexp.NodeCell {
+		return false
+	}
+
+	return isSymbol(node.Car, keyword)
+}
+
+func parseEndLabel(node *sexp.Node, label types.ID) (*sexp.Node, error) {
+	if v, ok := carSymbol(node); ok && strings.HasPrefix(v, "$") {
+		if types.ID(v) != label {
+			return nil, errInvalidModuleFormat
+		}
+		return node.Cdr, nil
+	}
+
+	return node, nil
+}
+
+func (p *functionParser) parseBlockParam(node *sexp.Node) (*mod.Local, error) {
+	if node == nil {
+		return nil, errInvalidModuleFormat
+	}
+
+	// type
+	v, ok := node.Car.SymbolValue()
+	if !ok {
+		return nil, errInvalidModuleFormat
+	}
+
+	typ := parseType(v)
+	if typ == types.Unkown {
+		return nil, errInvalidModuleFormat
+	}
+
+	return &mod.Local{
+		Type: typ,
+	}, nil
+}
+
+func (p *functionParser) parseBlockResult(node *sexp.Node) (*mod.Result, error) {
+	if node == nil {
+		return nil, errInvalidModuleFormat
 	}
 
-	return &instruction.ControlInstruction{
-		Instruction: iname,
-	}, node, nil
+	// type
+	v, ok := node.Car.SymbolValue()
+	if !ok {
+		return nil, errInvalidModuleFormat
+	}
+
+	typ := parseType(v)
+	if typ == types.Unkown {
+		return nil, errInvalidModuleFormat
+	}
+
+	return &mod.Result{
+		Type: typ,
+	}, nil
 }
 
-func (p *functionParser) parseNestedInstruction(node *sexp.Node) (instruction.Instruction, error) {
-	iname, err := getInstructionName(node)
+func parseMemory(node *sexp.Node, imp *mod.Import) (*mod.Memory, error) {
+	// id (optional)
+	var id types.ID
+	if v, ok := carSymbol(node); ok && strings.HasPrefix(v, "$") {
+		id = types.ID(v)
+		if !id.IsValid() {
+			return nil, errInvalidModuleFormat
+		}
+
+		node = node.Cdr
+	}
+
+	if imp == nil {
+		var err error
+		imp, node, err = parseInlineImport(node)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	limits, rest, err := parseLimits(node)
 	if err != nil {
 		return nil, err
 	}
-
-	switch iname {
-	case instruction.Block:
-		return p.parseBlockInstruction(node.Cdr)
+	if rest != nil {
+		return nil, errInvalidModuleFormat
 	}
 
-	return nil, errUnsupportedInstruction
+	return &mod.Memory{
+		ID:     id,
+		Import: imp,
+		Limits: limits,
+	}, nil
 }
 
-func (p *functionParser) parseBlockInstruction(node *sexp.Node) (instruction.Instruction, error) {
+// parseLimits parses the minimum and the optional maximum, and returns the
+// rest of the list.
+func parseLimits(node *sexp.Node) (mod.Limits, *sexp.Node, error) {
+	var limits mod.Limits
 	if node == nil {
-		return nil, errInvalidModuleFormat
+		return limits, nil, errInvalidModuleFormat
 	}
 
-	// label
-	var label types.ID
-	if v, ok := node.Car.SymbolValue(); ok {
-		label = types.ID(v)
-		if !label.IsValid() {
-			return nil, errInvalidModuleFormat
+	min, ok := intU32(node.Car)
+	if !ok {
+		return limits, nil, errInvalidModuleFormat
+	}
+	limits.Min = min
+	node = node.Cdr
+
+	if node != nil {
+		if max, ok := intU32(node.Car); ok {
+			limits.Max = max
+			limits.HasMax = true
+			node = node.Cdr
 		}
+	}
 
-		node = node.Cdr
-		if node == nil {
-			return nil, errInvalidModuleFormat
+	return limits, node, nil
+}
+
+// intU32 returns the value of an integer node in the range of u32.
+func intU32(node *sexp.Node) (uint32, bool) {
+	n, ok := node.IntValue()
+	if !ok || n < 0 || n > math.MaxUint32 {
+		return 0, false
+	}
+
+	return uint32(n), true
+}
+
+func parseU32(s string) (uint32, bool) {
+	n, ok := sexp.ParseInt(s)
+	if !ok || n < 0 || n > math.MaxUint32 || strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		return 0, false
+	}
+
+	return uint32(n), true
+}
+
+// parseTable parses a table, which may have the elements inline. The
+// element segment of the inline elements is returned to be added to the
+// module.
+func parseTable(node *sexp.Node, imp *mod.Import) (*mod.Table, *mod.Element, error) {
+	// id (optional)
+	var id types.ID
+	if v, ok := carSymbol(node); ok && strings.HasPrefix(v, "$") {
+		id = types.ID(v)
+		if !id.IsValid() {
+			return nil, nil, errInvalidModuleFormat
 		}
+
+		node = node.Cdr
 	}
 
-	block := &mod.Block{
-		Label: label,
+	if imp == nil {
+		var err error
+		imp, node, err = parseInlineImport(node)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	curr := node
+	t := &mod.Table{
+		ID:     id,
+		Import: imp,
+	}
 
-	// parse params
-	for curr != nil && isFunctionParam(curr.Car) {
-		car := curr.Car
+	// reftype (elem ...) is the abbreviation of a table of the size of the
+	// elements and an active segment at offset 0, which an imported table
+	// cannot have
+	if v, ok := carSymbol(node); ok && imp == nil {
+		t.Type = parseType(v)
+		if !t.Type.IsReference() || node.Cdr == nil || !isClause(node.Cdr.Car, "elem") || node.Cdr.Cdr != nil {
+			return nil, nil, errInvalidModuleFormat
+		}
 
-		p, err := p.parseBlockParam(car.Cdr)
+		e := &mod.Element{
+			Mode: mod.ElementActive,
+			Offset: []instruction.Instruction{
+				&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+			},
+			Type: t.Type,
+		}
+		var err error
+		if isIndex(node.Cdr.Car.Cdr) {
+			e.Init, err = parseFuncIndices(node.Cdr.Car.Cdr)
+		} else {
+			e.Init, err = parseElementExprs(node.Cdr.Car.Cdr)
+		}
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		block.Parameters = append(block.Parameters, p)
+		n := uint32(len(e.Init))
+		t.Limits = mod.Limits{Min: n, Max: n, HasMax: true}
 
-		curr = curr.Cdr
+		return t, e, nil
 	}
 
-	// parse results
-	for curr != nil && isFunctionResult(curr.Car) {
-		car := curr.Car
+	limits, rest, err := parseLimits(node)
+	if err != nil {
+		return nil, nil, err
+	}
+	t.Limits = limits
 
-		r, err := p.parseBlockResult(car.Cdr)
+	v, ok := carSymbol(rest)
+	if !ok || rest.Cdr != nil {
+		return nil, nil, errInvalidModuleFormat
+	}
+	t.Type = parseType(v)
+	if !t.Type.IsReference() {
+		return nil, nil, errInvalidModuleFormat
+	}
+
+	return t, nil, nil
+}
+
+func parseElement(node *sexp.Node) (*mod.Element, error) {
+	// id (optional)
+	var id types.ID
+	if v, ok := carSymbol(node); ok && strings.HasPrefix(v, "$") {
+		id = types.ID(v)
+		if !id.IsValid() {
+			return nil, errInvalidModuleFormat
+		}
+
+		node = node.Cdr
+	}
+
+	e := &mod.Element{
+		ID:   id,
+		Mode: mod.ElementPassive,
+	}
+
+	if v, ok := carSymbol(node); ok && v == "declare" {
+		e.Mode = mod.ElementDeclarative
+		node = node.Cdr
+	}
+
+	// table (optional)
+	hasTable := false
+	if node != nil && isClause(node.Car, "table") {
+		index, err := parseIndex(node.Car.Cdr)
 		if err != nil {
 			return nil, err
 		}
+		if node.Car.Cdr.Cdr != nil {
+			return nil, errInvalidModuleFormat
+		}
+		e.Table = index
+		hasTable = true
 
-		block.Results = append(block.Results, r)
+		node = node.Cdr
+	}
 
-		curr = curr.Cdr
+	// an active segment has the offset, which can be written as a single
+	// folded instruction
+	if e.Mode == mod.ElementPassive && node != nil && node.Car.Type == sexp.NodeCell {
+		p := &functionParser{
+			f: &mod.Function{},
+		}
+
+		var err error
+		if isClause(node.Car, "offset") {
+			e.Offset, err = p.parseInstructions(node.Car.Cdr)
+		} else {
+			e.Offset, err = p.parseFoldedInstruction(node.Car)
+		}
+		if err != nil {
+			return nil, err
+		}
+		e.Mode = mod.ElementActive
+
+		node = node.Cdr
+	} else if hasTable {
+		return nil, errInvalidModuleFormat
 	}
 
-	instructions, err := p.parseInstructions(curr)
+	// elements, which are function indices or expressions of the type
+	var err error
+	switch v, _ := carSymbol(node); {
+	case v == "func":
+		e.Type = types.FuncRef
+		e.Init, err = parseFuncIndices(node.Cdr)
+	case parseType(v).IsReference():
+		e.Type = parseType(v)
+		e.Init, err = parseElementExprs(node.Cdr)
+	case e.Mode == mod.ElementActive && !hasTable:
+		// only the function indices can be written without the table
+		e.Type = types.FuncRef
+		e.Init, err = parseFuncIndices(node)
+	default:
+		err = errInvalidModuleFormat
+	}
 	if err != nil {
 		return nil, err
 	}
-	block.Instructions = instructions
 
-	p.f.Blocks = append(p.f.Blocks, block)
+	return e, nil
+}
 
-	return &instruction.BlockInstruction{
-		Instruction: instruction.Block,
-		Label:       label,
-	}, nil
+// parseFuncIndices parses function indices as the elements of ref.func.
+func parseFuncIndices(node *sexp.Node) ([][]instruction.Instruction, error) {
+	var init [][]instruction.Instruction
+	for ; node != nil; node = node.Cdr {
+		index, err := parseIndex(node)
+		if err != nil {
+			return nil, err
+		}
+		init = append(init, []instruction.Instruction{
+			&instruction.ReferenceInstruction{Instruction: instruction.RefFunc, Function: index},
+		})
+	}
+
+	return init, nil
 }
 
-func (p *functionParser) parseBlockParam(node *sexp.Node) (*mod.Local, error) {
+// parseElementExprs parses the expressions of elements, each of which is
+// (item ...) or a single folded instruction.
+func parseElementExprs(node *sexp.Node) ([][]instruction.Instruction, error) {
+	var init [][]instruction.Instruction
+	for ; node != nil; node = node.Cdr {
+		if node.Car.Type != sexp.NodeCell {
+			return nil, errInvalidModuleFormat
+		}
+
+		p := &functionParser{
+			f: &mod.Function{},
+		}
+
+		var expr []instruction.Instruction
+		var err error
+		if isClause(node.Car, "item") {
+			expr, err = p.parseInstructions(node.Car.Cdr)
+		} else {
+			expr, err = p.parseFoldedInstruction(node.Car)
+		}
+		if err != nil {
+			return nil, err
+		}
+		init = append(init, expr)
+	}
+
+	return init, nil
+}
+
+func parseGlobal(node *sexp.Node, imp *mod.Import) (*mod.Global, error) {
+	// id (optional)
+	var id types.ID
+	if v, ok := carSymbol(node); ok && strings.HasPrefix(v, "$") {
+		id = types.ID(v)
+		if !id.IsValid() {
+			return nil, errInvalidModuleFormat
+		}
+
+		node = node.Cdr
+	}
+
+	if imp == nil {
+		var err error
+		imp, node, err = parseInlineImport(node)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if node == nil {
 		return nil, errInvalidModuleFormat
 	}
 
-	// type
-	v, ok := node.Car.SymbolValue()
+	// type, which is wrapped in (mut ...) if the global is mutable
+	g := &mod.Global{
+		ID:     id,
+		Import: imp,
+	}
+	typ := node.Car
+	if isClause(typ, "mut") {
+		if typ.Cdr == nil || typ.Cdr.Cdr != nil {
+			return nil, errInvalidModuleFormat
+		}
+		g.Mutable = true
+		typ = typ.Cdr.Car
+	}
+	v, ok := typ.SymbolValue()
 	if !ok {
 		return nil, errInvalidModuleFormat
 	}
-
-	typ := parseType(v)
-	if typ == types.Unkown {
+	g.Type = parseType(v)
+	if g.Type == types.Unkown {
 		return nil, errInvalidModuleFormat
 	}
 
-	return &mod.Local{
-		Type: typ,
-	}, nil
+	// an imported global has no initial value
+	if imp != nil {
+		if node.Cdr != nil {
+			return nil, errInvalidModuleFormat
+		}
+		return g, nil
+	}
+
+	p := &functionParser{
+		f: &mod.Function{},
+	}
+	init, err := p.parseInstructions(node.Cdr)
+	if err != nil {
+		return nil, err
+	}
+	g.Init = init
+
+	return g, nil
 }
 
-func (p *functionParser) parseBlockResult(node *sexp.Node) (*mod.Result, error) {
-	if node == nil {
-		return nil, errInvalidModuleFormat
+func parseData(node *sexp.Node) (*mod.Data, error) {
+	// id (optional)
+	var id types.ID
+	if v, ok := carSymbol(node); ok && strings.HasPrefix(v, "$") {
+		id = types.ID(v)
+		if !id.IsValid() {
+			return nil, errInvalidModuleFormat
+		}
+
+		node = node.Cdr
 	}
 
-	// type
-	v, ok := node.Car.SymbolValue()
-	if !ok {
-		return nil, errInvalidModuleFormat
+	d := &mod.Data{
+		ID:   id,
+		Mode: mod.DataPassive,
 	}
 
-	typ := parseType(v)
-	if typ == types.Unkown {
+	// memory (optional)
+	hasMemory := false
+	if node != nil && isClause(node.Car, "memory") {
+		index, err := parseIndex(node.Car.Cdr)
+		if err != nil {
+			return nil, err
+		}
+		if node.Car.Cdr.Cdr != nil {
+			return nil, errInvalidModuleFormat
+		}
+		d.Memory = index
+		hasMemory = true
+
+		node = node.Cdr
+	}
+
+	// an active segment has the offset, which can be written as a single
+	// folded instruction
+	if node != nil && node.Car.Type == sexp.NodeCell {
+		p := &functionParser{
+			f: &mod.Function{},
+		}
+
+		var err error
+		if isClause(node.Car, "offset") {
+			d.Offset, err = p.parseInstructions(node.Car.Cdr)
+		} else {
+			d.Offset, err = p.parseFoldedInstruction(node.Car)
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.Mode = mod.DataActive
+
+		node = node.Cdr
+	} else if hasMemory {
 		return nil, errInvalidModuleFormat
 	}
 
-	return &mod.Result{
-		Type: typ,
-	}, nil
+	for ; node != nil; node = node.Cdr {
+		s, ok := node.Car.StringValue()
+		if !ok {
+			return nil, errInvalidModuleFormat
+		}
+		d.Init = append(d.Init, s...)
+	}
+
+	return d, nil
 }
 
 func parseExport(node *sexp.Node) (*mod.Export, error) {
@@ -642,8 +2044,24 @@ func parseExportTarget(s string) (mod.ExportTarget, error) {
 	return "", errInvalidModuleFormat
 }
 
+// isIndex reports whether the car of node is an index or an ID.
+func isIndex(node *sexp.Node) bool {
+	if node == nil {
+		return false
+	}
+	if _, ok := node.Car.IntValue(); ok {
+		return true
+	}
+	v, ok := node.Car.SymbolValue()
+	return ok && strings.HasPrefix(v, "$")
+}
+
 func parseIndex(node *sexp.Node) (types.Index, error) {
 	var index types.Index
+	if node == nil {
+		return index, errInvalidModuleFormat
+	}
+
 	if idx, ok := node.Car.IntValue(); ok {
 		index.Index = int(idx)
 	} else if v, ok := node.Car.SymbolValue(); ok {
@@ -1,6 +1,8 @@
 package text
 
 import (
+	"errors"
+	"math"
 	"strings"
 	"testing"
 
@@ -53,9 +55,14 @@ var tests = map[string]struct {
 )`,
 		mod: &mod.Module{
 			ID: "$testmod",
+			Types: []*mod.FuncType{
+				{Parameters: []types.Type{types.I32, types.I64}, Results: []types.Type{types.I32}},
+				{Parameters: []types.Type{types.I32}, Results: []types.Type{types.I32}},
+			},
 			Functions: []*mod.Function{
 				{
-					ID: "$main",
+					ID:   "$main",
+					Type: types.NewIndex(0),
 					Parameters: []*mod.Local{
 						{ID: "$a", Type: types.I32},
 						{ID: "$b", Type: types.I64},
@@ -68,13 +75,6 @@ var tests = map[string]struct {
 						{ID: "$d", Type: types.I64},
 					},
 					Blocks: []*mod.Block{
-						{
-							Label: "$block2",
-							Instructions: []instruction.Instruction{
-								&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{10}},
-								&instruction.I32Instruction{Instruction: instruction.I32DivS},
-							},
-						},
 						{
 							Label: "$block1",
 							Parameters: []*mod.Local{
@@ -90,7 +90,14 @@ var tests = map[string]struct {
 								&instruction.I32Instruction{Instruction: instruction.I32Add},
 								&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.Index{ID: "$c"}},
 								&instruction.I32Instruction{Instruction: instruction.I32Mul},
-								&instruction.BlockInstruction{Instruction: instruction.Block, Label: "$block2"},
+								&instruction.BlockInstruction{Instruction: instruction.Block, Block: 1},
+							},
+						},
+						{
+							Label: "$block2",
+							Instructions: []instruction.Instruction{
+								&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{10}},
+								&instruction.I32Instruction{Instruction: instruction.I32DivS},
 							},
 						},
 					},
@@ -104,7 +111,7 @@ var tests = map[string]struct {
 						&instruction.I32Instruction{Instruction: instruction.I32Mul},
 						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{7}},
 						&instruction.I32Instruction{Instruction: instruction.I32DivS},
-						&instruction.BlockInstruction{Instruction: instruction.Block, Label: "$block1"},
+						&instruction.BlockInstruction{Instruction: instruction.Block, Block: 0},
 						&instruction.ControlInstruction{Instruction: instruction.Return},
 						&instruction.ParametricInstruction{Instruction: instruction.Drop},
 						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
@@ -117,6 +124,725 @@ var tests = map[string]struct {
 		},
 		err: nil,
 	},
+	"success 02": {
+		input: `(module
+  (func (param i32) (result i32)
+    (i32.add (local.get 0) (i32.const 1))
+    block (result i32)
+      block $inner
+      end $inner
+      (block
+        i32.const 2
+        drop)
+      i32.const 3
+    end
+    i32.mul
+  )
+  (func $empty)
+  (export "inc" (func 0))
+)`,
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{Parameters: []types.Type{types.I32}, Results: []types.Type{types.I32}},
+				{},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Parameters: []*mod.Local{
+						{Type: types.I32},
+					},
+					Results: []*mod.Result{
+						{Type: types.I32},
+					},
+					Blocks: []*mod.Block{
+						{
+							Results: []*mod.Result{
+								{Type: types.I32},
+							},
+							Instructions: []instruction.Instruction{
+								&instruction.BlockInstruction{Instruction: instruction.Block, Block: 1},
+								&instruction.BlockInstruction{Instruction: instruction.Block, Block: 2},
+								&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{3}},
+							},
+						},
+						{
+							Label: "$inner",
+						},
+						{
+							Instructions: []instruction.Instruction{
+								&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{2}},
+								&instruction.ParametricInstruction{Instruction: instruction.Drop},
+							},
+						},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+						&instruction.I32Instruction{Instruction: instruction.I32Add},
+						&instruction.BlockInstruction{Instruction: instruction.Block, Block: 0},
+						&instruction.I32Instruction{Instruction: instruction.I32Mul},
+					},
+				},
+				{
+					ID:   "$empty",
+					Type: types.NewIndex(1),
+				},
+			},
+			Exports: []*mod.Export{
+				{Name: "inc", Target: mod.ExportFunction, Index: types.NewIndex(0)},
+			},
+		},
+		err: nil,
+	},
+	"success 03": {
+		input: `(module
+  (func (param f32) (result f32)
+    (f32.sqrt (f32.add (local.get 0) (f32.const 0x1.8p1)))
+    f32.const inf
+    f32.max)
+  (func (result f64)
+    f64.const -1_000.25
+    f64.const 3
+    f64.nearest
+    f64.div)
+)`,
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{Parameters: []types.Type{types.F32}, Results: []types.Type{types.F32}},
+				{Results: []types.Type{types.F64}},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Parameters: []*mod.Local{
+						{Type: types.F32},
+					},
+					Results: []*mod.Result{
+						{Type: types.F32},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+						&instruction.F32Instruction{Instruction: instruction.F32Const, Values: []float32{3}},
+						&instruction.F32Instruction{Instruction: instruction.F32Add},
+						&instruction.F32Instruction{Instruction: instruction.F32Sqrt},
+						&instruction.F32Instruction{Instruction: instruction.F32Const, Values: []float32{float32(math.Inf(1))}},
+						&instruction.F32Instruction{Instruction: instruction.F32Max},
+					},
+				},
+				{
+					Type: types.NewIndex(1),
+					Results: []*mod.Result{
+						{Type: types.F64},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.F64Instruction{Instruction: instruction.F64Const, Values: []float64{-1000.25}},
+						&instruction.F64Instruction{Instruction: instruction.F64Const, Values: []float64{3}},
+						&instruction.F64Instruction{Instruction: instruction.F64Nearest},
+						&instruction.F64Instruction{Instruction: instruction.F64Div},
+					},
+				},
+			},
+		},
+		err: nil,
+	},
+	"success 04": {
+		input: `(module
+  (func (param i32)
+    (block $exit
+      (br_if $exit (local.get 0))
+      local.get 0
+      br_table 0 $exit 1
+      br 0)))`,
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{Parameters: []types.Type{types.I32}},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Parameters: []*mod.Local{
+						{Type: types.I32},
+					},
+					Blocks: []*mod.Block{
+						{
+							Label: "$exit",
+							Instructions: []instruction.Instruction{
+								&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+								&instruction.BranchInstruction{Instruction: instruction.BrIf, Labels: []types.Index{types.NewIndexWithID("$exit")}},
+								&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+								&instruction.BranchInstruction{Instruction: instruction.BrTable, Labels: []types.Index{types.NewIndex(0), types.NewIndexWithID("$exit"), types.NewIndex(1)}},
+								&instruction.BranchInstruction{Instruction: instruction.Br, Labels: []types.Index{types.NewIndex(0)}},
+							},
+						},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.BlockInstruction{Instruction: instruction.Block, Block: 0},
+					},
+				},
+			},
+		},
+		err: nil,
+	},
+	"success 05": {
+		input: `(module
+  (func (param i32) (result i32)
+    (loop $l
+      (br_if $l (i32.eqz (local.get 0))))
+    (if (result i32) (local.get 0)
+      (then (i32.const 1))
+      (else (i32.const 2)))
+    local.get 0
+    if $x (result i32)
+      i32.const 3
+    else $x
+      i32.const 4
+    end $x
+    i32.add
+    (if (block (result i32) (i32.const 1))
+      (then))))`,
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{Parameters: []types.Type{types.I32}, Results: []types.Type{types.I32}},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Parameters: []*mod.Local{
+						{Type: types.I32},
+					},
+					Results: []*mod.Result{
+						{Type: types.I32},
+					},
+					Blocks: []*mod.Block{
+						{
+							Label: "$l",
+							Instructions: []instruction.Instruction{
+								&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+								&instruction.I32Instruction{Instruction: instruction.I32Eqz},
+								&instruction.BranchInstruction{Instruction: instruction.BrIf, Labels: []types.Index{types.NewIndexWithID("$l")}},
+							},
+						},
+						{
+							Results: []*mod.Result{
+								{Type: types.I32},
+							},
+							Instructions: []instruction.Instruction{
+								&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+							},
+							Else: []instruction.Instruction{
+								&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{2}},
+							},
+						},
+						{
+							Label: "$x",
+							Results: []*mod.Result{
+								{Type: types.I32},
+							},
+							Instructions: []instruction.Instruction{
+								&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{3}},
+							},
+							Else: []instruction.Instruction{
+								&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{4}},
+							},
+						},
+						{
+							Results: []*mod.Result{
+								{Type: types.I32},
+							},
+							Instructions: []instruction.Instruction{
+								&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+							},
+						},
+						{},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.BlockInstruction{Instruction: instruction.Loop, Block: 0},
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+						&instruction.BlockInstruction{Instruction: instruction.If, Block: 1},
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+						&instruction.BlockInstruction{Instruction: instruction.If, Block: 2},
+						&instruction.I32Instruction{Instruction: instruction.I32Add},
+						&instruction.BlockInstruction{Instruction: instruction.Block, Block: 3},
+						&instruction.BlockInstruction{Instruction: instruction.If, Block: 4},
+					},
+				},
+			},
+		},
+		err: nil,
+	},
+	"success 06": {
+		input: `(module
+  (memory $mem 1 2)
+  (func (param i32) (result i32)
+    (i32.store offset=8 (local.get 0) (i32.const 42))
+    (drop (memory.grow (i32.const 1)))
+    local.get 0
+    i64.load32_u offset=0x10 align=2
+    drop
+    local.get 0
+    i32.load8_s align=1
+    memory.size
+    i32.add)
+  (export "mem" (memory $mem)))`,
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{Parameters: []types.Type{types.I32}, Results: []types.Type{types.I32}},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Parameters: []*mod.Local{
+						{Type: types.I32},
+					},
+					Results: []*mod.Result{
+						{Type: types.I32},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{42}},
+						&instruction.MemoryInstruction{Instruction: instruction.I32Store, Offset: 8, Align: 2},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+						&instruction.MemoryInstruction{Instruction: instruction.MemoryGrow},
+						&instruction.ParametricInstruction{Instruction: instruction.Drop},
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+						&instruction.MemoryInstruction{Instruction: instruction.I64Load32U, Offset: 16, Align: 1},
+						&instruction.ParametricInstruction{Instruction: instruction.Drop},
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+						&instruction.MemoryInstruction{Instruction: instruction.I32Load8S},
+						&instruction.MemoryInstruction{Instruction: instruction.MemorySize},
+						&instruction.I32Instruction{Instruction: instruction.I32Add},
+					},
+				},
+			},
+			Memories: []*mod.Memory{
+				{ID: "$mem", Limits: mod.Limits{Min: 1, Max: 2, HasMax: true}},
+			},
+			Exports: []*mod.Export{
+				{Name: "mem", Target: mod.ExportMemory, Index: types.NewIndexWithID("$mem")},
+			},
+		},
+		err: nil,
+	},
+	"success 07": {
+		input: `(module
+  (memory $m 1)
+  (func
+    (memory.init $p (i32.const 0) (i32.const 1) (i32.const 2))
+    data.drop 1)
+  (data (i32.const 8) "hello" "\00\ff")
+  (data $p "\u{3042}")
+  (data (memory $m) (offset i32.const 16) ""))`,
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Instructions: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{2}},
+						&instruction.MemoryInstruction{Instruction: instruction.MemoryInit, Data: types.NewIndexWithID("$p")},
+						&instruction.MemoryInstruction{Instruction: instruction.DataDrop, Data: types.NewIndex(1)},
+					},
+				},
+			},
+			Memories: []*mod.Memory{
+				{ID: "$m", Limits: mod.Limits{Min: 1}},
+			},
+			Data: []*mod.Data{
+				{
+					Mode: mod.DataActive,
+					Offset: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{8}},
+					},
+					Init: []byte("hello\x00\xff"),
+				},
+				{
+					ID:   "$p",
+					Mode: mod.DataPassive,
+					Init: []byte("\u3042"),
+				},
+				{
+					Mode:   mod.DataActive,
+					Memory: types.NewIndexWithID("$m"),
+					Offset: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{16}},
+					},
+				},
+			},
+		},
+		err: nil,
+	},
+	"success 08": {
+		input: `(module
+  (global $g (mut i64) (i64.const 1))
+  (global f32 f32.const 0.5)
+  (func
+    global.get $g
+    global.set 0)
+  (export "g" (global $g)))`,
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Instructions: []instruction.Instruction{
+						&instruction.VariableInstruction{Instruction: instruction.GlobalGet, Index: types.NewIndexWithID("$g")},
+						&instruction.VariableInstruction{Instruction: instruction.GlobalSet, Index: types.NewIndex(0)},
+					},
+				},
+			},
+			Globals: []*mod.Global{
+				{
+					ID:      "$g",
+					Type:    types.I64,
+					Mutable: true,
+					Init: []instruction.Instruction{
+						&instruction.I64Instruction{Instruction: instruction.I64Const, Values: []int64{1}},
+					},
+				},
+				{
+					Type: types.F32,
+					Init: []instruction.Instruction{
+						&instruction.F32Instruction{Instruction: instruction.F32Const, Values: []float32{0.5}},
+					},
+				},
+			},
+			Exports: []*mod.Export{
+				{Name: "g", Target: mod.ExportGlobal, Index: types.NewIndexWithID("$g")},
+			},
+		},
+		err: nil,
+	},
+	"success 09": {
+		input: `(module
+  (table $t 2 10 funcref)
+  (table funcref (elem $f))
+  (func $f (param i32) (result i32)
+    (call_indirect $t (param i32) (result i32) (local.get 0) (i32.const 1))
+    (table.set 1 (i32.const 0) (ref.null func))
+    (table.get (i32.const 0))
+    ref.is_null
+    drop
+    table.size
+    drop
+    (table.copy 0 1 (i32.const 0) (i32.const 0) (i32.const 1))
+    (table.init $e (i32.const 0) (i32.const 0) (i32.const 1))
+    elem.drop 2)
+  (elem (i32.const 1) $f)
+  (elem $e funcref (ref.func $f) (item ref.null func))
+  (elem declare func 0))`,
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{Parameters: []types.Type{types.I32}, Results: []types.Type{types.I32}},
+			},
+			Functions: []*mod.Function{
+				{
+					ID:         "$f",
+					Type:       types.NewIndex(0),
+					Parameters: []*mod.Local{{Type: types.I32}},
+					Results:    []*mod.Result{{Type: types.I32}},
+					Instructions: []instruction.Instruction{
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+						&instruction.CallIndirectInstruction{
+							Instruction: instruction.CallIndirect,
+							Table:       types.NewIndexWithID("$t"),
+							Type:        types.NewIndex(0),
+						},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.ReferenceInstruction{Instruction: instruction.RefNull, Type: types.FuncRef},
+						&instruction.TableInstruction{Instruction: instruction.TableSet, Table: types.NewIndex(1)},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.TableInstruction{Instruction: instruction.TableGet, Table: types.NewIndex(0)},
+						&instruction.ReferenceInstruction{Instruction: instruction.RefIsNull},
+						&instruction.ParametricInstruction{Instruction: instruction.Drop},
+						&instruction.TableInstruction{Instruction: instruction.TableSize, Table: types.NewIndex(0)},
+						&instruction.ParametricInstruction{Instruction: instruction.Drop},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+						&instruction.TableInstruction{Instruction: instruction.TableCopy, Table: types.NewIndex(0), Source: types.NewIndex(1)},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+						&instruction.TableInstruction{Instruction: instruction.TableInit, Table: types.NewIndex(0), Elem: types.NewIndexWithID("$e")},
+						&instruction.TableInstruction{Instruction: instruction.ElemDrop, Elem: types.NewIndex(2)},
+					},
+				},
+			},
+			Tables: []*mod.Table{
+				{ID: "$t", Limits: mod.Limits{Min: 2, Max: 10, HasMax: true}, Type: types.FuncRef},
+				{Limits: mod.Limits{Min: 1, Max: 1, HasMax: true}, Type: types.FuncRef},
+			},
+			Elements: []*mod.Element{
+				{
+					Mode:  mod.ElementActive,
+					Table: types.NewIndex(1),
+					Offset: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+					},
+					Type: types.FuncRef,
+					Init: [][]instruction.Instruction{
+						{&instruction.ReferenceInstruction{Instruction: instruction.RefFunc, Function: types.NewIndexWithID("$f")}},
+					},
+				},
+				{
+					Mode: mod.ElementActive,
+					Offset: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+					},
+					Type: types.FuncRef,
+					Init: [][]instruction.Instruction{
+						{&instruction.ReferenceInstruction{Instruction: instruction.RefFunc, Function: types.NewIndexWithID("$f")}},
+					},
+				},
+				{
+					ID:   "$e",
+					Mode: mod.ElementPassive,
+					Type: types.FuncRef,
+					Init: [][]instruction.Instruction{
+						{&instruction.ReferenceInstruction{Instruction: instruction.RefFunc, Function: types.NewIndexWithID("$f")}},
+						{&instruction.ReferenceInstruction{Instruction: instruction.RefNull, Type: types.FuncRef}},
+					},
+				},
+				{
+					Mode: mod.ElementDeclarative,
+					Type: types.FuncRef,
+					Init: [][]instruction.Instruction{
+						{&instruction.ReferenceInstruction{Instruction: instruction.RefFunc, Function: types.NewIndex(0)}},
+					},
+				},
+			},
+		},
+		err: nil,
+	},
+	"success 10": {
+		input: `(module
+  (func $g (result i64)
+    i64.const 1)
+  (type $t (func (param i32) (result i32)))
+  (func $f (type $t)
+    local.get 0
+    (block (type $t))
+    (call_indirect (type 0) (i32.const 0)))
+  (func (type 0) (param $p i32) (result i32)
+    local.get $p)
+  (table 1 funcref))`,
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{ID: "$t", Parameters: []types.Type{types.I32}, Results: []types.Type{types.I32}},
+				{Results: []types.Type{types.I64}},
+			},
+			Functions: []*mod.Function{
+				{
+					ID:      "$g",
+					Type:    types.NewIndex(1),
+					Results: []*mod.Result{{Type: types.I64}},
+					Instructions: []instruction.Instruction{
+						&instruction.I64Instruction{Instruction: instruction.I64Const, Values: []int64{1}},
+					},
+				},
+				{
+					ID:         "$f",
+					Type:       types.NewIndexWithID("$t"),
+					Parameters: []*mod.Local{{Type: types.I32}},
+					Results:    []*mod.Result{{Type: types.I32}},
+					Blocks: []*mod.Block{
+						{
+							Parameters: []*mod.Local{{Type: types.I32}},
+							Results:    []*mod.Result{{Type: types.I32}},
+						},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndex(0)},
+						&instruction.BlockInstruction{Instruction: instruction.Block, Block: 0},
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+						&instruction.CallIndirectInstruction{
+							Instruction: instruction.CallIndirect,
+							Table:       types.NewIndex(0),
+							Type:        types.NewIndex(0),
+						},
+					},
+				},
+				{
+					Type:       types.NewIndex(0),
+					Parameters: []*mod.Local{{ID: "$p", Type: types.I32}},
+					Results:    []*mod.Result{{Type: types.I32}},
+					Instructions: []instruction.Instruction{
+						&instruction.VariableInstruction{Instruction: instruction.LocalGet, Index: types.NewIndexWithID("$p")},
+					},
+				},
+			},
+			Tables: []*mod.Table{
+				{Limits: mod.Limits{Min: 1}, Type: types.FuncRef},
+			},
+		},
+		err: nil,
+	},
+	"success 11": {
+		input: `(module
+  (import "env" "log" (func $log (param i32)))
+  (import "env" "mem" (memory 1))
+  (global $g (import "env" "g") (mut i64))
+  (table (import "env" "t") 2 funcref)
+  (func
+    i32.const 1
+    call $log)
+  (export "f" (func 1)))`,
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{Parameters: []types.Type{types.I32}},
+				{},
+			},
+			Functions: []*mod.Function{
+				{
+					ID:         "$log",
+					Import:     &mod.Import{Module: "env", Name: "log"},
+					Type:       types.NewIndex(0),
+					Parameters: []*mod.Local{{Type: types.I32}},
+				},
+				{
+					Type: types.NewIndex(1),
+					Instructions: []instruction.Instruction{
+						&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{1}},
+						&instruction.CallInstruction{Instruction: instruction.Call, Index: types.NewIndexWithID("$log")},
+					},
+				},
+			},
+			Tables: []*mod.Table{
+				{Import: &mod.Import{Module: "env", Name: "t"}, Limits: mod.Limits{Min: 2}, Type: types.FuncRef},
+			},
+			Memories: []*mod.Memory{
+				{Import: &mod.Import{Module: "env", Name: "mem"}, Limits: mod.Limits{Min: 1}},
+			},
+			Globals: []*mod.Global{
+				{ID: "$g", Import: &mod.Import{Module: "env", Name: "g"}, Type: types.I64, Mutable: true},
+			},
+			Exports: []*mod.Export{
+				{Name: "f", Target: mod.ExportFunction, Index: types.NewIndex(1)},
+			},
+		},
+		err: nil,
+	},
+	"success 12": {
+		input: `(module
+  (func $init)
+  (start $init))`,
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{},
+			},
+			Functions: []*mod.Function{
+				{ID: "$init", Type: types.NewIndex(0)},
+			},
+			Start: indexPtr(types.NewIndexWithID("$init")),
+		},
+		err: nil,
+	},
+	"success 13": {
+		input: `(module
+  (func
+    (loop $l (br_if $l (i32.const 0)))
+    (loop $l (br $l))
+    block
+      block $#block0
+      end
+    end))`,
+		mod: &mod.Module{
+			Types: []*mod.FuncType{
+				{},
+			},
+			Functions: []*mod.Function{
+				{
+					Type: types.NewIndex(0),
+					Blocks: []*mod.Block{
+						{
+							Label: "$l",
+							Instructions: []instruction.Instruction{
+								&instruction.I32Instruction{Instruction: instruction.I32Const, Values: []int32{0}},
+								&instruction.BranchInstruction{Instruction: instruction.BrIf, Labels: []types.Index{types.NewIndexWithID("$l")}},
+							},
+						},
+						{
+							Label: "$l",
+							Instructions: []instruction.Instruction{
+								&instruction.BranchInstruction{Instruction: instruction.Br, Labels: []types.Index{types.NewIndexWithID("$l")}},
+							},
+						},
+						{
+							Instructions: []instruction.Instruction{
+								&instruction.BlockInstruction{Instruction: instruction.Block, Block: 3},
+							},
+						},
+						{
+							Label: "$#block0",
+						},
+					},
+					Instructions: []instruction.Instruction{
+						&instruction.BlockInstruction{Instruction: instruction.Loop, Block: 0},
+						&instruction.BlockInstruction{Instruction: instruction.Loop, Block: 1},
+						&instruction.BlockInstruction{Instruction: instruction.Block, Block: 2},
+					},
+				},
+			},
+		},
+		err: nil,
+	},
+	"multiple start": {
+		input: `(module
+  (func $init)
+  (start $init)
+  (start $init))`,
+		err: errInvalidModuleFormat,
+	},
+	"import after definition": {
+		input: `(module
+  (func)
+  (import "env" "f" (func)))`,
+		err: errImportAfterDefinition,
+	},
+	"imported function with body": {
+		input: `(module
+  (func (import "env" "f") (result i32)
+    i32.const 1))`,
+		err: errInvalidModuleFormat,
+	},
+	"unknown type": {
+		input: `(module
+  (func (type 0)))`,
+		err: errUnknownType,
+	},
+	"inline type mismatch": {
+		input: `(module
+  (type (func (param i32)))
+  (func (type 0) (param i64)))`,
+		err: errTypeMismatch,
+	},
+	"elem with table without func": {
+		input: `(module
+  (table 1 funcref)
+  (elem (table 0) (i32.const 0) 0))`,
+		err: errInvalidModuleFormat,
+	},
+	"invalid global type": {
+		input: `(module
+  (global (mut i32 i64) i32.const 0))`,
+		err: errInvalidModuleFormat,
+	},
+	"invalid alignment": {
+		input: `(module
+  (func
+    i32.const 0
+    i32.load align=3
+    drop))`,
+		err: errInvalidModuleFormat,
+	},
 }
 
 func Test_Decode(t *testing.T) {
@@ -125,7 +851,7 @@ func Test_Decode(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			d := NewDecoder(strings.NewReader(tt.input))
 			m, err := d.Decode()
-			if err != tt.err {
+			if !errors.Is(err, tt.err) {
 				t.Errorf("Decoder.Decode(): err: want: %v, got:%v", tt.err, err)
 			}
 
@@ -135,3 +861,7 @@ func Test_Decode(t *testing.T) {
 		})
 	}
 }
+
+func indexPtr(idx types.Index) *types.Index {
+	return &idx
+}
@@ -0,0 +1,702 @@
+package text
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kechako/wasmexec/mod"
+	"github.com/kechako/wasmexec/mod/instruction"
+	"github.com/kechako/wasmexec/mod/types"
+)
+
+// Style is the way the encoder writes block instructions.
+type Style int
+
+const (
+	// StyleFolded writes block instructions as S-expressions.
+	StyleFolded Style = iota
+	// StyleFlat writes block instructions as plain instructions terminated
+	// by end.
+	StyleFlat
+)
+
+type Encoder struct {
+	w     io.Writer
+	style Style
+}
+
+var _ mod.Encoder = (*Encoder)(nil)
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w:     w,
+		style: StyleFolded,
+	}
+}
+
+// SetStyle sets the style of block instructions, which is StyleFolded by
+// default.
+func (e *Encoder) SetStyle(style Style) {
+	e.style = style
+}
+
+func (e *Encoder) Encode(m *mod.Module) error {
+	p := &printer{
+		style: e.style,
+	}
+	if err := p.printModule(m); err != nil {
+		return fmt.Errorf("failed to encode wat: %w", err)
+	}
+
+	if _, err := e.w.Write(p.buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write wat: %w", err)
+	}
+
+	return nil
+}
+
+var (
+	errUnknownInstruction = errors.New("unknown instruction")
+	errBlockNotFound      = errors.New("block is not found")
+)
+
+const indentWidth = 2
+
+type printer struct {
+	buf    bytes.Buffer
+	style  Style
+	indent int
+}
+
+func (p *printer) println(s string) {
+	p.buf.WriteString(strings.Repeat(" ", p.indent*indentWidth))
+	p.buf.WriteString(s)
+	p.buf.WriteByte('\n')
+}
+
+func (p *printer) printModule(m *mod.Module) error {
+	if m.ID.IsEmpty() {
+		p.println("(module")
+	} else {
+		p.println("(module " + string(m.ID))
+	}
+	p.indent++
+
+	for _, t := range m.Types {
+		s := "(type"
+		if !t.ID.IsEmpty() {
+			s += " " + string(t.ID)
+		}
+		p.println(s + " (func" + formatFuncType(t) + "))")
+	}
+
+	// imports precede the definitions of all kinds
+	for _, f := range m.Functions {
+		if f.Import != nil {
+			p.println(formatImport(f.Import, "func"+formatFuncHeader(m, f)))
+		}
+	}
+	for _, t := range m.Tables {
+		if t.Import != nil {
+			p.println(formatImport(t.Import, formatTable(t)))
+		}
+	}
+	for _, mem := range m.Memories {
+		if mem.Import != nil {
+			p.println(formatImport(mem.Import, formatMemory(mem)))
+		}
+	}
+	for _, g := range m.Globals {
+		if g.Import != nil {
+			p.println(formatImport(g.Import, formatGlobalType(g)))
+		}
+	}
+
+	for i, f := range m.Functions {
+		if f.Import != nil {
+			continue
+		}
+		fp := &functionPrinter{
+			printer: p,
+			m:       m,
+			f:       f,
+		}
+		if err := fp.Print(); err != nil {
+			return fmt.Errorf("func %d: %w", i, err)
+		}
+	}
+
+	for _, t := range m.Tables {
+		if t.Import == nil {
+			p.println("(" + formatTable(t) + ")")
+		}
+	}
+
+	for _, mem := range m.Memories {
+		if mem.Import == nil {
+			p.println("(" + formatMemory(mem) + ")")
+		}
+	}
+
+	for i, g := range m.Globals {
+		if g.Import != nil {
+			continue
+		}
+		s, err := formatGlobal(g)
+		if err != nil {
+			return fmt.Errorf("global %d: %w", i, err)
+		}
+		p.println(s)
+	}
+
+	for _, e := range m.Exports {
+		p.println(fmt.Sprintf("(export %s (%s %s))", formatString(e.Name), e.Target, formatIndex(e.Index)))
+	}
+
+	if m.Start != nil {
+		p.println("(start " + formatIndex(*m.Start) + ")")
+	}
+
+	for i, e := range m.Elements {
+		s, err := formatElement(e)
+		if err != nil {
+			return fmt.Errorf("elem %d: %w", i, err)
+		}
+		p.println(s)
+	}
+
+	for i, d := range m.Data {
+		s, err := formatData(d)
+		if err != nil {
+			return fmt.Errorf("data %d: %w", i, err)
+		}
+		p.println(s)
+	}
+
+	p.indent--
+	p.println(")")
+
+	return nil
+}
+
+type functionPrinter struct {
+	*printer
+	m *mod.Module
+	f *mod.Function
+}
+
+func (p *functionPrinter) Print() error {
+	p.println("(func" + formatFuncHeader(p.m, p.f))
+	p.indent++
+
+	for _, l := range p.f.Locals {
+		p.println("(local" + formatLocal(l) + ")")
+	}
+
+	if err := p.printInstructions(p.f.Instructions); err != nil {
+		return err
+	}
+
+	p.indent--
+	p.println(")")
+
+	return nil
+}
+
+func (p *functionPrinter) printInstructions(instructions []instruction.Instruction) error {
+	for _, i := range instructions {
+		if err := p.printInstruction(i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *functionPrinter) printInstruction(i instruction.Instruction) error {
+	if i, ok := i.(*instruction.BlockInstruction); ok {
+		return p.printBlockInstruction(i)
+	}
+
+	s, err := formatInstruction(i)
+	if err != nil {
+		return err
+	}
+	p.println(s)
+
+	return nil
+}
+
+// formatInstruction formats an instruction other than block instructions.
+func formatInstruction(i instruction.Instruction) (string, error) {
+	switch i := i.(type) {
+	case *instruction.I32Instruction:
+		s := string(i.Instruction)
+		for _, v := range i.Values {
+			s += " " + strconv.FormatInt(int64(v), 10)
+		}
+		return s, nil
+	case *instruction.I64Instruction:
+		s := string(i.Instruction)
+		for _, v := range i.Values {
+			s += " " + strconv.FormatInt(v, 10)
+		}
+		return s, nil
+	case *instruction.F32Instruction:
+		s := string(i.Instruction)
+		for _, v := range i.Values {
+			s += " " + formatFloat(float64(v), uint64(math.Float32bits(v)), 32)
+		}
+		return s, nil
+	case *instruction.F64Instruction:
+		s := string(i.Instruction)
+		for _, v := range i.Values {
+			s += " " + formatFloat(v, math.Float64bits(v), 64)
+		}
+		return s, nil
+	case *instruction.ConversionInstruction:
+		return string(i.Instruction), nil
+	case *instruction.ParametricInstruction:
+		return string(i.Instruction), nil
+	case *instruction.ReferenceInstruction:
+		switch i.Instruction {
+		case instruction.RefNull:
+			// the heap type of funcref
+			return string(i.Instruction) + " func", nil
+		case instruction.RefFunc:
+			return string(i.Instruction) + " " + formatIndex(i.Function), nil
+		}
+		return string(i.Instruction), nil
+	case *instruction.VariableInstruction:
+		return string(i.Instruction) + " " + formatIndex(i.Index), nil
+	case *instruction.TableInstruction:
+		s := string(i.Instruction)
+		switch i.Instruction {
+		case instruction.TableCopy:
+			s += " " + formatIndex(i.Table) + " " + formatIndex(i.Source)
+		case instruction.TableInit:
+			s += " " + formatIndex(i.Table) + " " + formatIndex(i.Elem)
+		case instruction.ElemDrop:
+			s += " " + formatIndex(i.Elem)
+		default:
+			s += " " + formatIndex(i.Table)
+		}
+		return s, nil
+	case *instruction.MemoryInstruction:
+		s := string(i.Instruction)
+		switch i.Instruction {
+		case instruction.MemoryInit, instruction.DataDrop:
+			return s + " " + formatIndex(i.Data), nil
+		}
+		if i.Offset != 0 {
+			s += " offset=" + strconv.FormatUint(uint64(i.Offset), 10)
+		}
+		if i.Instruction.AccessSize() != 0 && i.Align != i.Instruction.NaturalAlignment() {
+			s += " align=" + strconv.FormatUint(1<<i.Align, 10)
+		}
+		return s, nil
+	case *instruction.ControlInstruction:
+		return string(i.Instruction), nil
+	case *instruction.CallInstruction:
+		return string(i.Instruction) + " " + formatIndex(i.Index), nil
+	case *instruction.CallIndirectInstruction:
+		return string(i.Instruction) + " " + formatIndex(i.Table) + " (type " + formatIndex(i.Type) + ")", nil
+	case *instruction.BranchInstruction:
+		s := string(i.Instruction)
+		for _, label := range i.Labels {
+			s += " " + formatIndex(label)
+		}
+		return s, nil
+	}
+
+	return "", fmt.Errorf("%s: %w", i.Name(), errUnknownInstruction)
+}
+
+func (p *functionPrinter) printBlockInstruction(i *instruction.BlockInstruction) error {
+	block, ok := p.f.Block(i.Block)
+	if !ok {
+		return fmt.Errorf("%s %d: %w", i.Name(), i.Block, errBlockNotFound)
+	}
+
+	var b strings.Builder
+	if p.style == StyleFolded {
+		b.WriteString("(")
+	}
+	b.WriteString(string(i.Instruction))
+	if !block.Label.IsEmpty() {
+		b.WriteString(" " + string(block.Label))
+	}
+	writeParameters(&b, block.Parameters)
+	writeResults(&b, block.Results)
+	p.println(b.String())
+
+	if i.Instruction == instruction.If {
+		if err := p.printIfBranches(block); err != nil {
+			return err
+		}
+	} else {
+		p.indent++
+		if err := p.printInstructions(block.Instructions); err != nil {
+			return err
+		}
+		p.indent--
+	}
+
+	if p.style == StyleFolded {
+		p.println(")")
+	} else {
+		p.println("end")
+	}
+
+	return nil
+}
+
+func (p *functionPrinter) printIfBranches(block *mod.Block) error {
+	if p.style == StyleFlat {
+		p.indent++
+		if err := p.printInstructions(block.Instructions); err != nil {
+			return err
+		}
+		p.indent--
+
+		if block.Else != nil {
+			p.println("else")
+			p.indent++
+			if err := p.printInstructions(block.Else); err != nil {
+				return err
+			}
+			p.indent--
+		}
+
+		return nil
+	}
+
+	p.indent++
+	p.println("(then")
+	p.indent++
+	if err := p.printInstructions(block.Instructions); err != nil {
+		return err
+	}
+	p.indent--
+	p.println(")")
+
+	if block.Else != nil {
+		p.println("(else")
+		p.indent++
+		if err := p.printInstructions(block.Else); err != nil {
+			return err
+		}
+		p.indent--
+		p.println(")")
+	}
+	p.indent--
+
+	return nil
+}
+
+// formatFuncHeader returns the id and the typeuse of f.
+func formatFuncHeader(m *mod.Module, f *mod.Function) string {
+	var b strings.Builder
+	if !f.ID.IsEmpty() {
+		b.WriteString(" " + string(f.ID))
+	}
+	// a module built without the types has no type for the function
+	if _, ok := m.TypeIndex(f.Type); ok {
+		b.WriteString(" (type " + formatIndex(f.Type) + ")")
+	}
+	writeParameters(&b, f.Parameters)
+	writeResults(&b, f.Results)
+
+	return b.String()
+}
+
+// formatImport returns an import of the description desc, which is not
+// parenthesized.
+func formatImport(imp *mod.Import, desc string) string {
+	return fmt.Sprintf("(import %s %s (%s))", formatString(imp.Module), formatString(imp.Name), desc)
+}
+
+func formatTable(t *mod.Table) string {
+	s := "table"
+	if !t.ID.IsEmpty() {
+		s += " " + string(t.ID)
+	}
+
+	return s + formatLimits(t.Limits) + " " + string(t.Type)
+}
+
+func formatMemory(mem *mod.Memory) string {
+	s := "memory"
+	if !mem.ID.IsEmpty() {
+		s += " " + string(mem.ID)
+	}
+
+	return s + formatLimits(mem.Limits)
+}
+
+func writeParameters(b *strings.Builder, params []*mod.Local) {
+	for _, param := range params {
+		b.WriteString(" (param" + formatLocal(param) + ")")
+	}
+}
+
+func writeResults(b *strings.Builder, results []*mod.Result) {
+	for _, r := range results {
+		b.WriteString(" (result " + string(r.Type) + ")")
+	}
+}
+
+// formatFuncType returns the parameters and the results of t.
+func formatFuncType(t *mod.FuncType) string {
+	var s string
+	if len(t.Parameters) > 0 {
+		s += " (param" + formatTypes(t.Parameters) + ")"
+	}
+	if len(t.Results) > 0 {
+		s += " (result" + formatTypes(t.Results) + ")"
+	}
+
+	return s
+}
+
+func formatLocal(l *mod.Local) string {
+	if l.ID.IsEmpty() {
+		return " " + string(l.Type)
+	}
+
+	return " " + string(l.ID) + " " + string(l.Type)
+}
+
+func formatGlobal(g *mod.Global) (string, error) {
+	expr, err := formatExpr(g.Init)
+	if err != nil {
+		return "", err
+	}
+
+	return "(" + formatGlobalType(g) + expr + ")", nil
+}
+
+// formatGlobalType returns the id and the type of g.
+func formatGlobalType(g *mod.Global) string {
+	s := "global"
+	if !g.ID.IsEmpty() {
+		s += " " + string(g.ID)
+	}
+
+	if g.Mutable {
+		return s + " (mut " + string(g.Type) + ")"
+	}
+
+	return s + " " + string(g.Type)
+}
+
+// formatExpr formats a constant expression in a line, where each
+// instruction is preceded by a space.
+func formatExpr(expr []instruction.Instruction) (string, error) {
+	var s string
+	for _, i := range expr {
+		is, err := formatInstruction(i)
+		if err != nil {
+			return "", err
+		}
+		s += " " + is
+	}
+
+	return s, nil
+}
+
+func formatTypes(typs []types.Type) string {
+	var s string
+	for _, typ := range typs {
+		s += " " + string(typ)
+	}
+
+	return s
+}
+
+func formatElement(e *mod.Element) (string, error) {
+	s := "(elem"
+	if !e.ID.IsEmpty() {
+		s += " " + string(e.ID)
+	}
+
+	switch e.Mode {
+	case mod.ElementActive:
+		s += " (table " + formatIndex(e.Table) + ")"
+		expr, err := formatExpr(e.Offset)
+		if err != nil {
+			return "", err
+		}
+		s += " (offset" + expr + ")"
+	case mod.ElementDeclarative:
+		s += " declare"
+	}
+
+	// the elements are written as function indices if possible
+	if funcs, ok := elementFuncs(e); ok {
+		s += " func"
+		for _, idx := range funcs {
+			s += " " + formatIndex(idx)
+		}
+		return s + ")", nil
+	}
+
+	s += " " + string(e.Type)
+	for _, init := range e.Init {
+		expr, err := formatExpr(init)
+		if err != nil {
+			return "", err
+		}
+		s += " (item" + expr + ")"
+	}
+
+	return s + ")", nil
+}
+
+// elementFuncs returns the functions of the elements if all of them are
+// ref.func.
+func elementFuncs(e *mod.Element) ([]types.Index, bool) {
+	if e.Type != types.FuncRef {
+		return nil, false
+	}
+
+	funcs := make([]types.Index, 0, len(e.Init))
+	for _, init := range e.Init {
+		if len(init) != 1 || init[0].Name() != instruction.RefFunc {
+			return nil, false
+		}
+		funcs = append(funcs, init[0].(*instruction.ReferenceInstruction).Function)
+	}
+
+	return funcs, true
+}
+
+func formatData(d *mod.Data) (string, error) {
+	s := "(data"
+	if !d.ID.IsEmpty() {
+		s += " " + string(d.ID)
+	}
+
+	if d.Mode == mod.DataActive {
+		if d.Memory.IsID() || d.Memory.Index != 0 {
+			s += " (memory " + formatIndex(d.Memory) + ")"
+		}
+		expr, err := formatExpr(d.Offset)
+		if err != nil {
+			return "", err
+		}
+		s += " (offset" + expr + ")"
+	}
+
+	if len(d.Init) > 0 {
+		s += " " + formatBytes(d.Init)
+	}
+
+	return s + ")", nil
+}
+
+func formatLimits(limits mod.Limits) string {
+	s := " " + strconv.FormatUint(uint64(limits.Min), 10)
+	if limits.HasMax {
+		s += " " + strconv.FormatUint(uint64(limits.Max), 10)
+	}
+
+	return s
+}
+
+func formatIndex(idx types.Index) string {
+	if idx.IsID() {
+		return string(idx.ID)
+	}
+
+	return strconv.Itoa(idx.Index)
+}
+
+// formatFloat formats a float of the given size, whose bits are also passed
+// to keep the payload of a NaN. The result is always read back as a float
+// literal of the same value.
+func formatFloat(f float64, bits uint64, bitSize int) string {
+	fracBits := uint64(1)<<52 - 1
+	if bitSize == 32 {
+		fracBits = 1<<23 - 1
+	}
+
+	sign := ""
+	if bits>>(bitSize-1) != 0 {
+		sign = "-"
+	}
+
+	switch {
+	case math.IsInf(f, 0):
+		return sign + "inf"
+	case math.IsNaN(f):
+		payload := bits & fracBits
+		if payload == (fracBits+1)>>1 {
+			return sign + "nan"
+		}
+		return fmt.Sprintf("%snan:0x%x", sign, payload)
+	}
+
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(s, ".e") {
+		// without a fraction or an exponent, it is an integer literal
+		s += ".0"
+	}
+
+	return s
+}
+
+// formatBytes formats bytes as a string literal, where the bytes other than
+// printable ASCII characters are escaped.
+func formatBytes(data []byte) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, c := range data {
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+func formatString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, `\%02x`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
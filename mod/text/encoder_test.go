@@ -0,0 +1,254 @@
+package text
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var encodeTests = map[string]struct {
+	input  string
+	style  Style
+	output string
+}{
+	"folded": {
+		input: `(module $testmod
+  (func $main (result i32)
+    (local $l i32)
+    (block $b1 (result i32)
+      (block i32.const 1 drop)
+      i32.const 10)
+    local.tee $l
+    i32.const 20
+    call $sub)
+  (func $sub (param $p1 i32) (param $p2 i32) (result i32)
+    local.get $p1
+    local.get $p2
+    i32.sub
+    return)
+  (export "main\t\"1\"" (func $main)))`,
+		style: StyleFolded,
+		output: `(module $testmod
+  (type (func (result i32)))
+  (type (func (param i32 i32) (result i32)))
+  (func $main (type 0) (result i32)
+    (local $l i32)
+    (block $b1 (result i32)
+      (block
+        i32.const 1
+        drop
+      )
+      i32.const 10
+    )
+    local.tee $l
+    i32.const 20
+    call $sub
+  )
+  (func $sub (type 1) (param $p1 i32) (param $p2 i32) (result i32)
+    local.get $p1
+    local.get $p2
+    i32.sub
+    return
+  )
+  (export "main\t\"1\"" (func $main))
+)
+`,
+	},
+	"flat": {
+		input: `(module
+  (func (param i32) (result i32)
+    local.get 0
+    (block (param i32) (result i32)
+      (block $b2)
+      i32.const 10
+      i32.add)))`,
+		style: StyleFlat,
+		output: `(module
+  (type (func (param i32) (result i32)))
+  (func (type 0) (param i32) (result i32)
+    local.get 0
+    block (param i32) (result i32)
+      block $b2
+      end
+      i32.const 10
+      i32.add
+    end
+  )
+)
+`,
+	},
+	"float": {
+		input: `(module
+  (func (result f32)
+    f32.const 1
+    f32.const -0x0p+0
+    f32.const 0.1
+    f32.const 1e38
+    f32.const -inf
+    f32.const nan
+    f32.const -nan:0x1
+    f32.min)
+  (func (result f64)
+    f64.const 2.5
+    f64.const 0x1p-1074
+    f64.const nan:0x4_0000_0000_0000
+    f64.copysign))`,
+		style: StyleFolded,
+		output: `(module
+  (type (func (result f32)))
+  (type (func (result f64)))
+  (func (type 0) (result f32)
+    f32.const 1.0
+    f32.const -0.0
+    f32.const 0.1
+    f32.const 1e+38
+    f32.const -inf
+    f32.const nan
+    f32.const -nan:0x1
+    f32.min
+  )
+  (func (type 1) (result f64)
+    f64.const 2.5
+    f64.const 5e-324
+    f64.const nan:0x4000000000000
+    f64.copysign
+  )
+)
+`,
+	},
+	"module fields": {
+		input: `(module
+  (memory 1)
+  (global $g (mut i32) (i32.const -1))
+  (global f64 f64.const 0.5)
+  (data (i32.const 8) "a\n\"")
+  (func (result i32)
+    global.get $g)
+  (export "g" (global $g)))`,
+		style: StyleFolded,
+		output: `(module
+  (type (func (result i32)))
+  (func (type 0) (result i32)
+    global.get $g
+  )
+  (memory 1)
+  (global $g (mut i32) i32.const -1)
+  (global f64 f64.const 0.5)
+  (export "g" (global $g))
+  (data (offset i32.const 8) "a\0a\"")
+)
+`,
+	},
+	"tables": {
+		input: `(module
+  (type $sig (func (param i32 i64)))
+  (table $t 1 funcref)
+  (func $f
+    (call_indirect (type $sig) (param i32 i64) (i32.const 1) (i64.const 2) (i32.const 0))
+    (table.grow $t (ref.func $f) (i32.const 1))
+    drop)
+  (elem (i32.const 0) $f)
+  (elem funcref (ref.null func))
+  (elem declare func))`,
+		style: StyleFolded,
+		output: `(module
+  (type $sig (func (param i32 i64)))
+  (type (func))
+  (func $f (type 1)
+    i32.const 1
+    i64.const 2
+    i32.const 0
+    call_indirect 0 (type $sig)
+    ref.func $f
+    i32.const 1
+    table.grow $t
+    drop
+  )
+  (table $t 1 funcref)
+  (elem (table 0) (offset i32.const 0) func $f)
+  (elem funcref (item ref.null func))
+  (elem declare func)
+)
+`,
+	},
+	"imports": {
+		input: `(module
+  (memory (import "env" "mem") 1 2)
+  (import "env" "log" (func $log (param i32)))
+  (import "env" "g" (global $g i32))
+  (func $f
+    (call $log (global.get $g))))`,
+		style: StyleFlat,
+		output: `(module
+  (type (func (param i32)))
+  (type (func))
+  (import "env" "log" (func $log (type 0) (param i32)))
+  (import "env" "mem" (memory 1 2))
+  (import "env" "g" (global $g i32))
+  (func $f (type 1)
+    global.get $g
+    call $log
+  )
+)
+`,
+	},
+}
+
+func Test_Encode(t *testing.T) {
+	for name, tt := range encodeTests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			m, err := NewDecoder(strings.NewReader(tt.input)).Decode()
+			if err != nil {
+				t.Fatalf("Decoder.Decode(): %v", err)
+			}
+
+			var buf bytes.Buffer
+			e := NewEncoder(&buf)
+			e.SetStyle(tt.style)
+			if err := e.Encode(m); err != nil {
+				t.Fatalf("Encoder.Encode(): %v", err)
+			}
+
+			if diff := cmp.Diff(buf.String(), tt.output); diff != "" {
+				t.Errorf("Encoder.Encode(), differs: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Encode_RoundTrip(t *testing.T) {
+	styles := map[string]Style{
+		"folded": StyleFolded,
+		"flat":   StyleFlat,
+	}
+
+	for name, tt := range tests {
+		if tt.err != nil {
+			continue
+		}
+		for styleName, style := range styles {
+			tt := tt
+			style := style
+			t.Run(name+"/"+styleName, func(t *testing.T) {
+				var buf bytes.Buffer
+				e := NewEncoder(&buf)
+				e.SetStyle(style)
+				if err := e.Encode(tt.mod); err != nil {
+					t.Fatalf("Encoder.Encode(): %v", err)
+				}
+
+				m, err := NewDecoder(&buf).Decode()
+				if err != nil {
+					t.Fatalf("Decoder.Decode(): %v", err)
+				}
+
+				if diff := cmp.Diff(m, tt.mod); diff != "" {
+					t.Errorf("Decoder.Decode(), differs: (-got +want)\n%s", diff)
+				}
+			})
+		}
+	}
+}
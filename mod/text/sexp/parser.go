@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
 	"unicode"
@@ -21,6 +23,7 @@ const (
 	NodeNil NodeType = iota
 	NodeSymbol
 	NodeInt
+	NodeFloat
 	NodeString
 	NodeCell
 )
@@ -28,8 +31,10 @@ const (
 type Node struct {
 	Type  NodeType
 	Value any
-	Car   *Node
-	Cdr   *Node
+	// Text is the literal of an int node, which is also read as a float.
+	Text string
+	Car  *Node
+	Cdr  *Node
 }
 
 func (node *Node) SymbolValue() (string, bool) {
@@ -50,6 +55,47 @@ func (node *Node) IntValue() (int64, bool) {
 	return n, true
 }
 
+// Float32Value returns the value of a float or integer node as a float32.
+// The literals are rounded to float32 directly from their text, so that
+// they are not rounded twice, and keep the sign of a negative zero.
+func (node *Node) Float32Value() (float32, bool) {
+	s, ok := node.numberText()
+	if !ok {
+		return 0, false
+	}
+
+	bits, ok := parseFloat(s, 32)
+	return math.Float32frombits(uint32(bits)), ok
+}
+
+// Float64Value returns the value of a float or integer node as a float64.
+func (node *Node) Float64Value() (float64, bool) {
+	s, ok := node.numberText()
+	if !ok {
+		return 0, false
+	}
+
+	bits, ok := parseFloat(s, 64)
+	return math.Float64frombits(bits), ok
+}
+
+// numberText returns the literal of a float or integer node.
+func (node *Node) numberText() (string, bool) {
+	if node == nil {
+		return "", false
+	}
+
+	switch node.Type {
+	case NodeInt:
+		return node.Text, true
+	case NodeFloat:
+		s, _ := node.Value.(string)
+		return s, true
+	}
+
+	return "", false
+}
+
 func (node *Node) StringValue() (string, bool) {
 	if node == nil || node.Type != NodeString {
 		return "", false
@@ -89,11 +135,13 @@ func (node *Node) String() string {
 		fmt.Fprint(&buf, node.Value)
 	case NodeInt:
 		fmt.Fprint(&buf, node.Value)
+	case NodeFloat:
+		fmt.Fprint(&buf, node.Value)
 	case NodeString:
 		fmt.Fprintf(&buf, "%q", node.Value)
 	}
 
-	return ""
+	return buf.String()
 }
 
 type Parser struct {
@@ -212,6 +260,8 @@ func (p *Parser) parseList() (*Node, error) {
 	return node, nil
 }
 
+// parseString parses a string literal, which is a sequence of bytes that
+// can be written with escapes like \hh as well as UTF-8 characters.
 func (p *Parser) parseString() (*Node, error) {
 	var s strings.Builder
 
@@ -224,32 +274,48 @@ func (p *Parser) parseString() (*Node, error) {
 		if r == '"' {
 			break
 		}
+		if r < 0x20 || r == 0x7f {
+			return nil, ErrInvalidFormat
+		}
+
+		if r != '\\' {
+			s.WriteRune(r)
+			continue
+		}
 
-		if r == '\\' {
-			r, _, err = p.r.ReadRune()
+		r, _, err = p.r.ReadRune()
+		if err != nil {
+			return nil, handleError(err)
+		}
+
+		switch r {
+		case 't':
+			s.WriteByte('\t')
+		case 'n':
+			s.WriteByte('\n')
+		case 'r':
+			s.WriteByte('\r')
+		case '"', '\'', '\\':
+			s.WriteRune(r)
+		case 'u':
+			c, err := p.parseUnicodeEscape()
+			if err != nil {
+				return nil, err
+			}
+			s.WriteRune(c)
+		default:
+			// a byte of two hex digits
+			r2, _, err := p.r.ReadRune()
 			if err != nil {
 				return nil, handleError(err)
 			}
-
-			switch r {
-			case '\\':
-				r = '\\'
-			case 'n':
-				r = '\n'
-			case 'r':
-				r = '\n'
-			case 't':
-				r = '\t'
-			case 'v':
-				r = '\v'
-			case 'b':
-				r = '\b'
-			case 'f':
-				r = '\f'
-			case '"':
+			hi, ok1 := hexDigit(r)
+			lo, ok2 := hexDigit(r2)
+			if !ok1 || !ok2 {
+				return nil, ErrInvalidFormat
 			}
+			s.WriteByte(byte(hi<<4 | lo))
 		}
-		s.WriteRune(r)
 	}
 
 	return &Node{
@@ -258,6 +324,49 @@ func (p *Parser) parseString() (*Node, error) {
 	}, nil
 }
 
+// parseUnicodeEscape parses {hex} after \u, which is a Unicode scalar value.
+func (p *Parser) parseUnicodeEscape() (rune, error) {
+	r, _, err := p.r.ReadRune()
+	if err != nil {
+		return 0, handleError(err)
+	}
+	if r != '{' {
+		return 0, ErrInvalidFormat
+	}
+
+	var b strings.Builder
+	for {
+		r, _, err := p.r.ReadRune()
+		if err != nil {
+			return 0, handleError(err)
+		}
+		if r == '}' {
+			break
+		}
+		b.WriteRune(r)
+	}
+
+	n, ok := parseInt("0x" + b.String())
+	if !ok || n < 0 || n >= 0x110000 || (n >= 0xd800 && n < 0xe000) {
+		return 0, ErrInvalidFormat
+	}
+
+	return rune(n), nil
+}
+
+func hexDigit(r rune) (byte, bool) {
+	switch {
+	case r >= '0' && r <= '9':
+		return byte(r - '0'), true
+	case r >= 'a' && r <= 'f':
+		return byte(r - 'a' + 10), true
+	case r >= 'A' && r <= 'F':
+		return byte(r - 'A' + 10), true
+	}
+
+	return 0, false
+}
+
 func (p *Parser) parsePrimitive() (*Node, error) {
 	var b strings.Builder
 
@@ -283,17 +392,20 @@ func (p *Parser) parsePrimitive() (*Node, error) {
 		}, nil
 	}
 
-	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+	if n, ok := parseInt(s); ok {
 		return &Node{
 			Type:  NodeInt,
 			Value: n,
+			Text:  s,
 		}, nil
 	}
 
-	if f, err := strconv.ParseFloat(s, 64); err == nil {
+	// float literals are kept as text, because how they are rounded depends
+	// on the type of the constant
+	if isFloat(s) {
 		return &Node{
-			Type:  NodeInt,
-			Value: f,
+			Type:  NodeFloat,
+			Value: s,
 		}, nil
 	}
 
@@ -303,6 +415,116 @@ func (p *Parser) parsePrimitive() (*Node, error) {
 	}, nil
 }
 
+// ParseInt parses s as an integer literal, which is also used for the
+// values of keywords like offset=N.
+func ParseInt(s string) (int64, bool) {
+	return parseInt(s)
+}
+
+// parseInt parses an integer literal, which is a decimal or hexadecimal
+// number with an optional sign and underscores between digits. An unsigned
+// literal up to the maximum uint64 wraps around into an int64.
+func parseInt(s string) (int64, bool) {
+	neg := false
+	if strings.HasPrefix(s, "-") {
+		neg = true
+		s = s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+
+	base := 10
+	if strings.HasPrefix(s, "0x") {
+		base = 16
+		s = s[2:]
+	}
+
+	if s == "" || s[0] == '_' || s[len(s)-1] == '_' || strings.Contains(s, "__") {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(strings.ReplaceAll(s, "_", ""), base, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	if neg {
+		if n > 1<<63 {
+			return 0, false
+		}
+		return -int64(n), true
+	}
+
+	return int64(n), true
+}
+
+var (
+	regexpDecimalFloat = regexp.MustCompile(`^[+-]?[0-9](_?[0-9])*(\.([0-9](_?[0-9])*)?)?([eE][+-]?[0-9](_?[0-9])*)?$`)
+	regexpHexFloat     = regexp.MustCompile(`^[+-]?0x[0-9A-Fa-f](_?[0-9A-Fa-f])*(\.([0-9A-Fa-f](_?[0-9A-Fa-f])*)?)?([pP][+-]?[0-9](_?[0-9])*)?$`)
+	regexpInfNaN       = regexp.MustCompile(`^[+-]?(inf|nan(:0x[0-9A-Fa-f](_?[0-9A-Fa-f])*)?)$`)
+)
+
+// isFloat reports whether s is a float literal.
+func isFloat(s string) bool {
+	return regexpDecimalFloat.MatchString(s) || regexpHexFloat.MatchString(s) || regexpInfNaN.MatchString(s)
+}
+
+// parseFloat parses a float literal and returns the bits of the float of the
+// given size. Besides decimal and hexadecimal numbers, the literal can be
+// inf, nan or nan:0x followed by the payload of the NaN.
+func parseFloat(s string, bitSize int) (uint64, bool) {
+	if !isFloat(s) {
+		return 0, false
+	}
+
+	var signBit, expBits, fracBits uint64
+	if bitSize == 32 {
+		signBit, expBits, fracBits = 1<<31, 0xff<<23, 1<<23-1
+	} else {
+		signBit, expBits, fracBits = 1<<63, 0x7ff<<52, 1<<52-1
+	}
+
+	var sign uint64
+	body := s
+	if strings.HasPrefix(body, "-") {
+		sign = signBit
+		body = body[1:]
+	} else if strings.HasPrefix(body, "+") {
+		body = body[1:]
+	}
+
+	switch {
+	case body == "inf":
+		return sign | expBits, true
+	case body == "nan":
+		// canonical NaN, which has only the most significant bit of the
+		// fraction set
+		return sign | expBits | (fracBits+1)>>1, true
+	case strings.HasPrefix(body, "nan:"):
+		payload, err := strconv.ParseUint(strings.ReplaceAll(body[len("nan:0x"):], "_", ""), 16, 64)
+		if err != nil || payload == 0 || payload > fracBits {
+			return 0, false
+		}
+		return sign | expBits | payload, true
+	}
+
+	body = strings.ReplaceAll(s, "_", "")
+	if strings.Contains(body, "0x") && !strings.ContainsAny(body, "pP") {
+		// a hexadecimal float without an exponent
+		body += "p0"
+	}
+
+	f, err := strconv.ParseFloat(body, bitSize)
+	if err != nil {
+		return 0, false
+	}
+	if bitSize == 32 {
+		return uint64(math.Float32bits(float32(f))), true
+	}
+
+	return math.Float64bits(f), true
+}
+
 func isPrimitive(r rune) bool {
 	if r >= unicode.MaxASCII {
 		return false
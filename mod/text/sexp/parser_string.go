@@ -11,13 +11,14 @@ func _() {
 	_ = x[NodeNil-0]
 	_ = x[NodeSymbol-1]
 	_ = x[NodeInt-2]
-	_ = x[NodeString-3]
-	_ = x[NodeCell-4]
+	_ = x[NodeFloat-3]
+	_ = x[NodeString-4]
+	_ = x[NodeCell-5]
 }
 
-const _NodeType_name = "NodeNilNodeSymbolNodeIntNodeStringNodeCell"
+const _NodeType_name = "NodeNilNodeSymbolNodeIntNodeFloatNodeStringNodeCell"
 
-var _NodeType_index = [...]uint8{0, 7, 17, 24, 34, 42}
+var _NodeType_index = [...]uint8{0, 7, 17, 24, 33, 43, 51}
 
 func (i NodeType) String() string {
 	if i < 0 || i >= NodeType(len(_NodeType_index)-1) {
@@ -2,6 +2,7 @@ package sexp
 
 import (
 	"io"
+	"math"
 	"strings"
 	"testing"
 
@@ -80,6 +81,7 @@ var tests = map[string]struct {
 									Car: &Node{ // 5
 										Type:  NodeInt,
 										Value: int64(5),
+										Text:  "5",
 									},
 									Cdr: &Node{
 										Type: NodeCell,
@@ -92,6 +94,7 @@ var tests = map[string]struct {
 											Car: &Node{ // 20
 												Type:  NodeInt,
 												Value: int64(20),
+												Text:  "20",
 											},
 											Cdr: &Node{
 												Type: NodeCell,
@@ -110,6 +113,7 @@ var tests = map[string]struct {
 														Car: &Node{ // 4
 															Type:  NodeInt,
 															Value: int64(4),
+															Text:  "4",
 														},
 														Cdr: &Node{
 															Type: NodeCell,
@@ -128,6 +132,7 @@ var tests = map[string]struct {
 																	Car: &Node{ // 3
 																		Type:  NodeInt,
 																		Value: int64(3),
+																		Text:  "3",
 																	},
 																	Cdr: &Node{
 																		Type: NodeCell,
@@ -146,6 +151,7 @@ var tests = map[string]struct {
 																				Car: &Node{ // 7
 																					Type:  NodeInt,
 																					Value: int64(7),
+																					Text:  "7",
 																				},
 																				Cdr: &Node{
 																					Type: NodeCell,
@@ -176,6 +182,7 @@ var tests = map[string]struct {
 																									Car: &Node{ // 0
 																										Type:  NodeInt,
 																										Value: int64(0),
+																										Text:  "0",
 																									},
 																								},
 																							},
@@ -253,6 +260,14 @@ var tests = map[string]struct {
 		},
 		err: nil,
 	},
+	"string escapes": {
+		input: `"a\t\"\'\\\41\u{1F600}\ff"`,
+		node: &Node{
+			Type:  NodeString,
+			Value: "a\t\"'\\A\U0001F600\xff",
+		},
+		err: nil,
+	},
 	"unexpected EOF 01": {
 		input: "(aaa bbb",
 		node:  nil,
@@ -293,6 +308,16 @@ var tests = map[string]struct {
 		node:  nil,
 		err:   ErrInvalidFormat,
 	},
+	"invalid escape": {
+		input: `"\x"`,
+		node:  nil,
+		err:   ErrInvalidFormat,
+	},
+	"invalid unicode escape": {
+		input: `"\u{d800}"`,
+		node:  nil,
+		err:   ErrInvalidFormat,
+	},
 }
 
 func Test_Parser(t *testing.T) {
@@ -311,3 +336,106 @@ func Test_Parser(t *testing.T) {
 		})
 	}
 }
+
+var intTests = []struct {
+	input string
+	n     int64
+	ok    bool
+}{
+	{input: "0", n: 0, ok: true},
+	{input: "-42", n: -42, ok: true},
+	{input: "+42", n: 42, ok: true},
+	{input: "1_000_000", n: 1000000, ok: true},
+	{input: "0x7f", n: 127, ok: true},
+	{input: "-0x8000_0000_0000_0000", n: -1 << 63, ok: true},
+	{input: "0xffffffffffffffff", n: -1, ok: true},
+	{input: "18446744073709551615", n: -1, ok: true},
+	{input: "18446744073709551616", ok: false},
+	{input: "-0x8000000000000001", ok: false},
+	{input: "_1", ok: false},
+	{input: "1__0", ok: false},
+	{input: "0x", ok: false},
+	{input: "1.5", ok: false},
+}
+
+func Test_parseInt(t *testing.T) {
+	for _, tt := range intTests {
+		n, ok := parseInt(tt.input)
+		if ok != tt.ok || n != tt.n {
+			t.Errorf("parseInt(%q): got (%d, %v), want (%d, %v)", tt.input, n, ok, tt.n, tt.ok)
+		}
+	}
+}
+
+var floatTests = []struct {
+	input   string
+	bitSize int
+	bits    uint64
+	ok      bool
+}{
+	{input: "1.5", bitSize: 32, bits: 0x3fc00000, ok: true},
+	{input: "-0.0", bitSize: 32, bits: 0x80000000, ok: true},
+	{input: "1e10", bitSize: 64, bits: 0x4202a05f20000000, ok: true},
+	{input: "1_000.5", bitSize: 64, bits: 0x408f440000000000, ok: true},
+	{input: "0x1.8p1", bitSize: 32, bits: 0x40400000, ok: true},
+	{input: "0x1.8", bitSize: 64, bits: 0x3ff8000000000000, ok: true},
+	{input: "-0x1p-149", bitSize: 32, bits: 0x80000001, ok: true},
+	{input: "0.1", bitSize: 32, bits: 0x3dcccccd, ok: true},
+	{input: "0.1", bitSize: 64, bits: 0x3fb999999999999a, ok: true},
+	{input: "-0", bitSize: 32, bits: 0x80000000, ok: true},
+	{input: "-0", bitSize: 64, bits: 0x8000000000000000, ok: true},
+	{input: "18446744073709551615", bitSize: 64, bits: 0x43f0000000000000, ok: true},
+	{input: "0x8000000000000000", bitSize: 32, bits: 0x5f000000, ok: true},
+	{input: "inf", bitSize: 32, bits: 0x7f800000, ok: true},
+	{input: "-inf", bitSize: 64, bits: 0xfff0000000000000, ok: true},
+	{input: "nan", bitSize: 32, bits: 0x7fc00000, ok: true},
+	{input: "-nan", bitSize: 64, bits: 0xfff8000000000000, ok: true},
+	{input: "nan:0x1", bitSize: 32, bits: 0x7f800001, ok: true},
+	{input: "nan:0xf_ffff_ffff_ffff", bitSize: 64, bits: 0x7fffffffffffffff, ok: true},
+	{input: "nan:0x800000", bitSize: 32, ok: false},
+	{input: "nan:0x0", bitSize: 64, ok: false},
+	{input: "1e39", bitSize: 32, ok: false},
+	{input: ".5", bitSize: 64, ok: false},
+	{input: "1._5", bitSize: 64, ok: false},
+	{input: "infinity", bitSize: 64, ok: false},
+	{input: "+-inf", bitSize: 64, ok: false},
+}
+
+func Test_parseFloat(t *testing.T) {
+	for _, tt := range floatTests {
+		bits, ok := parseFloat(tt.input, tt.bitSize)
+		if ok != tt.ok || bits != tt.bits {
+			t.Errorf("parseFloat(%q, %d): got (%#x, %v), want (%#x, %v)", tt.input, tt.bitSize, bits, ok, tt.bits, tt.ok)
+		}
+	}
+}
+
+func Test_Node_FloatValue(t *testing.T) {
+	for _, tt := range floatTests {
+		if !tt.ok {
+			continue
+		}
+
+		// integer literals are parsed as int nodes
+		list, err := New(strings.NewReader("(" + tt.input + ")")).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		node := list.Car
+
+		var bits uint64
+		var ok bool
+		if tt.bitSize == 32 {
+			var f float32
+			f, ok = node.Float32Value()
+			bits = uint64(math.Float32bits(f))
+		} else {
+			var f float64
+			f, ok = node.Float64Value()
+			bits = math.Float64bits(f)
+		}
+		if !ok || bits != tt.bits {
+			t.Errorf("Node.Float%dValue() of %q: got (%#x, %v), want (%#x, true)", tt.bitSize, tt.input, bits, ok, tt.bits)
+		}
+	}
+}
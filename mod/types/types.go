@@ -10,8 +10,16 @@ const (
 	I64    Type = "i64"
 	F32    Type = "f32"
 	F64    Type = "f64"
+	// FuncRef is the type of references to functions, which is the element
+	// type of tables.
+	FuncRef Type = "funcref"
 )
 
+// IsReference reports whether the type is a reference type.
+func (typ Type) IsReference() bool {
+	return typ == FuncRef
+}
+
 type ID string
 
 var regexpID = regexp.MustCompile("^\\$[0-9A-Za-z!#$%&'*+\\-,/:<=>?@\\\\^_`|~]+$")
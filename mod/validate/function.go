@@ -0,0 +1,641 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kechako/wasmexec/mod"
+	"github.com/kechako/wasmexec/mod/instruction"
+	"github.com/kechako/wasmexec/mod/types"
+)
+
+// errStackEmpty is the cause of a type mismatch when an operand is popped
+// from the empty stack of the current frame.
+var errStackEmpty = errors.New("stack is empty")
+
+// ctrlFrame is an entry of the control stack.
+type ctrlFrame struct {
+	// name of the block instruction, which is empty for the function body
+	name    instruction.InstructionName
+	block   *mod.Block
+	params  []types.Type
+	results []types.Type
+	// height of the operand stack at the start of the frame
+	height int
+	// whether the rest of the frame is unreachable
+	unreachable bool
+}
+
+type functionValidator struct {
+	m     *mod.Module
+	f     *mod.Function
+	field string
+	// whether f is a constant expression rather than a function
+	constExpr bool
+	// functions declared to be referred by ref.func
+	refs map[int]bool
+
+	vals  []types.Type
+	ctrls []*ctrlFrame
+
+	// position and name of the current instruction
+	pos  int
+	name instruction.InstructionName
+}
+
+func (v *functionValidator) Validate() error {
+	if err := v.validateLocals(); err != nil {
+		return fieldError(v.field, err)
+	}
+
+	v.pos = -1
+	v.pushCtrl("", nil, nil, resultTypes(v.f.Results))
+	if err := v.validateInstructions(v.f.Instructions); err != nil {
+		return err
+	}
+	if _, err := v.popCtrl(); err != nil {
+		body := "function"
+		if v.constExpr {
+			body = "constant expression"
+		}
+		return fieldError(v.field, fmt.Errorf("%w at end of %s", err, body))
+	}
+
+	return nil
+}
+
+func (v *functionValidator) validateLocals() error {
+	ids := make(map[types.ID]bool)
+	for _, list := range [][]*mod.Local{v.f.Parameters, v.f.Locals} {
+		for _, l := range list {
+			if l.ID.IsEmpty() {
+				continue
+			}
+			if ids[l.ID] {
+				return fmt.Errorf("%w %s", ErrDuplicateID, l.ID)
+			}
+			ids[l.ID] = true
+		}
+	}
+
+	return nil
+}
+
+func (v *functionValidator) validateInstructions(instructions []instruction.Instruction) error {
+	for _, i := range instructions {
+		v.pos++
+		v.name = i.Name()
+
+		if err := v.validateInstruction(i); err != nil {
+			// errors in nested blocks are already reported
+			var e *Error
+			if errors.As(err, &e) {
+				return err
+			}
+			return &Error{
+				Field:       v.field,
+				Instruction: v.pos,
+				Name:        v.name,
+				Err:         err,
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *functionValidator) validateInstruction(i instruction.Instruction) error {
+	switch i := i.(type) {
+	case *instruction.I32Instruction:
+		if i.Instruction == instruction.I32Const && len(i.Values) != 1 {
+			return ErrInvalidInstruction
+		}
+	case *instruction.I64Instruction:
+		if i.Instruction == instruction.I64Const && len(i.Values) != 1 {
+			return ErrInvalidInstruction
+		}
+	case *instruction.F32Instruction:
+		if i.Instruction == instruction.F32Const && len(i.Values) != 1 {
+			return ErrInvalidInstruction
+		}
+	case *instruction.F64Instruction:
+		if i.Instruction == instruction.F64Const && len(i.Values) != 1 {
+			return ErrInvalidInstruction
+		}
+	case *instruction.ParametricInstruction:
+		switch i.Instruction {
+		case instruction.Drop:
+			_, err := v.popVal()
+			return err
+		}
+	case *instruction.ReferenceInstruction:
+		return v.validateReferenceInstruction(i)
+	case *instruction.VariableInstruction:
+		return v.validateVariableInstruction(i)
+	case *instruction.TableInstruction:
+		return v.validateTableInstruction(i)
+	case *instruction.MemoryInstruction:
+		if err := v.validateMemoryInstruction(i); err != nil {
+			return err
+		}
+	case *instruction.ControlInstruction:
+		switch i.Instruction {
+		case instruction.Unreachable:
+			v.setUnreachable()
+			return nil
+		case instruction.Nop:
+			return nil
+		case instruction.Return:
+			if err := v.popVals(resultTypes(v.f.Results)); err != nil {
+				return err
+			}
+			v.setUnreachable()
+			return nil
+		}
+	case *instruction.BranchInstruction:
+		return v.validateBranchInstruction(i)
+	case *instruction.CallInstruction:
+		return v.validateCallInstruction(i)
+	case *instruction.CallIndirectInstruction:
+		return v.validateCallIndirectInstruction(i)
+	case *instruction.BlockInstruction:
+		return v.validateBlockInstruction(i)
+	}
+
+	sig, ok := signatures[i.Name()]
+	if !ok {
+		return ErrUnknownInstruction
+	}
+	if err := v.popVals(sig.params); err != nil {
+		return err
+	}
+	v.pushVals(sig.results)
+
+	return nil
+}
+
+func (v *functionValidator) validateReferenceInstruction(i *instruction.ReferenceInstruction) error {
+	switch i.Instruction {
+	case instruction.RefNull:
+		if !i.Type.IsReference() {
+			return fmt.Errorf("%w: %s is not a reference type", ErrInvalidInstruction, i.Type)
+		}
+		v.pushVal(i.Type)
+	case instruction.RefIsNull:
+		typ, err := v.popVal()
+		if err != nil {
+			return err
+		}
+		if typ != types.Unkown && !typ.IsReference() {
+			return fmt.Errorf("%w: expected a reference but got %s", ErrTypeMismatch, typ)
+		}
+		v.pushVal(types.I32)
+	case instruction.RefFunc:
+		idx, ok := v.m.FunctionIndex(i.Function)
+		if !ok {
+			return fmt.Errorf("%w %s", ErrUnknownFunction, formatIndex(i.Function))
+		}
+		if !v.refs[idx] {
+			return fmt.Errorf("%w %s", ErrUndeclaredReference, formatIndex(i.Function))
+		}
+		v.pushVal(types.FuncRef)
+	default:
+		return ErrUnknownInstruction
+	}
+
+	return nil
+}
+
+func (v *functionValidator) validateVariableInstruction(i *instruction.VariableInstruction) error {
+	if i.Instruction == instruction.GlobalGet || i.Instruction == instruction.GlobalSet {
+		return v.validateGlobalInstruction(i)
+	}
+
+	idx, ok := v.f.LocalIndex(i.Index)
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownLocal, formatIndex(i.Index))
+	}
+
+	var typ types.Type
+	if idx < len(v.f.Parameters) {
+		typ = v.f.Parameters[idx].Type
+	} else {
+		typ = v.f.Locals[idx-len(v.f.Parameters)].Type
+	}
+
+	switch i.Instruction {
+	case instruction.LocalGet:
+		v.pushVal(typ)
+	case instruction.LocalSet:
+		if _, err := v.popExpect(typ); err != nil {
+			return err
+		}
+	case instruction.LocalTee:
+		if _, err := v.popExpect(typ); err != nil {
+			return err
+		}
+		v.pushVal(typ)
+	default:
+		return ErrUnknownInstruction
+	}
+
+	return nil
+}
+
+func (v *functionValidator) validateGlobalInstruction(i *instruction.VariableInstruction) error {
+	idx, ok := v.m.GlobalIndex(i.Index)
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownGlobal, formatIndex(i.Index))
+	}
+	g := v.m.Globals[idx]
+
+	if i.Instruction == instruction.GlobalGet {
+		v.pushVal(g.Type)
+		return nil
+	}
+
+	if !g.Mutable {
+		return fmt.Errorf("%w %s", ErrImmutableGlobal, formatIndex(i.Index))
+	}
+	if _, err := v.popExpect(g.Type); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (v *functionValidator) table(idx types.Index) (*mod.Table, error) {
+	n, ok := v.m.TableIndex(idx)
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownTable, formatIndex(idx))
+	}
+
+	return v.m.Tables[n], nil
+}
+
+func (v *functionValidator) element(idx types.Index) (*mod.Element, error) {
+	n, ok := v.m.ElementIndex(idx)
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownElement, formatIndex(idx))
+	}
+
+	return v.m.Elements[n], nil
+}
+
+func (v *functionValidator) validateTableInstruction(i *instruction.TableInstruction) error {
+	if i.Instruction == instruction.ElemDrop {
+		_, err := v.element(i.Elem)
+		return err
+	}
+
+	t, err := v.table(i.Table)
+	if err != nil {
+		return err
+	}
+
+	// the signatures depend on the type of the table
+	var sig signature
+	switch i.Instruction {
+	case instruction.TableGet:
+		sig = signature{params: []types.Type{types.I32}, results: []types.Type{t.Type}}
+	case instruction.TableSet:
+		sig = signature{params: []types.Type{types.I32, t.Type}}
+	case instruction.TableSize:
+		sig = constop(types.I32)
+	case instruction.TableGrow:
+		sig = signature{params: []types.Type{t.Type, types.I32}, results: []types.Type{types.I32}}
+	case instruction.TableFill:
+		sig = signature{params: []types.Type{types.I32, t.Type, types.I32}}
+	case instruction.TableCopy:
+		src, err := v.table(i.Source)
+		if err != nil {
+			return err
+		}
+		if src.Type != t.Type {
+			return fmt.Errorf("%w: expected %s but got %s", ErrTypeMismatch, t.Type, src.Type)
+		}
+		sig = signature{params: []types.Type{types.I32, types.I32, types.I32}}
+	case instruction.TableInit:
+		e, err := v.element(i.Elem)
+		if err != nil {
+			return err
+		}
+		if e.Type != t.Type {
+			return fmt.Errorf("%w: expected %s but got %s", ErrTypeMismatch, t.Type, e.Type)
+		}
+		sig = signature{params: []types.Type{types.I32, types.I32, types.I32}}
+	default:
+		return ErrUnknownInstruction
+	}
+
+	if err := v.popVals(sig.params); err != nil {
+		return err
+	}
+	v.pushVals(sig.results)
+
+	return nil
+}
+
+func (v *functionValidator) validateMemoryInstruction(i *instruction.MemoryInstruction) error {
+	if i.Instruction == instruction.MemoryInit || i.Instruction == instruction.DataDrop {
+		if _, ok := v.m.DataIndex(i.Data); !ok {
+			return fmt.Errorf("%w %s", ErrUnknownData, formatIndex(i.Data))
+		}
+	}
+	if i.Instruction != instruction.DataDrop && len(v.m.Memories) == 0 {
+		return fmt.Errorf("%w 0", ErrUnknownMemory)
+	}
+	if i.Instruction.AccessSize() != 0 && i.Align > i.Instruction.NaturalAlignment() {
+		return ErrInvalidAlignment
+	}
+
+	return nil
+}
+
+func (v *functionValidator) validateCallInstruction(i *instruction.CallInstruction) error {
+	idx, ok := v.m.FunctionIndex(i.Index)
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownFunction, formatIndex(i.Index))
+	}
+
+	f := v.m.Functions[idx]
+	if err := v.popVals(localTypes(f.Parameters)); err != nil {
+		return err
+	}
+	v.pushVals(resultTypes(f.Results))
+
+	return nil
+}
+
+func (v *functionValidator) validateCallIndirectInstruction(i *instruction.CallIndirectInstruction) error {
+	t, err := v.table(i.Table)
+	if err != nil {
+		return err
+	}
+	if t.Type != types.FuncRef {
+		return fmt.Errorf("%w: expected %s but got %s", ErrTypeMismatch, types.FuncRef, t.Type)
+	}
+
+	n, ok := v.m.TypeIndex(i.Type)
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownType, formatIndex(i.Type))
+	}
+	typ := v.m.Types[n]
+
+	// the operand is the index in the table
+	if _, err := v.popExpect(types.I32); err != nil {
+		return err
+	}
+	if err := v.popVals(typ.Parameters); err != nil {
+		return err
+	}
+	v.pushVals(typ.Results)
+
+	return nil
+}
+
+func (v *functionValidator) validateBlockInstruction(i *instruction.BlockInstruction) error {
+	block, ok := v.f.Block(i.Block)
+	if !ok {
+		return fmt.Errorf("%w %d", ErrUnknownBlock, i.Block)
+	}
+
+	if block.Else != nil && i.Instruction != instruction.If {
+		return ErrInvalidInstruction
+	}
+
+	if i.Instruction == instruction.If {
+		if _, err := v.popExpect(types.I32); err != nil {
+			return err
+		}
+	}
+
+	params := localTypes(block.Parameters)
+	results := resultTypes(block.Results)
+	if err := v.popVals(params); err != nil {
+		return err
+	}
+
+	// errors at the end of the block are reported at the block instruction
+	pos, name := v.pos, v.name
+
+	v.pushCtrl(i.Instruction, block, params, results)
+	if err := v.validateInstructions(block.Instructions); err != nil {
+		return err
+	}
+	if err := v.popBlockCtrl(i.Block, pos, name); err != nil {
+		return err
+	}
+
+	if i.Instruction == instruction.If {
+		// an if without else has an empty else branch, which passes the
+		// params through as the results
+		v.pushCtrl(i.Instruction, block, params, results)
+		if err := v.validateInstructions(block.Else); err != nil {
+			return err
+		}
+		if err := v.popBlockCtrl(i.Block, pos, name); err != nil {
+			return err
+		}
+	}
+	v.pushVals(results)
+
+	return nil
+}
+
+// popBlockCtrl pops the control frame of the n-th block, and reports an
+// error at the position of the block instruction.
+func (v *functionValidator) popBlockCtrl(n int, pos int, name instruction.InstructionName) error {
+	end := v.pos
+	v.pos, v.name = pos, name
+	if _, err := v.popCtrl(); err != nil {
+		return fmt.Errorf("%w at end of %s", err, formatBlock(v.f, n))
+	}
+	v.pos = end
+
+	return nil
+}
+
+func (v *functionValidator) validateBranchInstruction(i *instruction.BranchInstruction) error {
+	if len(i.Labels) == 0 || (i.Instruction != instruction.BrTable && len(i.Labels) != 1) {
+		return ErrInvalidInstruction
+	}
+
+	labels := make([][]types.Type, len(i.Labels))
+	for n, idx := range i.Labels {
+		ctrl, ok := v.label(idx)
+		if !ok {
+			return fmt.Errorf("%w %s", ErrUnknownLabel, formatIndex(idx))
+		}
+		labels[n] = ctrl.labelTypes()
+	}
+
+	switch i.Instruction {
+	case instruction.Br:
+		if err := v.popVals(labels[0]); err != nil {
+			return err
+		}
+		v.setUnreachable()
+	case instruction.BrIf:
+		if _, err := v.popExpect(types.I32); err != nil {
+			return err
+		}
+		if err := v.popVals(labels[0]); err != nil {
+			return err
+		}
+		v.pushVals(labels[0])
+	case instruction.BrTable:
+		if _, err := v.popExpect(types.I32); err != nil {
+			return err
+		}
+		defaultLabel := labels[len(labels)-1]
+		for _, label := range labels[:len(labels)-1] {
+			if len(label) != len(defaultLabel) {
+				return fmt.Errorf("%w: expected %s but got %s", ErrTypeMismatch, formatTypes(defaultLabel), formatTypes(label))
+			}
+			// the operands are checked against every label without being
+			// consumed
+			vals := v.vals
+			if err := v.popVals(label); err != nil {
+				return err
+			}
+			v.vals = vals
+		}
+		if err := v.popVals(defaultLabel); err != nil {
+			return err
+		}
+		v.setUnreachable()
+	default:
+		return ErrUnknownInstruction
+	}
+
+	return nil
+}
+
+// label returns the control frame of the label, which is a depth counted
+// from the innermost frame or the ID of a block.
+func (v *functionValidator) label(idx types.Index) (*ctrlFrame, bool) {
+	if idx.IsIndex() {
+		if idx.Index < 0 || idx.Index >= len(v.ctrls) {
+			return nil, false
+		}
+		return v.ctrls[len(v.ctrls)-1-idx.Index], true
+	}
+
+	for n := len(v.ctrls) - 1; n >= 0; n-- {
+		ctrl := v.ctrls[n]
+		if ctrl.block != nil && ctrl.block.Label == idx.ID {
+			return ctrl, true
+		}
+	}
+
+	return nil, false
+}
+
+func (v *functionValidator) pushVal(typ types.Type) {
+	v.vals = append(v.vals, typ)
+}
+
+func (v *functionValidator) pushVals(list []types.Type) {
+	v.vals = append(v.vals, list...)
+}
+
+// popVal pops an operand, which is types.Unkown if the stack is polymorphic
+// in unreachable code.
+func (v *functionValidator) popVal() (types.Type, error) {
+	ctrl := v.ctrls[len(v.ctrls)-1]
+	if len(v.vals) == ctrl.height {
+		if ctrl.unreachable {
+			return types.Unkown, nil
+		}
+		return types.Unkown, fmt.Errorf("%w: %v", ErrTypeMismatch, errStackEmpty)
+	}
+
+	typ := v.vals[len(v.vals)-1]
+	v.vals = v.vals[:len(v.vals)-1]
+
+	return typ, nil
+}
+
+func (v *functionValidator) popExpect(expect types.Type) (types.Type, error) {
+	actual, err := v.popVal()
+	if err != nil {
+		return types.Unkown, fmt.Errorf("%w: expected %s but %v", ErrTypeMismatch, expect, errStackEmpty)
+	}
+
+	if actual != expect && actual != types.Unkown && expect != types.Unkown {
+		return types.Unkown, fmt.Errorf("%w: expected %s but got %s", ErrTypeMismatch, expect, actual)
+	}
+
+	if actual == types.Unkown {
+		return expect, nil
+	}
+	return actual, nil
+}
+
+func (v *functionValidator) popVals(list []types.Type) error {
+	for i := len(list) - 1; i >= 0; i-- {
+		if _, err := v.popExpect(list[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *functionValidator) pushCtrl(name instruction.InstructionName, block *mod.Block, params, results []types.Type) {
+	v.ctrls = append(v.ctrls, &ctrlFrame{
+		name:    name,
+		block:   block,
+		params:  params,
+		results: results,
+		height:  len(v.vals),
+	})
+	v.pushVals(params)
+}
+
+func (v *functionValidator) popCtrl() (*ctrlFrame, error) {
+	ctrl := v.ctrls[len(v.ctrls)-1]
+	if err := v.popVals(ctrl.results); err != nil {
+		return nil, err
+	}
+	if len(v.vals) != ctrl.height {
+		return nil, fmt.Errorf("%w: expected %s but %d extra values remain", ErrTypeMismatch, formatTypes(ctrl.results), len(v.vals)-ctrl.height)
+	}
+	v.ctrls = v.ctrls[:len(v.ctrls)-1]
+
+	return ctrl, nil
+}
+
+// labelTypes returns the types of the operands a branch to the frame takes,
+// which are the params of a loop since it branches to the start.
+func (ctrl *ctrlFrame) labelTypes() []types.Type {
+	if ctrl.name == instruction.Loop {
+		return ctrl.params
+	}
+	return ctrl.results
+}
+
+func (v *functionValidator) setUnreachable() {
+	ctrl := v.ctrls[len(v.ctrls)-1]
+	v.vals = v.vals[:ctrl.height]
+	ctrl.unreachable = true
+}
+
+func localTypes(list []*mod.Local) []types.Type {
+	typs := make([]types.Type, len(list))
+	for i, l := range list {
+		typs[i] = l.Type
+	}
+
+	return typs
+}
+
+func resultTypes(list []*mod.Result) []types.Type {
+	typs := make([]types.Type, len(list))
+	for i, r := range list {
+		typs[i] = r.Type
+	}
+
+	return typs
+}
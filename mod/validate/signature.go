@@ -0,0 +1,222 @@
+package validate
+
+import (
+	"github.com/kechako/wasmexec/mod/instruction"
+	"github.com/kechako/wasmexec/mod/types"
+)
+
+// signature is the operand types an instruction pops and the result types
+// it pushes.
+type signature struct {
+	params  []types.Type
+	results []types.Type
+}
+
+func constop(t types.Type) signature {
+	return signature{results: []types.Type{t}}
+}
+
+func unop(t types.Type) signature {
+	return signature{params: []types.Type{t}, results: []types.Type{t}}
+}
+
+func binop(t types.Type) signature {
+	return signature{params: []types.Type{t, t}, results: []types.Type{t}}
+}
+
+func testop(t types.Type) signature {
+	return signature{params: []types.Type{t}, results: []types.Type{types.I32}}
+}
+
+func relop(t types.Type) signature {
+	return signature{params: []types.Type{t, t}, results: []types.Type{types.I32}}
+}
+
+func cvtop(from, to types.Type) signature {
+	return signature{params: []types.Type{from}, results: []types.Type{to}}
+}
+
+func loadop(t types.Type) signature {
+	return signature{params: []types.Type{types.I32}, results: []types.Type{t}}
+}
+
+func storeop(t types.Type) signature {
+	return signature{params: []types.Type{types.I32, t}}
+}
+
+// signatures holds the signatures of instructions whose types do not depend
+// on the module or the context.
+var signatures = map[instruction.InstructionName]signature{
+	instruction.I32Const:  constop(types.I32),
+	instruction.I32Clz:    unop(types.I32),
+	instruction.I32Ctz:    unop(types.I32),
+	instruction.I32Popcnt: unop(types.I32),
+	instruction.I32Add:    binop(types.I32),
+	instruction.I32Sub:    binop(types.I32),
+	instruction.I32Mul:    binop(types.I32),
+	instruction.I32DivS:   binop(types.I32),
+	instruction.I32DivU:   binop(types.I32),
+	instruction.I32RemS:   binop(types.I32),
+	instruction.I32RemU:   binop(types.I32),
+	instruction.I32And:    binop(types.I32),
+	instruction.I32Or:     binop(types.I32),
+	instruction.I32Xor:    binop(types.I32),
+	instruction.I32Shl:    binop(types.I32),
+	instruction.I32ShrS:   binop(types.I32),
+	instruction.I32ShrU:   binop(types.I32),
+	instruction.I32Rotl:   binop(types.I32),
+	instruction.I32Rotr:   binop(types.I32),
+	instruction.I32Eqz:    testop(types.I32),
+	instruction.I32Eq:     relop(types.I32),
+	instruction.I32Ne:     relop(types.I32),
+	instruction.I32LtS:    relop(types.I32),
+	instruction.I32LtU:    relop(types.I32),
+	instruction.I32GtS:    relop(types.I32),
+	instruction.I32GtU:    relop(types.I32),
+	instruction.I32LeS:    relop(types.I32),
+	instruction.I32LeU:    relop(types.I32),
+	instruction.I32GeS:    relop(types.I32),
+	instruction.I32GeU:    relop(types.I32),
+
+	instruction.I64Const:  constop(types.I64),
+	instruction.I64Clz:    unop(types.I64),
+	instruction.I64Ctz:    unop(types.I64),
+	instruction.I64Popcnt: unop(types.I64),
+	instruction.I64Add:    binop(types.I64),
+	instruction.I64Sub:    binop(types.I64),
+	instruction.I64Mul:    binop(types.I64),
+	instruction.I64DivS:   binop(types.I64),
+	instruction.I64DivU:   binop(types.I64),
+	instruction.I64RemS:   binop(types.I64),
+	instruction.I64RemU:   binop(types.I64),
+	instruction.I64And:    binop(types.I64),
+	instruction.I64Or:     binop(types.I64),
+	instruction.I64Xor:    binop(types.I64),
+	instruction.I64Shl:    binop(types.I64),
+	instruction.I64ShrS:   binop(types.I64),
+	instruction.I64ShrU:   binop(types.I64),
+	instruction.I64Rotl:   binop(types.I64),
+	instruction.I64Rotr:   binop(types.I64),
+	instruction.I64Eqz:    testop(types.I64),
+	instruction.I64Eq:     relop(types.I64),
+	instruction.I64Ne:     relop(types.I64),
+	instruction.I64LtS:    relop(types.I64),
+	instruction.I64LtU:    relop(types.I64),
+	instruction.I64GtS:    relop(types.I64),
+	instruction.I64GtU:    relop(types.I64),
+	instruction.I64LeS:    relop(types.I64),
+	instruction.I64LeU:    relop(types.I64),
+	instruction.I64GeS:    relop(types.I64),
+	instruction.I64GeU:    relop(types.I64),
+
+	instruction.F32Const:    constop(types.F32),
+	instruction.F32Abs:      unop(types.F32),
+	instruction.F32Neg:      unop(types.F32),
+	instruction.F32Ceil:     unop(types.F32),
+	instruction.F32Floor:    unop(types.F32),
+	instruction.F32Trunc:    unop(types.F32),
+	instruction.F32Nearest:  unop(types.F32),
+	instruction.F32Sqrt:     unop(types.F32),
+	instruction.F32Add:      binop(types.F32),
+	instruction.F32Sub:      binop(types.F32),
+	instruction.F32Mul:      binop(types.F32),
+	instruction.F32Div:      binop(types.F32),
+	instruction.F32Min:      binop(types.F32),
+	instruction.F32Max:      binop(types.F32),
+	instruction.F32Copysign: binop(types.F32),
+	instruction.F32Eq:       relop(types.F32),
+	instruction.F32Ne:       relop(types.F32),
+	instruction.F32Lt:       relop(types.F32),
+	instruction.F32Gt:       relop(types.F32),
+	instruction.F32Le:       relop(types.F32),
+	instruction.F32Ge:       relop(types.F32),
+
+	instruction.F64Const:    constop(types.F64),
+	instruction.F64Abs:      unop(types.F64),
+	instruction.F64Neg:      unop(types.F64),
+	instruction.F64Ceil:     unop(types.F64),
+	instruction.F64Floor:    unop(types.F64),
+	instruction.F64Trunc:    unop(types.F64),
+	instruction.F64Nearest:  unop(types.F64),
+	instruction.F64Sqrt:     unop(types.F64),
+	instruction.F64Add:      binop(types.F64),
+	instruction.F64Sub:      binop(types.F64),
+	instruction.F64Mul:      binop(types.F64),
+	instruction.F64Div:      binop(types.F64),
+	instruction.F64Min:      binop(types.F64),
+	instruction.F64Max:      binop(types.F64),
+	instruction.F64Copysign: binop(types.F64),
+	instruction.F64Eq:       relop(types.F64),
+	instruction.F64Ne:       relop(types.F64),
+	instruction.F64Lt:       relop(types.F64),
+	instruction.F64Gt:       relop(types.F64),
+	instruction.F64Le:       relop(types.F64),
+	instruction.F64Ge:       relop(types.F64),
+
+	instruction.I32WrapI64:        cvtop(types.I64, types.I32),
+	instruction.I32TruncF32S:      cvtop(types.F32, types.I32),
+	instruction.I32TruncF32U:      cvtop(types.F32, types.I32),
+	instruction.I32TruncF64S:      cvtop(types.F64, types.I32),
+	instruction.I32TruncF64U:      cvtop(types.F64, types.I32),
+	instruction.I64ExtendI32S:     cvtop(types.I32, types.I64),
+	instruction.I64ExtendI32U:     cvtop(types.I32, types.I64),
+	instruction.I64TruncF32S:      cvtop(types.F32, types.I64),
+	instruction.I64TruncF32U:      cvtop(types.F32, types.I64),
+	instruction.I64TruncF64S:      cvtop(types.F64, types.I64),
+	instruction.I64TruncF64U:      cvtop(types.F64, types.I64),
+	instruction.F32ConvertI32S:    cvtop(types.I32, types.F32),
+	instruction.F32ConvertI32U:    cvtop(types.I32, types.F32),
+	instruction.F32ConvertI64S:    cvtop(types.I64, types.F32),
+	instruction.F32ConvertI64U:    cvtop(types.I64, types.F32),
+	instruction.F32DemoteF64:      cvtop(types.F64, types.F32),
+	instruction.F64ConvertI32S:    cvtop(types.I32, types.F64),
+	instruction.F64ConvertI32U:    cvtop(types.I32, types.F64),
+	instruction.F64ConvertI64S:    cvtop(types.I64, types.F64),
+	instruction.F64ConvertI64U:    cvtop(types.I64, types.F64),
+	instruction.F64PromoteF32:     cvtop(types.F32, types.F64),
+	instruction.I32ReinterpretF32: cvtop(types.F32, types.I32),
+	instruction.I64ReinterpretF64: cvtop(types.F64, types.I64),
+	instruction.F32ReinterpretI32: cvtop(types.I32, types.F32),
+	instruction.F64ReinterpretI64: cvtop(types.I64, types.F64),
+	instruction.I32Extend8S:       cvtop(types.I32, types.I32),
+	instruction.I32Extend16S:      cvtop(types.I32, types.I32),
+	instruction.I64Extend8S:       cvtop(types.I64, types.I64),
+	instruction.I64Extend16S:      cvtop(types.I64, types.I64),
+	instruction.I64Extend32S:      cvtop(types.I64, types.I64),
+	instruction.I32TruncSatF32S:   cvtop(types.F32, types.I32),
+	instruction.I32TruncSatF32U:   cvtop(types.F32, types.I32),
+	instruction.I32TruncSatF64S:   cvtop(types.F64, types.I32),
+	instruction.I32TruncSatF64U:   cvtop(types.F64, types.I32),
+	instruction.I64TruncSatF32S:   cvtop(types.F32, types.I64),
+	instruction.I64TruncSatF32U:   cvtop(types.F32, types.I64),
+	instruction.I64TruncSatF64S:   cvtop(types.F64, types.I64),
+	instruction.I64TruncSatF64U:   cvtop(types.F64, types.I64),
+
+	instruction.I32Load:    loadop(types.I32),
+	instruction.I64Load:    loadop(types.I64),
+	instruction.F32Load:    loadop(types.F32),
+	instruction.F64Load:    loadop(types.F64),
+	instruction.I32Load8S:  loadop(types.I32),
+	instruction.I32Load8U:  loadop(types.I32),
+	instruction.I32Load16S: loadop(types.I32),
+	instruction.I32Load16U: loadop(types.I32),
+	instruction.I64Load8S:  loadop(types.I64),
+	instruction.I64Load8U:  loadop(types.I64),
+	instruction.I64Load16S: loadop(types.I64),
+	instruction.I64Load16U: loadop(types.I64),
+	instruction.I64Load32S: loadop(types.I64),
+	instruction.I64Load32U: loadop(types.I64),
+	instruction.I32Store:   storeop(types.I32),
+	instruction.I64Store:   storeop(types.I64),
+	instruction.F32Store:   storeop(types.F32),
+	instruction.F64Store:   storeop(types.F64),
+	instruction.I32Store8:  storeop(types.I32),
+	instruction.I32Store16: storeop(types.I32),
+	instruction.I64Store8:  storeop(types.I64),
+	instruction.I64Store16: storeop(types.I64),
+	instruction.I64Store32: storeop(types.I64),
+	instruction.MemorySize: constop(types.I32),
+	instruction.MemoryGrow: unop(types.I32),
+	instruction.MemoryInit: {params: []types.Type{types.I32, types.I32, types.I32}},
+	instruction.DataDrop:   {},
+}
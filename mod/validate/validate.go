@@ -0,0 +1,497 @@
+// Package validate implements the validation algorithm of the WebAssembly
+// specification, which checks a module is well-typed before it is run.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kechako/wasmexec/mod"
+	"github.com/kechako/wasmexec/mod/instruction"
+	"github.com/kechako/wasmexec/mod/types"
+)
+
+var (
+	ErrTypeMismatch       = errors.New("type mismatch")
+	ErrUnknownInstruction = errors.New("unknown instruction")
+	ErrInvalidInstruction = errors.New("invalid instruction")
+	ErrUnknownType        = errors.New("unknown type")
+	ErrUnknownLocal       = errors.New("unknown local")
+	ErrUnknownFunction    = errors.New("unknown function")
+	ErrUnknownBlock       = errors.New("unknown block")
+	ErrUnknownLabel       = errors.New("unknown label")
+	ErrUnknownTable       = errors.New("unknown table")
+	ErrUnknownMemory      = errors.New("unknown memory")
+	ErrUnknownGlobal      = errors.New("unknown global")
+	ErrUnknownElement     = errors.New("unknown elem segment")
+	ErrUnknownData        = errors.New("unknown data segment")
+	ErrImmutableGlobal    = errors.New("global is immutable")
+	ErrConstantRequired   = errors.New("constant expression required")
+	// ErrUndeclaredReference is the error of ref.func in a function for a
+	// function which is not referred outside of the functions.
+	ErrUndeclaredReference = errors.New("undeclared function reference")
+	ErrInvalidAlignment    = errors.New("alignment must not be larger than natural")
+	ErrInvalidLimits       = errors.New("invalid limits")
+	ErrMultipleMemories    = errors.New("multiple memories")
+	ErrDuplicateID         = errors.New("duplicate identifier")
+	ErrDuplicateExport     = errors.New("duplicate export name")
+)
+
+// Error is an error found by Validate with the position where it is found.
+type Error struct {
+	// Field is the module field, such as `func 0 $main` or `export "main"`.
+	Field string
+	// Instruction is the position of the instruction in the function body,
+	// where the instructions in blocks are counted in order as in the binary
+	// format, or -1 if the error is not caused by an instruction.
+	Instruction int
+	// Name is the name of the instruction.
+	Name instruction.InstructionName
+	Err  error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Field)
+	if e.Instruction >= 0 {
+		fmt.Fprintf(&b, ": instruction %d (%s)", e.Instruction, e.Name)
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Err.Error())
+
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Validate validates the module, and returns an *Error for the first
+// problem found.
+func Validate(m *mod.Module) error {
+	v := &validator{
+		m: m,
+	}
+
+	return v.Validate()
+}
+
+type validator struct {
+	m *mod.Module
+	// functions declared to be referred by ref.func
+	refs map[int]bool
+}
+
+func (v *validator) Validate() error {
+	v.refs = declaredRefs(v.m)
+
+	if err := v.validateTypes(); err != nil {
+		return err
+	}
+
+	ids := make(map[types.ID]bool)
+	for i, f := range v.m.Functions {
+		field := fmt.Sprintf("func %d", i)
+		if !f.ID.IsEmpty() {
+			field += " " + string(f.ID)
+
+			if ids[f.ID] {
+				return fieldError(field, ErrDuplicateID)
+			}
+			ids[f.ID] = true
+		}
+
+		n, ok := v.m.TypeIndex(f.Type)
+		if !ok {
+			return fieldError(field, fmt.Errorf("%w %s", ErrUnknownType, formatIndex(f.Type)))
+		}
+		if !v.m.Types[n].Equal(f.Signature()) {
+			return fieldError(field, fmt.Errorf("%w: the signature does not match type %s", ErrTypeMismatch, formatIndex(f.Type)))
+		}
+		// an imported function has no code
+		if f.Import != nil {
+			continue
+		}
+
+		fv := &functionValidator{
+			m:     v.m,
+			f:     f,
+			field: field,
+			refs:  v.refs,
+		}
+		if err := fv.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := v.validateTables(); err != nil {
+		return err
+	}
+
+	if err := v.validateMemories(); err != nil {
+		return err
+	}
+
+	if err := v.validateGlobals(); err != nil {
+		return err
+	}
+
+	if err := v.validateElements(); err != nil {
+		return err
+	}
+
+	if err := v.validateData(); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	for _, e := range v.m.Exports {
+		field := fmt.Sprintf("export %q", e.Name)
+
+		if names[e.Name] {
+			return fieldError(field, ErrDuplicateExport)
+		}
+		names[e.Name] = true
+
+		if err := v.validateExport(e); err != nil {
+			return fieldError(field, err)
+		}
+	}
+
+	if v.m.Start != nil {
+		if err := v.validateStart(*v.m.Start); err != nil {
+			return fieldError("start", err)
+		}
+	}
+
+	return nil
+}
+
+func (v *validator) validateExport(e *mod.Export) error {
+	switch e.Target {
+	case mod.ExportFunction:
+		if _, ok := v.m.FunctionIndex(e.Index); !ok {
+			return fmt.Errorf("%w %s", ErrUnknownFunction, formatIndex(e.Index))
+		}
+		return nil
+	case mod.ExportTable:
+		if _, ok := v.m.TableIndex(e.Index); !ok {
+			return fmt.Errorf("%w %s", ErrUnknownTable, formatIndex(e.Index))
+		}
+		return nil
+	case mod.ExportMemory:
+		if _, ok := v.m.MemoryIndex(e.Index); !ok {
+			return fmt.Errorf("%w %s", ErrUnknownMemory, formatIndex(e.Index))
+		}
+		return nil
+	case mod.ExportGlobal:
+		if _, ok := v.m.GlobalIndex(e.Index); !ok {
+			return fmt.Errorf("%w %s", ErrUnknownGlobal, formatIndex(e.Index))
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown export target %q", e.Target)
+}
+
+// validateStart checks the start function takes no parameters and returns
+// no results.
+func (v *validator) validateStart(idx types.Index) error {
+	n, ok := v.m.FunctionIndex(idx)
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownFunction, formatIndex(idx))
+	}
+	if sig := v.m.Functions[n].Signature(); len(sig.Parameters) > 0 || len(sig.Results) > 0 {
+		return fmt.Errorf("%w: the start function must have type [] -> []", ErrTypeMismatch)
+	}
+
+	return nil
+}
+
+func (v *validator) validateTypes() error {
+	ids := make(map[types.ID]bool)
+	for i, t := range v.m.Types {
+		field := fmt.Sprintf("type %d", i)
+		if !t.ID.IsEmpty() {
+			field += " " + string(t.ID)
+
+			if ids[t.ID] {
+				return fieldError(field, ErrDuplicateID)
+			}
+			ids[t.ID] = true
+		}
+	}
+
+	return nil
+}
+
+// declaredRefs returns the functions referred outside of the functions,
+// which ref.func in the functions can refer.
+func declaredRefs(m *mod.Module) map[int]bool {
+	refs := make(map[int]bool)
+	addRefs := func(expr []instruction.Instruction) {
+		for _, i := range expr {
+			if i, ok := i.(*instruction.ReferenceInstruction); ok && i.Instruction == instruction.RefFunc {
+				if idx, ok := m.FunctionIndex(i.Function); ok {
+					refs[idx] = true
+				}
+			}
+		}
+	}
+
+	for _, g := range m.Globals {
+		addRefs(g.Init)
+	}
+	for _, e := range m.Elements {
+		for _, init := range e.Init {
+			addRefs(init)
+		}
+	}
+	for _, e := range m.Exports {
+		if e.Target != mod.ExportFunction {
+			continue
+		}
+		if idx, ok := m.FunctionIndex(e.Index); ok {
+			refs[idx] = true
+		}
+	}
+
+	return refs
+}
+
+func (v *validator) validateTables() error {
+	ids := make(map[types.ID]bool)
+	for i, t := range v.m.Tables {
+		field := fmt.Sprintf("table %d", i)
+		if !t.ID.IsEmpty() {
+			field += " " + string(t.ID)
+
+			if ids[t.ID] {
+				return fieldError(field, ErrDuplicateID)
+			}
+			ids[t.ID] = true
+		}
+
+		if !t.Type.IsReference() {
+			return fieldError(field, fmt.Errorf("%w: %s is not a reference type", ErrTypeMismatch, t.Type))
+		}
+		if err := validateLimits(t.Limits, mod.MaxTableSize); err != nil {
+			return fieldError(field, err)
+		}
+	}
+
+	return nil
+}
+
+func (v *validator) validateMemories() error {
+	ids := make(map[types.ID]bool)
+	for i, mem := range v.m.Memories {
+		field := fmt.Sprintf("memory %d", i)
+		if !mem.ID.IsEmpty() {
+			field += " " + string(mem.ID)
+
+			if ids[mem.ID] {
+				return fieldError(field, ErrDuplicateID)
+			}
+			ids[mem.ID] = true
+		}
+
+		if i > 0 {
+			return fieldError(field, ErrMultipleMemories)
+		}
+		if err := validateLimits(mem.Limits, mod.MaxPages); err != nil {
+			return fieldError(field, err)
+		}
+	}
+
+	return nil
+}
+
+func (v *validator) validateGlobals() error {
+	ids := make(map[types.ID]bool)
+	for i, g := range v.m.Globals {
+		field := fmt.Sprintf("global %d", i)
+		if !g.ID.IsEmpty() {
+			field += " " + string(g.ID)
+
+			if ids[g.ID] {
+				return fieldError(field, ErrDuplicateID)
+			}
+			ids[g.ID] = true
+		}
+
+		// an imported global is initialized by the host, and the initial
+		// value of the others can refer only to the globals before it
+		if g.Import != nil {
+			continue
+		}
+		if err := v.validateConstExpr(field, g.Init, g.Type, i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *validator) validateElements() error {
+	ids := make(map[types.ID]bool)
+	for i, e := range v.m.Elements {
+		field := fmt.Sprintf("elem %d", i)
+		if !e.ID.IsEmpty() {
+			field += " " + string(e.ID)
+
+			if ids[e.ID] {
+				return fieldError(field, ErrDuplicateID)
+			}
+			ids[e.ID] = true
+		}
+
+		if !e.Type.IsReference() {
+			return fieldError(field, fmt.Errorf("%w: %s is not a reference type", ErrTypeMismatch, e.Type))
+		}
+		for _, init := range e.Init {
+			if err := v.validateConstExpr(field, init, e.Type, len(v.m.Globals)); err != nil {
+				return err
+			}
+		}
+
+		if e.Mode != mod.ElementActive {
+			continue
+		}
+		idx, ok := v.m.TableIndex(e.Table)
+		if !ok {
+			return fieldError(field, fmt.Errorf("%w %s", ErrUnknownTable, formatIndex(e.Table)))
+		}
+		if typ := v.m.Tables[idx].Type; typ != e.Type {
+			return fieldError(field, fmt.Errorf("%w: expected %s but got %s", ErrTypeMismatch, typ, e.Type))
+		}
+		if err := v.validateConstExpr(field, e.Offset, types.I32, len(v.m.Globals)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *validator) validateData() error {
+	ids := make(map[types.ID]bool)
+	for i, d := range v.m.Data {
+		field := fmt.Sprintf("data %d", i)
+		if !d.ID.IsEmpty() {
+			field += " " + string(d.ID)
+
+			if ids[d.ID] {
+				return fieldError(field, ErrDuplicateID)
+			}
+			ids[d.ID] = true
+		}
+
+		if d.Mode != mod.DataActive {
+			continue
+		}
+		if _, ok := v.m.MemoryIndex(d.Memory); !ok {
+			return fieldError(field, fmt.Errorf("%w %s", ErrUnknownMemory, formatIndex(d.Memory)))
+		}
+		if err := v.validateConstExpr(field, d.Offset, types.I32, len(v.m.Globals)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateConstExpr validates a constant expression, which is evaluated to
+// a value of typ at instantiation. It can get the values of the first
+// globals immutable globals.
+func (v *validator) validateConstExpr(field string, expr []instruction.Instruction, typ types.Type, globals int) error {
+	for n, i := range expr {
+		var err error
+		switch i.Name() {
+		case instruction.I32Const, instruction.I64Const, instruction.F32Const, instruction.F64Const,
+			instruction.RefNull, instruction.RefFunc:
+		case instruction.GlobalGet:
+			index := i.(*instruction.VariableInstruction).Index
+			if idx, ok := v.m.GlobalIndex(index); !ok || idx >= globals {
+				err = fmt.Errorf("%w %s", ErrUnknownGlobal, formatIndex(index))
+			} else if v.m.Globals[idx].Mutable {
+				err = ErrConstantRequired
+			}
+		default:
+			err = ErrConstantRequired
+		}
+		if err != nil {
+			return &Error{
+				Field:       field,
+				Instruction: n,
+				Name:        i.Name(),
+				Err:         err,
+			}
+		}
+	}
+
+	fv := &functionValidator{
+		m: v.m,
+		f: &mod.Function{
+			Results:      []*mod.Result{{Type: typ}},
+			Instructions: expr,
+		},
+		field:     field,
+		refs:      v.refs,
+		constExpr: true,
+	}
+
+	return fv.Validate()
+}
+
+// validateLimits checks the limits are within the range of k.
+func validateLimits(limits mod.Limits, k uint32) error {
+	if limits.Min > k {
+		return fmt.Errorf("%w: minimum %d must be at most %d", ErrInvalidLimits, limits.Min, k)
+	}
+	if limits.HasMax {
+		if limits.Max > k {
+			return fmt.Errorf("%w: maximum %d must be at most %d", ErrInvalidLimits, limits.Max, k)
+		}
+		if limits.Min > limits.Max {
+			return fmt.Errorf("%w: minimum %d must not be greater than maximum %d", ErrInvalidLimits, limits.Min, limits.Max)
+		}
+	}
+
+	return nil
+}
+
+func fieldError(field string, err error) error {
+	return &Error{
+		Field:       field,
+		Instruction: -1,
+		Err:         err,
+	}
+}
+
+func formatIndex(idx types.Index) string {
+	if idx.IsID() {
+		return string(idx.ID)
+	}
+
+	return fmt.Sprint(idx.Index)
+}
+
+func formatTypes(list []types.Type) string {
+	s := make([]string, len(list))
+	for i, t := range list {
+		s[i] = string(t)
+	}
+
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+// formatBlock returns the label of the n-th block of f, or the number if the
+// block is anonymous.
+func formatBlock(f *mod.Function, n int) string {
+	if block, ok := f.Block(n); ok && !block.Label.IsEmpty() {
+		return "block " + string(block.Label)
+	}
+
+	return fmt.Sprint("block ", n)
+}
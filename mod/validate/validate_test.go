@@ -0,0 +1,605 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kechako/wasmexec/mod/text"
+)
+
+var tests = map[string]struct {
+	input string
+	err   error
+	// position of the instruction reported by Error
+	instruction int
+	// message of the error, which is checked unless empty
+	msg string
+}{
+	"valid": {
+		input: `(module
+  (func $main (result i32) (local $l i32)
+    i32.const 30
+    (block $b (param i32) (result i32)
+      i32.const 40
+      local.set $l
+      i32.const 60
+      i32.add)
+    local.get $l
+    call $sub)
+  (func $sub (param $p1 i32) (param $p2 i32) (result i32)
+    local.get $p1
+    local.get $p2
+    i32.sub
+    return
+    drop
+    i32.add)
+  (export "main" (func $main)))`,
+	},
+	"stack underflow": {
+		input: `(module
+  (func (result i32)
+    i32.const 1
+    i32.add))`,
+		err:         ErrTypeMismatch,
+		instruction: 1,
+	},
+	"operand type mismatch": {
+		input: `(module
+  (func (param $a i64) (result i32)
+    local.get $a
+    i32.eqz))`,
+		err:         ErrTypeMismatch,
+		instruction: 1,
+	},
+	"float comparison": {
+		input: `(module
+  (func (param f32) (result f64)
+    local.get 0
+    f32.const 1.5
+    f32.lt
+    f64.const 2
+    f64.add))`,
+		err:         ErrTypeMismatch,
+		instruction: 4,
+	},
+	"conversion type mismatch": {
+		input: `(module
+  (func (param i64) (result f64)
+    local.get 0
+    f64.convert_i64_u
+    f32.demote_f64
+    f64.reinterpret_i64))`,
+		err:         ErrTypeMismatch,
+		instruction: 3,
+	},
+	"branch": {
+		input: `(module
+  (func (param i32) (result i32)
+    (block $a (result i32)
+      (block $b
+        i32.const 1
+        local.get 0
+        br_if $a
+        drop
+        local.get 0
+        br_table 0 $b)
+      i32.const 2
+      br 1)))`,
+	},
+	"unknown label": {
+		input: `(module
+  (func
+    (block
+      br 2)))`,
+		err:         ErrUnknownLabel,
+		instruction: 1,
+	},
+	"branch type mismatch": {
+		input: `(module
+  (func (result i32)
+    (block $a (result i32)
+      i64.const 1
+      br $a)))`,
+		err:         ErrTypeMismatch,
+		instruction: 2,
+	},
+	"br_table arity mismatch": {
+		input: `(module
+  (func (result i32)
+    (block $a (result i32)
+      (block $b
+        i32.const 0
+        i32.const 0
+        br_table $a $b))
+    i32.const 1))`,
+		err:         ErrTypeMismatch,
+		instruction: 4,
+	},
+	"loop and if": {
+		input: `(module
+  (func (param i32) (result i32)
+    local.get 0
+    (loop $l (param i32) (result i32)
+      i32.const 1
+      i32.sub
+      local.tee 0
+      local.get 0
+      br_if $l)
+    (if (param i32) (result i32) (local.get 0)
+      (then
+        i32.const 1
+        i32.add)
+      (else
+        i32.const 2
+        i32.add))))`,
+	},
+	"loop branch type mismatch": {
+		input: `(module
+  (func
+    i32.const 0
+    (loop $l (param i32)
+      i64.const 1
+      br $l)))`,
+		err:         ErrTypeMismatch,
+		instruction: 3,
+	},
+	"if without condition": {
+		input: `(module
+  (func
+    (if
+      (then))))`,
+		err:         ErrTypeMismatch,
+		instruction: 0,
+	},
+	"if without else": {
+		input: `(module
+  (func (result i32)
+    (if (result i32) (i32.const 1)
+      (then
+        i32.const 1))))`,
+		err:         ErrTypeMismatch,
+		instruction: 1,
+	},
+	"local type mismatch": {
+		input: `(module
+  (func (local $a i64)
+    i32.const 1
+    local.set $a))`,
+		err:         ErrTypeMismatch,
+		instruction: 1,
+	},
+	"extra values at end of function": {
+		input: `(module
+  (func
+    i32.const 1))`,
+		err:         ErrTypeMismatch,
+		instruction: -1,
+		msg:         "func 0: type mismatch: expected [] but 1 extra values remain at end of function",
+	},
+	"missing result of function": {
+		input: `(module
+  (func (result i32)))`,
+		err:         ErrTypeMismatch,
+		instruction: -1,
+	},
+	"missing result of block": {
+		input: `(module
+  (func (result i32)
+    i32.const 1
+    (block (result i32)
+      i32.const 2
+      drop)
+    i32.add))`,
+		err:         ErrTypeMismatch,
+		instruction: 1,
+		msg:         "func 0: instruction 1 (block): type mismatch: expected i32 but stack is empty at end of block 0",
+	},
+	"missing result of labeled block": {
+		input: `(module
+  (func (result i32)
+    (block $b (result i32))))`,
+		err:         ErrTypeMismatch,
+		instruction: 0,
+		msg:         "func 0: instruction 0 (block): type mismatch: expected i32 but stack is empty at end of block $b",
+	},
+	"block cannot access outer operands": {
+		input: `(module
+  (func
+    i32.const 1
+    (block
+      drop)
+    drop))`,
+		err:         ErrTypeMismatch,
+		instruction: 2,
+	},
+	"call arguments": {
+		input: `(module
+  (func (result i32)
+    i32.const 1
+    call $f)
+  (func $f (param i32) (param i32) (result i32)
+    local.get 0))`,
+		err:         ErrTypeMismatch,
+		instruction: 1,
+	},
+	"unknown local": {
+		input: `(module
+  (func (param $a i32)
+    local.get 1
+    drop))`,
+		err:         ErrUnknownLocal,
+		instruction: 0,
+	},
+	"unknown function": {
+		input: `(module
+  (func
+    call $f))`,
+		err:         ErrUnknownFunction,
+		instruction: 0,
+	},
+	"duplicate local": {
+		input: `(module
+  (func (param $a i32) (local $a i32)))`,
+		err:         ErrDuplicateID,
+		instruction: -1,
+	},
+	"duplicate export": {
+		input: `(module
+  (func $f)
+  (export "f" (func $f))
+  (export "f" (func 0)))`,
+		err:         ErrDuplicateExport,
+		instruction: -1,
+	},
+	"unknown export": {
+		input: `(module
+  (func $f)
+  (export "f" (func 1)))`,
+		err:         ErrUnknownFunction,
+		instruction: -1,
+	},
+	"memory": {
+		input: `(module
+  (memory $m 1 2)
+  (func (param i32) (result i64)
+    (i64.store8 offset=4 (local.get 0) (i64.const 1))
+    (drop (memory.grow (memory.size)))
+    (i64.load16_u align=2 (local.get 0)))
+  (export "m" (memory $m)))`,
+	},
+	"load without memory": {
+		input: `(module
+  (func
+    i32.const 0
+    i32.load
+    drop))`,
+		err:         ErrUnknownMemory,
+		instruction: 1,
+	},
+	"alignment larger than natural": {
+		input: `(module
+  (memory 1)
+  (func
+    i32.const 0
+    i32.load16_s align=4
+    drop))`,
+		err:         ErrInvalidAlignment,
+		instruction: 1,
+	},
+	"store type mismatch": {
+		input: `(module
+  (memory 1)
+  (func
+    i32.const 0
+    f32.const 1
+    i32.store))`,
+		err:         ErrTypeMismatch,
+		instruction: 2,
+	},
+	"memory limits": {
+		input: `(module
+  (memory 2 1))`,
+		err:         ErrInvalidLimits,
+		instruction: -1,
+	},
+	"memory too large": {
+		input: `(module
+  (memory 65537))`,
+		err:         ErrInvalidLimits,
+		instruction: -1,
+	},
+	"multiple memories": {
+		input: `(module
+  (memory 1)
+  (memory 1))`,
+		err:         ErrMultipleMemories,
+		instruction: -1,
+	},
+	"data": {
+		input: `(module
+  (func
+    i32.const 0
+    i32.const 0
+    i32.const 4
+    memory.init $passive
+    data.drop $passive)
+  (memory 1)
+  (data (i32.const 8) "abcd")
+  (data $passive "efgh"))`,
+	},
+	"data without memory": {
+		input: `(module
+  (data (i32.const 0) "abcd"))`,
+		err:         ErrUnknownMemory,
+		instruction: -1,
+	},
+	"data offset type mismatch": {
+		input: `(module
+  (memory 1)
+  (data (i64.const 0) "abcd"))`,
+		err:         ErrTypeMismatch,
+		instruction: -1,
+		msg:         "data 0: type mismatch: expected i32 but got i64 at end of constant expression",
+	},
+	"data offset not constant": {
+		input: `(module
+  (memory 1)
+  (data (offset i32.const 1 i32.const 2 i32.add) "abcd"))`,
+		err:         ErrConstantRequired,
+		instruction: 2,
+	},
+	"globals": {
+		input: `(module
+  (global $c i64 (i64.const 1))
+  (global $v (mut i64) (global.get $c))
+  (func (result i64)
+    global.get $v
+    global.get $c
+    i64.add
+    global.set $v
+    global.get $v)
+  (export "v" (global $v)))`,
+	},
+	"set immutable global": {
+		input: `(module
+  (global $c i32 (i32.const 1))
+  (func
+    i32.const 2
+    global.set $c))`,
+		err:         ErrImmutableGlobal,
+		instruction: 1,
+	},
+	"global type mismatch": {
+		input: `(module
+  (global (mut f32) (f32.const 0))
+  (func
+    i32.const 2
+    global.set 0))`,
+		err:         ErrTypeMismatch,
+		instruction: 1,
+	},
+	"global init refers to later global": {
+		input: `(module
+  (global i32 (global.get 1))
+  (global i32 (i32.const 0)))`,
+		err:         ErrUnknownGlobal,
+		instruction: 0,
+	},
+	"global init refers to mutable global": {
+		input: `(module
+  (global (mut i32) (i32.const 0))
+  (global i32 (global.get 0)))`,
+		err:         ErrConstantRequired,
+		instruction: 0,
+	},
+	"unknown exported global": {
+		input: `(module
+  (export "g" (global 0)))`,
+		err:         ErrUnknownGlobal,
+		instruction: -1,
+	},
+	"memory.init unknown data": {
+		input: `(module
+  (func
+    i32.const 0
+    i32.const 0
+    i32.const 4
+    memory.init 1)
+  (memory 1)
+  (data "abcd"))`,
+		err:         ErrUnknownData,
+		instruction: 3,
+	},
+	"tables": {
+		input: `(module
+  (table $t 2 funcref)
+  (func $f (param i32) (result i32)
+    local.get 0)
+  (func (result i32)
+    i32.const 7
+    i32.const 0
+    call_indirect $t (param i32) (result i32)
+    i32.const 1
+    ref.func $f
+    table.set $t
+    table.size $t
+    i32.add
+    ref.null func
+    i32.const 1
+    table.grow $t
+    i32.add)
+  (elem (table $t) (i32.const 0) func $f))`,
+	},
+	"ref.is_null": {
+		input: `(module
+  (func (result i32)
+    ref.null func
+    ref.is_null))`,
+	},
+	"ref.is_null type mismatch": {
+		input: `(module
+  (func (result i32)
+    i32.const 0
+    ref.is_null))`,
+		err:         ErrTypeMismatch,
+		instruction: 1,
+	},
+	"undeclared function reference": {
+		input: `(module
+  (func $f)
+  (func (result funcref)
+    ref.func $f))`,
+		err:         ErrUndeclaredReference,
+		instruction: 0,
+	},
+	"call_indirect without table": {
+		input: `(module
+  (func
+    i32.const 0
+    call_indirect))`,
+		err:         ErrUnknownTable,
+		instruction: 1,
+	},
+	"call_indirect argument mismatch": {
+		input: `(module
+  (table 1 funcref)
+  (func
+    i64.const 0
+    i32.const 0
+    call_indirect (param i32)))`,
+		err:         ErrTypeMismatch,
+		instruction: 2,
+	},
+	"table.init unknown elem": {
+		input: `(module
+  (table 1 funcref)
+  (func
+    i32.const 0
+    i32.const 0
+    i32.const 1
+    table.init 1))`,
+		err:         ErrUnknownElement,
+		instruction: 3,
+	},
+	"elem without table": {
+		input: `(module
+  (func $f)
+  (elem (i32.const 0) func $f))`,
+		err:         ErrUnknownTable,
+		instruction: -1,
+	},
+	"elem offset not constant": {
+		input: `(module
+  (table 1 funcref)
+  (func $f)
+  (elem (offset i32.const 0 i32.const 0 i32.add) func $f))`,
+		err:         ErrConstantRequired,
+		instruction: 2,
+	},
+	"typeuse": {
+		input: `(module
+  (type $binop (func (param i32 i32) (result i32)))
+  (table 1 funcref)
+  (func $add (type $binop)
+    local.get 0
+    local.get 1
+    i32.add)
+  (func (result i32)
+    i32.const 1
+    i32.const 2
+    (block (type $binop)
+      i32.const 0
+      call_indirect (type $binop)))
+  (elem (i32.const 0) func $add))`,
+	},
+	"duplicate type": {
+		input: `(module
+  (type $t (func))
+  (type $t (func (param i32))))`,
+		err:         ErrDuplicateID,
+		instruction: -1,
+	},
+	"imports": {
+		input: `(module
+  (import "env" "add" (func $add (param i32) (param i32) (result i32)))
+  (import "env" "mem" (memory 1))
+  (import "env" "base" (global $base i32))
+  (global $g i32 (global.get $base))
+  (func (result i32)
+    (call $add (global.get $g) (i32.load (i32.const 0)))))`,
+	},
+	"imported memory and memory": {
+		input: `(module
+  (import "env" "mem" (memory 1))
+  (memory 1))`,
+		err:         ErrMultipleMemories,
+		instruction: -1,
+	},
+	"unreachable": {
+		input: `(module
+  (func (result i32)
+    nop
+    unreachable
+    i32.add))`,
+	},
+	"start": {
+		input: `(module
+  (func $init)
+  (start $init))`,
+	},
+	"start with results": {
+		input: `(module
+  (func $init (result i32)
+    i32.const 0)
+  (start $init))`,
+		err:         ErrTypeMismatch,
+		instruction: -1,
+	},
+	"unknown start": {
+		input: `(module
+  (start 0))`,
+		err:         ErrUnknownFunction,
+		instruction: -1,
+	},
+	"unknown exported table": {
+		input: `(module
+  (export "t" (table 0)))`,
+		err:         ErrUnknownTable,
+		instruction: -1,
+	},
+}
+
+func Test_Validate(t *testing.T) {
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			m, err := text.NewDecoder(strings.NewReader(tt.input)).Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = Validate(m)
+			if tt.err == nil {
+				if err != nil {
+					t.Errorf("Validate(): err: want: nil, got: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Validate(): err: want: %v, got: %v", tt.err, err)
+			}
+			var verr *Error
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate(): err: want: *Error, got: %T", err)
+			}
+			if verr.Instruction != tt.instruction {
+				t.Errorf("Validate(): instruction: want: %d, got: %d (%v)", tt.instruction, verr.Instruction, err)
+			}
+			if tt.msg != "" && err.Error() != tt.msg {
+				t.Errorf("Validate(): err: want: %q, got: %q", tt.msg, err.Error())
+			}
+		})
+	}
+}
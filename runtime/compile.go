@@ -0,0 +1,484 @@
+package runtime
+
+import (
+	"errors"
+
+	"github.com/kechako/wasmexec/mod"
+	"github.com/kechako/wasmexec/mod/instruction"
+	"github.com/kechako/wasmexec/mod/types"
+)
+
+var errConstInconsistent = errors.New("constant is inconsistent")
+
+// operation is an instruction of the compiled code, whose operands are
+// resolved at the compilation.
+type operation struct {
+	code opcode
+	// index of the local variable, the global, the table, the elem segment
+	// or the data segment, which is the destination table of table.copy
+	index int
+	// index of the source table of table.copy, or the elem segment of
+	// table.init
+	source int
+	// offset and the number of bytes of a load or a store
+	offset uint32
+	size   uint32
+	// bit pattern of a constant
+	bits uint64
+	// function of call and ref.func
+	fn *function
+	// type of the function of call_indirect
+	typ *mod.FuncType
+	// branch of the control operations except br_table
+	br branch
+	// branches of br_table for each operand followed by the default one
+	table []branch
+	// instruction compiled to the operation, or nil for the operations the
+	// compiler adds, which consume no fuel
+	inst instruction.Instruction
+}
+
+// branch is the destination of a branch.
+type branch struct {
+	// position of the operation to jump to
+	target int
+	// height of the operand stack at the destination, including the local
+	// variables, and the number of values to keep on the top of it
+	height int
+	arity  int
+}
+
+// position is the position of the instruction of an operation in the
+// source function, which is reported in the backtraces.
+type position struct {
+	// number of the innermost block, or -1 for the body of the function
+	block int
+	// position of the instruction in the block
+	instruction int
+}
+
+// control is a block being compiled.
+type control struct {
+	block *mod.Block
+	loop  bool
+	// height of the operand stack at the start of the block without the
+	// parameters
+	height  int
+	params  int
+	results int
+	// position of the first operation of a loop
+	start int
+	// branches to the end of the block, which are patched when the end is
+	// compiled
+	fixups []fixup
+}
+
+// fixup is a branch whose target is not yet compiled.
+type fixup struct {
+	// position of the operation
+	op int
+	// position of the branch in the table of br_table, or -1 for the
+	// branch of the other operations
+	entry int
+}
+
+// arity returns the number of values a branch to the block keeps.
+func (c *control) arity() int {
+	if c.loop {
+		return c.params
+	}
+
+	return c.results
+}
+
+// compiler compiles a function into the flat code, in which the operations
+// hold the resolved indices and the branches hold the precomputed targets
+// and stack heights.
+type compiler struct {
+	m  *Module
+	fn *function
+
+	ctrls []*control
+	// height of the operand stack, including the local variables
+	height int
+}
+
+// compile compiles the instructions of fn, and ends the code with an
+// implicit return.
+func (m *Module) compile(fn *function) error {
+	c := &compiler{
+		m:      m,
+		fn:     fn,
+		height: fn.locals,
+	}
+
+	body := &control{
+		height:  fn.locals,
+		results: len(fn.f.Results),
+	}
+	if err := c.compileBlock(body, -1, fn.f.Instructions); err != nil {
+		return err
+	}
+	c.patch(body, len(fn.code))
+	c.emit(operation{
+		code: opReturn,
+		br:   branch{arity: body.results},
+	}, position{block: -1, instruction: len(fn.f.Instructions) - 1})
+
+	return nil
+}
+
+// compileBlock compiles the instructions in the block of ctrl, which is the
+// n-th block or -1 for the body of the function.
+func (c *compiler) compileBlock(ctrl *control, n int, instructions []instruction.Instruction) error {
+	c.ctrls = append(c.ctrls, ctrl)
+	defer func() {
+		c.ctrls = c.ctrls[:len(c.ctrls)-1]
+	}()
+
+	for pos, i := range instructions {
+		if err := c.compileInstruction(i, position{block: n, instruction: pos}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// emit appends the operation at pos to the code, and returns its position in
+// the code.
+func (c *compiler) emit(op operation, pos position) int {
+	c.fn.code = append(c.fn.code, op)
+	c.fn.pos = append(c.fn.pos, pos)
+
+	return len(c.fn.code) - 1
+}
+
+// patch sets the target of the branches to the end of ctrl.
+func (c *compiler) patch(ctrl *control, target int) {
+	for _, f := range ctrl.fixups {
+		op := &c.fn.code[f.op]
+		if f.entry < 0 {
+			op.br.target = target
+		} else {
+			op.table[f.entry].target = target
+		}
+	}
+	ctrl.fixups = nil
+}
+
+// unreachable marks the rest of the block as unreachable, where the height of
+// the stack no longer matters.
+func (c *compiler) unreachable() {
+	c.height = c.ctrls[len(c.ctrls)-1].height
+}
+
+// label returns the block of the label, which is a depth counted from the
+// innermost block or the ID of a block.
+func (c *compiler) label(label types.Index) (*control, error) {
+	if label.IsIndex() {
+		n := len(c.ctrls) - 1 - label.Index
+		if label.Index < 0 || n < 0 {
+			return nil, errLabelNotFound
+		}
+		return c.ctrls[n], nil
+	}
+
+	// the body of the function has no label
+	for n := len(c.ctrls) - 1; n > 0; n-- {
+		if c.ctrls[n].block.Label == label.ID {
+			return c.ctrls[n], nil
+		}
+	}
+
+	return nil, errLabelNotFound
+}
+
+// branch returns the branch to the label, whose target is patched later as f
+// unless the label is a loop.
+func (c *compiler) branch(label types.Index, f fixup) (branch, error) {
+	ctrl, err := c.label(label)
+	if err != nil {
+		return branch{}, err
+	}
+
+	br := branch{
+		height: ctrl.height,
+		arity:  ctrl.arity(),
+	}
+	if ctrl.loop {
+		br.target = ctrl.start
+	} else {
+		ctrl.fixups = append(ctrl.fixups, f)
+	}
+
+	return br, nil
+}
+
+func (c *compiler) compileInstruction(i instruction.Instruction, pos position) error {
+	name := i.Name()
+	code, ok := opcodes[name]
+	if !ok {
+		return errUnsupportedInstruction
+	}
+	op := operation{
+		code: code,
+		inst: i,
+	}
+
+	switch i := i.(type) {
+	case *instruction.BlockInstruction:
+		return c.compileStructured(op, i, pos)
+	case *instruction.BranchInstruction:
+		return c.compileBranch(op, i, pos)
+	case *instruction.I32Instruction:
+		if code == opI32Const {
+			if len(i.Values) != 1 {
+				return errConstInconsistent
+			}
+			op.bits = toBits(i.Values[0])
+		}
+	case *instruction.I64Instruction:
+		if code == opI64Const {
+			if len(i.Values) != 1 {
+				return errConstInconsistent
+			}
+			op.bits = toBits(i.Values[0])
+		}
+	case *instruction.F32Instruction:
+		if code == opF32Const {
+			if len(i.Values) != 1 {
+				return errConstInconsistent
+			}
+			op.bits = toBits(i.Values[0])
+		}
+	case *instruction.F64Instruction:
+		if code == opF64Const {
+			if len(i.Values) != 1 {
+				return errConstInconsistent
+			}
+			op.bits = toBits(i.Values[0])
+		}
+	case *instruction.VariableInstruction:
+		var ok bool
+		if code == opGlobalGet || code == opGlobalSet {
+			op.index, ok = c.m.mod.GlobalIndex(i.Index)
+			if !ok {
+				return errGlobalNotFound
+			}
+		} else {
+			op.index, ok = c.fn.f.LocalIndex(i.Index)
+			if !ok {
+				return errLocalVariableInconsistent
+			}
+		}
+	case *instruction.ReferenceInstruction:
+		if code == opRefFunc {
+			n, ok := c.m.mod.FunctionIndex(i.Function)
+			if !ok {
+				return errFunctionNotFound
+			}
+			op.fn = c.m.funcs[n]
+		}
+	case *instruction.TableInstruction:
+		if err := c.resolveTable(&op, i); err != nil {
+			return err
+		}
+	case *instruction.MemoryInstruction:
+		op.offset = i.Offset
+		op.size = name.AccessSize()
+		if code == opMemoryInit || code == opDataDrop {
+			var ok bool
+			op.index, ok = c.m.mod.DataIndex(i.Data)
+			if !ok {
+				return errDataNotFound
+			}
+		}
+	case *instruction.CallInstruction:
+		n, ok := c.m.mod.FunctionIndex(i.Index)
+		if !ok {
+			return errFunctionNotFound
+		}
+		op.fn = c.m.funcs[n]
+		c.height += len(op.fn.f.Results) - len(op.fn.f.Parameters)
+	case *instruction.CallIndirectInstruction:
+		var ok bool
+		op.index, ok = c.m.mod.TableIndex(i.Table)
+		if !ok {
+			return errTableNotFound
+		}
+		n, ok := c.m.mod.TypeIndex(i.Type)
+		if !ok {
+			return errTypeNotFound
+		}
+		op.typ = c.m.mod.Types[n]
+		c.height += len(op.typ.Results) - len(op.typ.Parameters) - 1
+	}
+
+	switch code {
+	case opReturn:
+		op.br.arity = len(c.fn.f.Results)
+		c.emit(op, pos)
+		c.unreachable()
+	case opUnreachable:
+		c.emit(op, pos)
+		c.unreachable()
+	default:
+		c.height += stackEffect(code)
+		c.emit(op, pos)
+	}
+
+	return nil
+}
+
+// resolveTable resolves the table and the elem segment of a table
+// instruction.
+func (c *compiler) resolveTable(op *operation, i *instruction.TableInstruction) error {
+	if op.code == opElemDrop || op.code == opTableInit {
+		n, ok := c.m.mod.ElementIndex(i.Elem)
+		if !ok {
+			return errElementNotFound
+		}
+		if op.code == opElemDrop {
+			op.index = n
+			return nil
+		}
+		op.source = n
+	}
+
+	var ok bool
+	op.index, ok = c.m.mod.TableIndex(i.Table)
+	if !ok {
+		return errTableNotFound
+	}
+	if op.code == opTableCopy {
+		op.source, ok = c.m.mod.TableIndex(i.Source)
+		if !ok {
+			return errTableNotFound
+		}
+	}
+
+	return nil
+}
+
+// compileStructured compiles a block, a loop or an if. The operations of
+// them do nothing but consume the fuel except for if, which jumps to the
+// else branch or the end if the condition is zero. The then branch of an if
+// with the else branch ends with a jump to the end.
+func (c *compiler) compileStructured(op operation, i *instruction.BlockInstruction, pos position) error {
+	block, ok := c.fn.f.Block(i.Block)
+	if !ok {
+		return errBlockNotFound
+	}
+
+	if op.code == opIf {
+		c.height--
+	}
+	ctrl := &control{
+		block:   block,
+		loop:    op.code == opLoop,
+		height:  c.height - len(block.Parameters),
+		params:  len(block.Parameters),
+		results: len(block.Results),
+	}
+	n := c.emit(op, pos)
+	ctrl.start = n + 1
+
+	if err := c.compileBlock(ctrl, i.Block, block.Instructions); err != nil {
+		return err
+	}
+	if op.code == opIf {
+		if len(block.Else) > 0 {
+			jump := c.emit(operation{code: opJump}, position{
+				block:       i.Block,
+				instruction: len(block.Instructions) - 1,
+			})
+			ctrl.fixups = append(ctrl.fixups, fixup{op: jump, entry: -1})
+			c.fn.code[n].br.target = len(c.fn.code)
+			c.height = ctrl.height + ctrl.params
+			if err := c.compileBlock(ctrl, i.Block, block.Else); err != nil {
+				return err
+			}
+		} else {
+			ctrl.fixups = append(ctrl.fixups, fixup{op: n, entry: -1})
+		}
+	}
+
+	c.patch(ctrl, len(c.fn.code))
+	c.height = ctrl.height + ctrl.results
+
+	return nil
+}
+
+// compileBranch compiles br, br_if and br_table, which pop the operand before
+// the branch.
+func (c *compiler) compileBranch(op operation, i *instruction.BranchInstruction, pos position) error {
+	if len(i.Labels) == 0 {
+		return errLabelNotFound
+	}
+	if op.code != opBr {
+		c.height--
+	}
+
+	n := len(c.fn.code)
+	if op.code == opBrTable {
+		op.table = make([]branch, len(i.Labels))
+		for entry, label := range i.Labels {
+			br, err := c.branch(label, fixup{op: n, entry: entry})
+			if err != nil {
+				return err
+			}
+			op.table[entry] = br
+		}
+	} else {
+		br, err := c.branch(i.Labels[0], fixup{op: n, entry: -1})
+		if err != nil {
+			return err
+		}
+		op.br = br
+	}
+
+	c.emit(op, pos)
+	if op.code != opBrIf {
+		c.unreachable()
+	}
+
+	return nil
+}
+
+// stackEffect returns the change of the height of the operand stack by the
+// operation, except for the calls and the control operations whose effects
+// depend on their operands.
+func stackEffect(code opcode) int {
+	switch code {
+	case opI32Const, opI64Const, opF32Const, opF64Const,
+		opLocalGet, opGlobalGet, opRefNull, opRefFunc, opTableSize, opMemorySize:
+		return 1
+	case opI32Clz, opI32Ctz, opI32Popcnt, opI32Eqz,
+		opI64Clz, opI64Ctz, opI64Popcnt, opI64Eqz,
+		opF32Abs, opF32Neg, opF32Ceil, opF32Floor, opF32Trunc, opF32Nearest, opF32Sqrt,
+		opF64Abs, opF64Neg, opF64Ceil, opF64Floor, opF64Trunc, opF64Nearest, opF64Sqrt,
+		opLocalTee, opRefIsNull, opTableGet, opMemoryGrow:
+		return 0
+	case opTableSet:
+		return -2
+	case opTableFill, opTableCopy, opTableInit, opMemoryInit:
+		return -3
+	}
+
+	switch {
+	case code <= opF64Ge:
+		// the other numeric operations are binary
+		return -1
+	case code <= opI64TruncSatF64U:
+		return 0
+	case code >= opI32Load && code <= opI64Load32U:
+		return 0
+	case code >= opI32Store && code <= opI64Store32:
+		return -2
+	case code == opDrop, code == opLocalSet, code == opGlobalSet, code == opTableGrow:
+		return -1
+	}
+
+	return 0
+}
@@ -0,0 +1,208 @@
+package runtime
+
+import "math"
+
+type integer interface {
+	int32 | int64 | uint32 | uint64
+}
+
+// truncFloat truncates f to an integer, which must be in the range [lo, hi).
+func truncFloat[T integer](f, lo, hi float64) (T, error) {
+	if math.IsNaN(f) {
+		return 0, errInvalidConversion
+	}
+
+	t := math.Trunc(f)
+	if t < lo || t >= hi {
+		return 0, errIntegerOverflow
+	}
+
+	return T(t), nil
+}
+
+// truncFloatSat truncates f to an integer, saturating it to [minValue, maxValue]
+// instead of trapping. NaN is converted to 0.
+func truncFloatSat[T integer](f, lo, hi float64, minValue, maxValue T) T {
+	if math.IsNaN(f) {
+		return 0
+	}
+
+	t := math.Trunc(f)
+	if t < lo {
+		return minValue
+	}
+	if t >= hi {
+		return maxValue
+	}
+
+	return T(t)
+}
+
+func truncS32(f float64) (int32, error) {
+	return truncFloat[int32](f, math.MinInt32, 1<<31)
+}
+
+func truncU32(f float64) (int32, error) {
+	n, err := truncFloat[uint32](f, 0, 1<<32)
+	return int32(n), err
+}
+
+func truncS64(f float64) (int64, error) {
+	return truncFloat[int64](f, math.MinInt64, 1<<63)
+}
+
+func truncU64(f float64) (int64, error) {
+	n, err := truncFloat[uint64](f, 0, 1<<64)
+	return int64(n), err
+}
+
+func truncSatS32(f float64) (int32, error) {
+	return truncFloatSat[int32](f, math.MinInt32, 1<<31, math.MinInt32, math.MaxInt32), nil
+}
+
+func truncSatU32(f float64) (int32, error) {
+	return int32(truncFloatSat[uint32](f, 0, 1<<32, 0, math.MaxUint32)), nil
+}
+
+func truncSatS64(f float64) (int64, error) {
+	return truncFloatSat[int64](f, math.MinInt64, 1<<63, math.MinInt64, math.MaxInt64), nil
+}
+
+func truncSatU64(f float64) (int64, error) {
+	return int64(truncFloatSat[uint64](f, 0, 1<<64, 0, math.MaxUint64)), nil
+}
+
+// fromF32 lifts a conversion from float64 to float32 operands. Every float32
+// is exactly representable as a float64.
+func fromF32[T number](f func(float64) (T, error)) func(c float32) (T, error) {
+	return func(c float32) (T, error) {
+		return f(float64(c))
+	}
+}
+
+func (inst *Instance) execConversion(op *operation) error {
+	switch op.code {
+	case opI32WrapI64:
+		return execCvtop(inst, func(c int64) (int32, error) {
+			return int32(c), nil
+		})
+	case opI32TruncF32S:
+		return execCvtop(inst, fromF32(truncS32))
+	case opI32TruncF32U:
+		return execCvtop(inst, fromF32(truncU32))
+	case opI32TruncF64S:
+		return execCvtop(inst, truncS32)
+	case opI32TruncF64U:
+		return execCvtop(inst, truncU32)
+	case opI64ExtendI32S:
+		return execCvtop(inst, func(c int32) (int64, error) {
+			return int64(c), nil
+		})
+	case opI64ExtendI32U:
+		return execCvtop(inst, func(c int32) (int64, error) {
+			return int64(uint32(c)), nil
+		})
+	case opI64TruncF32S:
+		return execCvtop(inst, fromF32(truncS64))
+	case opI64TruncF32U:
+		return execCvtop(inst, fromF32(truncU64))
+	case opI64TruncF64S:
+		return execCvtop(inst, truncS64)
+	case opI64TruncF64U:
+		return execCvtop(inst, truncU64)
+	case opF32ConvertI32S:
+		return execCvtop(inst, func(c int32) (float32, error) {
+			return float32(c), nil
+		})
+	case opF32ConvertI32U:
+		return execCvtop(inst, func(c int32) (float32, error) {
+			return float32(uint32(c)), nil
+		})
+	case opF32ConvertI64S:
+		return execCvtop(inst, func(c int64) (float32, error) {
+			return float32(c), nil
+		})
+	case opF32ConvertI64U:
+		return execCvtop(inst, func(c int64) (float32, error) {
+			return float32(uint64(c)), nil
+		})
+	case opF32DemoteF64:
+		return execCvtop(inst, func(c float64) (float32, error) {
+			return float32(c), nil
+		})
+	case opF64ConvertI32S:
+		return execCvtop(inst, func(c int32) (float64, error) {
+			return float64(c), nil
+		})
+	case opF64ConvertI32U:
+		return execCvtop(inst, func(c int32) (float64, error) {
+			return float64(uint32(c)), nil
+		})
+	case opF64ConvertI64S:
+		return execCvtop(inst, func(c int64) (float64, error) {
+			return float64(c), nil
+		})
+	case opF64ConvertI64U:
+		return execCvtop(inst, func(c int64) (float64, error) {
+			return float64(uint64(c)), nil
+		})
+	case opF64PromoteF32:
+		return execCvtop(inst, func(c float32) (float64, error) {
+			return float64(c), nil
+		})
+	case opI32ReinterpretF32:
+		return execCvtop(inst, func(c float32) (int32, error) {
+			return int32(math.Float32bits(c)), nil
+		})
+	case opI64ReinterpretF64:
+		return execCvtop(inst, func(c float64) (int64, error) {
+			return int64(math.Float64bits(c)), nil
+		})
+	case opF32ReinterpretI32:
+		return execCvtop(inst, func(c int32) (float32, error) {
+			return math.Float32frombits(uint32(c)), nil
+		})
+	case opF64ReinterpretI64:
+		return execCvtop(inst, func(c int64) (float64, error) {
+			return math.Float64frombits(uint64(c)), nil
+		})
+	case opI32Extend8S:
+		return execCvtop(inst, func(c int32) (int32, error) {
+			return int32(int8(c)), nil
+		})
+	case opI32Extend16S:
+		return execCvtop(inst, func(c int32) (int32, error) {
+			return int32(int16(c)), nil
+		})
+	case opI64Extend8S:
+		return execCvtop(inst, func(c int64) (int64, error) {
+			return int64(int8(c)), nil
+		})
+	case opI64Extend16S:
+		return execCvtop(inst, func(c int64) (int64, error) {
+			return int64(int16(c)), nil
+		})
+	case opI64Extend32S:
+		return execCvtop(inst, func(c int64) (int64, error) {
+			return int64(int32(c)), nil
+		})
+	case opI32TruncSatF32S:
+		return execCvtop(inst, fromF32(truncSatS32))
+	case opI32TruncSatF32U:
+		return execCvtop(inst, fromF32(truncSatU32))
+	case opI32TruncSatF64S:
+		return execCvtop(inst, truncSatS32)
+	case opI32TruncSatF64U:
+		return execCvtop(inst, truncSatU32)
+	case opI64TruncSatF32S:
+		return execCvtop(inst, fromF32(truncSatS64))
+	case opI64TruncSatF32U:
+		return execCvtop(inst, fromF32(truncSatU64))
+	case opI64TruncSatF64S:
+		return execCvtop(inst, truncSatS64)
+	case opI64TruncSatF64U:
+		return execCvtop(inst, truncSatU64)
+	}
+
+	return errUnsupportedInstruction
+}
@@ -0,0 +1,231 @@
+package runtime
+
+import "math"
+
+// float is the Go types of the WebAssembly float types.
+type float interface {
+	float32 | float64
+}
+
+// fmin returns the minimum of two floats. A NaN operand is propagated as an
+// arithmetic NaN, and -0 is less than +0.
+func fmin[T float](c1, c2 T) T {
+	switch {
+	case c1 != c1 || c2 != c2:
+		return c1 + c2
+	case c1 < c2:
+		return c1
+	case c2 < c1:
+		return c2
+	case math.Signbit(float64(c1)):
+		// c1 and c2 are zeros
+		return c1
+	}
+
+	return c2
+}
+
+// fmax returns the maximum of two floats. A NaN operand is propagated as an
+// arithmetic NaN, and +0 is greater than -0.
+func fmax[T float](c1, c2 T) T {
+	switch {
+	case c1 != c1 || c2 != c2:
+		return c1 + c2
+	case c1 > c2:
+		return c1
+	case c2 > c1:
+		return c2
+	case math.Signbit(float64(c1)):
+		// c1 and c2 are zeros
+		return c2
+	}
+
+	return c1
+}
+
+const (
+	f32SignBit = 1 << 31
+	f64SignBit = 1 << 63
+)
+
+// abs, neg and copysign are not arithmetic operations; they change only the
+// sign bit even if the operand is a NaN.
+
+func f32Abs(c float32) float32 {
+	return math.Float32frombits(math.Float32bits(c) &^ f32SignBit)
+}
+
+func f32Neg(c float32) float32 {
+	return math.Float32frombits(math.Float32bits(c) ^ f32SignBit)
+}
+
+func f32Copysign(c1, c2 float32) float32 {
+	return math.Float32frombits(math.Float32bits(c1)&^f32SignBit | math.Float32bits(c2)&f32SignBit)
+}
+
+func f64Abs(c float64) float64 {
+	return math.Float64frombits(math.Float64bits(c) &^ f64SignBit)
+}
+
+func f64Neg(c float64) float64 {
+	return math.Float64frombits(math.Float64bits(c) ^ f64SignBit)
+}
+
+func f64Copysign(c1, c2 float64) float64 {
+	return math.Float64frombits(math.Float64bits(c1)&^f64SignBit | math.Float64bits(c2)&f64SignBit)
+}
+
+// f32Unop lifts a float64 function to float32. Every float32 is exactly
+// representable as a float64, and the functions lifted are exact or
+// correctly rounded, so the result is the same as computed in float32.
+func f32Unop(f func(float64) float64) func(c float32) float32 {
+	return func(c float32) float32 {
+		return float32(f(float64(c)))
+	}
+}
+
+func (inst *Instance) execF32(op *operation) error {
+	switch op.code {
+	case opF32Abs:
+		return execUnop(inst, f32Abs)
+	case opF32Neg:
+		return execUnop(inst, f32Neg)
+	case opF32Ceil:
+		return execUnop(inst, f32Unop(math.Ceil))
+	case opF32Floor:
+		return execUnop(inst, f32Unop(math.Floor))
+	case opF32Trunc:
+		return execUnop(inst, f32Unop(math.Trunc))
+	case opF32Nearest:
+		return execUnop(inst, f32Unop(math.RoundToEven))
+	case opF32Sqrt:
+		return execUnop(inst, f32Unop(math.Sqrt))
+	case opF32Add:
+		return execBinop(inst, func(c1, c2 float32) (float32, error) {
+			return c1 + c2, nil
+		})
+	case opF32Sub:
+		return execBinop(inst, func(c1, c2 float32) (float32, error) {
+			return c1 - c2, nil
+		})
+	case opF32Mul:
+		return execBinop(inst, func(c1, c2 float32) (float32, error) {
+			return c1 * c2, nil
+		})
+	case opF32Div:
+		return execBinop(inst, func(c1, c2 float32) (float32, error) {
+			return c1 / c2, nil
+		})
+	case opF32Min:
+		return execBinop(inst, func(c1, c2 float32) (float32, error) {
+			return fmin(c1, c2), nil
+		})
+	case opF32Max:
+		return execBinop(inst, func(c1, c2 float32) (float32, error) {
+			return fmax(c1, c2), nil
+		})
+	case opF32Copysign:
+		return execBinop(inst, func(c1, c2 float32) (float32, error) {
+			return f32Copysign(c1, c2), nil
+		})
+	case opF32Eq:
+		return execRelop(inst, func(c1, c2 float32) bool {
+			return c1 == c2
+		})
+	case opF32Ne:
+		return execRelop(inst, func(c1, c2 float32) bool {
+			return c1 != c2
+		})
+	case opF32Lt:
+		return execRelop(inst, func(c1, c2 float32) bool {
+			return c1 < c2
+		})
+	case opF32Gt:
+		return execRelop(inst, func(c1, c2 float32) bool {
+			return c1 > c2
+		})
+	case opF32Le:
+		return execRelop(inst, func(c1, c2 float32) bool {
+			return c1 <= c2
+		})
+	case opF32Ge:
+		return execRelop(inst, func(c1, c2 float32) bool {
+			return c1 >= c2
+		})
+	}
+
+	return errUnsupportedInstruction
+}
+
+func (inst *Instance) execF64(op *operation) error {
+	switch op.code {
+	case opF64Abs:
+		return execUnop(inst, f64Abs)
+	case opF64Neg:
+		return execUnop(inst, f64Neg)
+	case opF64Ceil:
+		return execUnop(inst, math.Ceil)
+	case opF64Floor:
+		return execUnop(inst, math.Floor)
+	case opF64Trunc:
+		return execUnop(inst, math.Trunc)
+	case opF64Nearest:
+		return execUnop(inst, math.RoundToEven)
+	case opF64Sqrt:
+		return execUnop(inst, math.Sqrt)
+	case opF64Add:
+		return execBinop(inst, func(c1, c2 float64) (float64, error) {
+			return c1 + c2, nil
+		})
+	case opF64Sub:
+		return execBinop(inst, func(c1, c2 float64) (float64, error) {
+			return c1 - c2, nil
+		})
+	case opF64Mul:
+		return execBinop(inst, func(c1, c2 float64) (float64, error) {
+			return c1 * c2, nil
+		})
+	case opF64Div:
+		return execBinop(inst, func(c1, c2 float64) (float64, error) {
+			return c1 / c2, nil
+		})
+	case opF64Min:
+		return execBinop(inst, func(c1, c2 float64) (float64, error) {
+			return fmin(c1, c2), nil
+		})
+	case opF64Max:
+		return execBinop(inst, func(c1, c2 float64) (float64, error) {
+			return fmax(c1, c2), nil
+		})
+	case opF64Copysign:
+		return execBinop(inst, func(c1, c2 float64) (float64, error) {
+			return f64Copysign(c1, c2), nil
+		})
+	case opF64Eq:
+		return execRelop(inst, func(c1, c2 float64) bool {
+			return c1 == c2
+		})
+	case opF64Ne:
+		return execRelop(inst, func(c1, c2 float64) bool {
+			return c1 != c2
+		})
+	case opF64Lt:
+		return execRelop(inst, func(c1, c2 float64) bool {
+			return c1 < c2
+		})
+	case opF64Gt:
+		return execRelop(inst, func(c1, c2 float64) bool {
+			return c1 > c2
+		})
+	case opF64Le:
+		return execRelop(inst, func(c1, c2 float64) bool {
+			return c1 <= c2
+		})
+	case opF64Ge:
+		return execRelop(inst, func(c1, c2 float64) bool {
+			return c1 >= c2
+		})
+	}
+
+	return errUnsupportedInstruction
+}
@@ -0,0 +1,27 @@
+package runtime
+
+import "github.com/kechako/wasmexec/mod"
+
+// function is a function compiled for the execution.
+type function struct {
+	f *mod.Function
+	// index of the function, or -1 for a constant expression
+	index int
+	// type of the function, which call_indirect checks
+	typ *mod.FuncType
+	// number of the parameters and the declared local variables
+	locals int
+	// compiled code and the positions of the instructions of each operation,
+	// which are empty for an imported function
+	code []operation
+	pos  []position
+}
+
+func newFunction(f *mod.Function, index int) *function {
+	return &function{
+		f:      f,
+		index:  index,
+		typ:    f.Signature(),
+		locals: len(f.Parameters) + len(f.Locals),
+	}
+}
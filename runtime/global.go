@@ -0,0 +1,127 @@
+package runtime
+
+import "github.com/kechako/wasmexec/mod/types"
+
+// Global is a global variable of a module instance.
+type Global struct {
+	typ     types.Type
+	mutable bool
+	// bit pattern of a number, or the reference of a funcref, which is
+	// kept as is because the global may be shared by the module instances
+	bits uint64
+	ref  FuncRef
+}
+
+// NewGlobal returns a global of the value, to be imported by modules. The
+// value must be an int32, an int64, a float32, a float64 or a FuncRef.
+func NewGlobal(v any, mutable bool) (*Global, error) {
+	typ := NewValue(v).Type()
+	if typ == types.Unkown {
+		return nil, errUnsupportedType
+	}
+
+	g := &Global{
+		typ:     typ,
+		mutable: mutable,
+	}
+	g.set(v)
+
+	return g, nil
+}
+
+// Type returns the type of the value.
+func (g *Global) Type() types.Type {
+	return g.typ
+}
+
+// Mutable reports whether the value can be changed.
+func (g *Global) Mutable() bool {
+	return g.mutable
+}
+
+// Get returns the value, which is an int32, an int64, a float32, a float64
+// or a FuncRef according to the type.
+func (g *Global) Get() any {
+	if g.typ == types.FuncRef {
+		return g.ref
+	}
+
+	return bitsNumber(g.bits, g.typ)
+}
+
+// Set changes the value of a mutable global. The value must be of the type
+// of the global.
+func (g *Global) Set(v any) error {
+	if !g.mutable {
+		return errImmutableGlobal
+	}
+	if NewValue(v).Type() != g.typ {
+		return errGlobalTypeMismatch
+	}
+	g.set(v)
+
+	return nil
+}
+
+// set changes the value, which must be of the type of the global.
+func (g *Global) set(v any) {
+	if ref, ok := v.(FuncRef); ok {
+		g.ref = ref
+		return
+	}
+	g.bits, _ = numberBits(v)
+}
+
+// initGlobals evaluates the initial values of the globals in order, so that
+// a global can be initialized with the globals before it. The imported
+// globals are already resolved.
+func (inst *Instance) initGlobals() error {
+	for i, g := range inst.module.mod.Globals {
+		if g.Import != nil {
+			continue
+		}
+		v, err := inst.evalConstExpr(inst.module.globalInits[i])
+		if err != nil {
+			return err
+		}
+
+		global := &Global{
+			typ:     g.Type,
+			mutable: g.Mutable,
+		}
+		global.set(v)
+		inst.globals[i] = global
+	}
+
+	return nil
+}
+
+func (inst *Instance) execGlobal(op *operation) error {
+	// a constant expression can refer to the globals not yet initialized
+	g := inst.globals[op.index]
+	if g == nil {
+		return errGlobalNotFound
+	}
+
+	switch op.code {
+	case opGlobalGet:
+		if g.typ == types.FuncRef {
+			return inst.stack.Push(inst.refBits(g.ref))
+		}
+		return inst.stack.Push(g.bits)
+	case opGlobalSet:
+		bits, err := inst.stack.Pop()
+		if err != nil {
+			return err
+		}
+		if g.typ == types.FuncRef {
+			g.ref = inst.bitsRef(bits)
+		} else {
+			g.bits = bits
+		}
+	default:
+		return errUnsupportedInstruction
+	}
+
+	return nil
+}
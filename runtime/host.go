@@ -0,0 +1,340 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/kechako/wasmexec/mod"
+	"github.com/kechako/wasmexec/mod/types"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	callerType  = reflect.TypeOf((*Caller)(nil))
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// hostValueTypes are the Go types of the parameters and the results of host
+// functions.
+var hostValueTypes = map[reflect.Type]types.Type{
+	reflect.TypeOf(int32(0)):   types.I32,
+	reflect.TypeOf(int64(0)):   types.I64,
+	reflect.TypeOf(float32(0)): types.F32,
+	reflect.TypeOf(float64(0)): types.F64,
+}
+
+// HostModule is a module implemented in Go, whose functions, memories,
+// globals and tables are imported by the module name and the names.
+//
+//	host := runtime.NewHostModule("env").
+//		Func("log", func(v int32) { log.Println(v) }).
+//		Func("now", func() int64 { return time.Now().Unix() })
+//	inst, err := runtime.New(m, runtime.HostModules(host))
+type HostModule struct {
+	name     string
+	funcs    map[string]*hostFunc
+	memories map[string]*Memory
+	globals  map[string]*Global
+	tables   map[string]*Table
+
+	// first error in adding the fields, which is reported at instantiation
+	err error
+}
+
+// NewHostModule returns an empty host module of the name.
+func NewHostModule(name string) *HostModule {
+	return &HostModule{
+		name:     name,
+		funcs:    make(map[string]*hostFunc),
+		memories: make(map[string]*Memory),
+		globals:  make(map[string]*Global),
+		tables:   make(map[string]*Table),
+	}
+}
+
+// Name returns the module name.
+func (hm *HostModule) Name() string {
+	return hm.name
+}
+
+// Func adds a function of the name. fn must be a Go function whose
+// parameters and results are int32, int64, float32 or float64, which are
+// i32, i64, f32 and f64 respectively. It can take a context.Context and then
+// a *Caller before the parameters, and return an error after the results,
+// which traps the execution if it is not nil.
+func (hm *HostModule) Func(name string, fn any) *HostModule {
+	h, err := newHostFunc(fn)
+	if err != nil {
+		hm.setErr(fmt.Errorf("func %q: %w", name, err))
+		return hm
+	}
+	h.name = hm.name + "." + name
+	hm.funcs[name] = h
+
+	return hm
+}
+
+// Memory adds a memory of the name, which is shared with the modules
+// importing it.
+func (hm *HostModule) Memory(name string, mem *Memory) *HostModule {
+	hm.memories[name] = mem
+	return hm
+}
+
+// Global adds a global of the name, which is shared with the modules
+// importing it.
+func (hm *HostModule) Global(name string, g *Global) *HostModule {
+	hm.globals[name] = g
+	return hm
+}
+
+// Table adds a table of the name, which is shared with the modules
+// importing it.
+func (hm *HostModule) Table(name string, t *Table) *HostModule {
+	hm.tables[name] = t
+	return hm
+}
+
+func (hm *HostModule) setErr(err error) {
+	if hm.err == nil {
+		hm.err = fmt.Errorf("host module %q: %w", hm.name, err)
+	}
+}
+
+// Caller is the module instance calling a host function.
+type Caller struct {
+	inst *Instance
+}
+
+// Memory returns the memory of the calling module, or nil if the module has
+// no memory.
+func (c *Caller) Memory() *Memory {
+	return c.inst.memory
+}
+
+// hostFunc is a Go function called as a function of the type.
+type hostFunc struct {
+	name string
+	fn   reflect.Value
+	typ  *mod.FuncType
+
+	// whether fn takes a context.Context, a *Caller, and returns an error
+	ctx    bool
+	caller bool
+	err    bool
+}
+
+func newHostFunc(fn any) (*hostFunc, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("%w: %T is not a function", errUnsupportedType, fn)
+	}
+	t := v.Type()
+	if t.IsVariadic() {
+		return nil, fmt.Errorf("%w: variadic function", errUnsupportedType)
+	}
+
+	h := &hostFunc{
+		fn:  v,
+		typ: &mod.FuncType{},
+	}
+
+	in := 0
+	if in < t.NumIn() && t.In(in) == contextType {
+		h.ctx = true
+		in++
+	}
+	if in < t.NumIn() && t.In(in) == callerType {
+		h.caller = true
+		in++
+	}
+	for ; in < t.NumIn(); in++ {
+		typ, ok := hostValueTypes[t.In(in)]
+		if !ok {
+			return nil, fmt.Errorf("%w: parameter of %s", errUnsupportedType, t.In(in))
+		}
+		h.typ.Parameters = append(h.typ.Parameters, typ)
+	}
+
+	out := t.NumOut()
+	if out > 0 && t.Out(out-1) == errorType {
+		h.err = true
+		out--
+	}
+	for i := 0; i < out; i++ {
+		typ, ok := hostValueTypes[t.Out(i)]
+		if !ok {
+			return nil, fmt.Errorf("%w: result of %s", errUnsupportedType, t.Out(i))
+		}
+		h.typ.Results = append(h.typ.Results, typ)
+	}
+
+	return h, nil
+}
+
+// call calls the function with the arguments, which are the values of the
+// parameter types, and returns the results.
+func (h *hostFunc) call(ctx context.Context, caller *Caller, args []any) ([]any, error) {
+	in := make([]reflect.Value, 0, len(args)+2)
+	if h.ctx {
+		in = append(in, reflect.ValueOf(ctx))
+	}
+	if h.caller {
+		in = append(in, reflect.ValueOf(caller))
+	}
+	for _, arg := range args {
+		in = append(in, reflect.ValueOf(arg))
+	}
+
+	out := h.fn.Call(in)
+	if h.err {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return nil, &hostError{name: h.name, err: err}
+		}
+		out = out[:len(out)-1]
+	}
+
+	results := make([]any, len(out))
+	for i, v := range out {
+		results[i] = v.Interface()
+	}
+
+	return results, nil
+}
+
+// callHost calls the imported function f, which takes the arguments from
+// the stack and pushes the results.
+func (inst *Instance) callHost(ctx context.Context, f *mod.Function) error {
+	h, ok := inst.hostFuncs[f]
+	if !ok {
+		return errFunctionNotFound
+	}
+
+	args, err := inst.popParameters(f.Parameters)
+	if err != nil {
+		return err
+	}
+
+	results, err := h.call(ctx, &Caller{inst: inst}, args)
+	if err != nil {
+		return err
+	}
+	for _, v := range results {
+		if err := inst.stack.Push(inst.valueBits(v)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveImports looks up the imports of the module in the host modules,
+// which must have the fields of the compatible types.
+func (inst *Instance) resolveImports(hosts map[string]*HostModule) error {
+	for _, hm := range hosts {
+		if hm.err != nil {
+			return hm.err
+		}
+	}
+
+	unknown := func(imp *mod.Import) error {
+		return fmt.Errorf("%w %q %q", errUnknownImport, imp.Module, imp.Name)
+	}
+	lookup := func(imp *mod.Import) (*HostModule, error) {
+		hm, ok := hosts[imp.Module]
+		if !ok {
+			return nil, unknown(imp)
+		}
+		return hm, nil
+	}
+	incompatible := func(imp *mod.Import) error {
+		return fmt.Errorf("%w %q %q", errIncompatibleImportType, imp.Module, imp.Name)
+	}
+
+	for _, f := range inst.module.mod.Functions {
+		if f.Import == nil {
+			continue
+		}
+		hm, err := lookup(f.Import)
+		if err != nil {
+			return err
+		}
+		h, ok := hm.funcs[f.Import.Name]
+		if !ok {
+			return unknown(f.Import)
+		}
+		if !h.typ.Equal(f.Signature()) {
+			return incompatible(f.Import)
+		}
+		inst.hostFuncs[f] = h
+	}
+
+	for i, t := range inst.module.mod.Tables {
+		if t.Import == nil {
+			continue
+		}
+		hm, err := lookup(t.Import)
+		if err != nil {
+			return err
+		}
+		table, ok := hm.tables[t.Import.Name]
+		if !ok {
+			return unknown(t.Import)
+		}
+		if t.Type != types.FuncRef || !matchLimits(table.Size(), table.max, t.Limits) {
+			return incompatible(t.Import)
+		}
+		inst.tables[i] = table
+	}
+
+	for i, m := range inst.module.mod.Memories {
+		if m.Import == nil {
+			continue
+		}
+		hm, err := lookup(m.Import)
+		if err != nil {
+			return err
+		}
+		mem, ok := hm.memories[m.Import.Name]
+		if !ok {
+			return unknown(m.Import)
+		}
+		if !matchLimits(mem.Size(), mem.max, m.Limits) {
+			return incompatible(m.Import)
+		}
+		if i == 0 {
+			inst.memory = mem
+		}
+	}
+
+	for i, g := range inst.module.mod.Globals {
+		if g.Import == nil {
+			continue
+		}
+		hm, err := lookup(g.Import)
+		if err != nil {
+			return err
+		}
+		global, ok := hm.globals[g.Import.Name]
+		if !ok {
+			return unknown(g.Import)
+		}
+		if global.typ != g.Type || global.mutable != g.Mutable {
+			return incompatible(g.Import)
+		}
+		inst.globals[i] = global
+	}
+
+	return nil
+}
+
+// matchLimits reports whether a memory or a table of the size and the
+// maximum can be imported as limits.
+func matchLimits(size, max uint32, limits mod.Limits) bool {
+	if size < limits.Min {
+		return false
+	}
+
+	return !limits.HasMax || max <= limits.Max
+}
@@ -0,0 +1,145 @@
+package runtime
+
+import (
+	"math"
+	"math/bits"
+)
+
+func (inst *Instance) execI32(op *operation) error {
+	switch op.code {
+	case opI32Clz:
+		return execUnop(inst, func(c int32) int32 {
+			return int32(bits.LeadingZeros32(uint32(c)))
+		})
+	case opI32Ctz:
+		return execUnop(inst, func(c int32) int32 {
+			return int32(bits.TrailingZeros32(uint32(c)))
+		})
+	case opI32Popcnt:
+		return execUnop(inst, func(c int32) int32 {
+			return int32(bits.OnesCount32(uint32(c)))
+		})
+	case opI32Add:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			return c1 + c2, nil
+		})
+	case opI32Sub:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			return c1 - c2, nil
+		})
+	case opI32Mul:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			return c1 * c2, nil
+		})
+	case opI32DivS:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			if c2 == 0 {
+				return 0, errIntegerDivideByZero
+			}
+			if c1 == math.MinInt32 && c2 == -1 {
+				return 0, errIntegerOverflow
+			}
+			return c1 / c2, nil
+		})
+	case opI32DivU:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			if c2 == 0 {
+				return 0, errIntegerDivideByZero
+			}
+			return int32(uint32(c1) / uint32(c2)), nil
+		})
+	case opI32RemS:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			if c2 == 0 {
+				return 0, errIntegerDivideByZero
+			}
+			// math.MinInt32 % -1 is 0 in Go as required
+			return c1 % c2, nil
+		})
+	case opI32RemU:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			if c2 == 0 {
+				return 0, errIntegerDivideByZero
+			}
+			return int32(uint32(c1) % uint32(c2)), nil
+		})
+	case opI32And:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			return c1 & c2, nil
+		})
+	case opI32Or:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			return c1 | c2, nil
+		})
+	case opI32Xor:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			return c1 ^ c2, nil
+		})
+	case opI32Shl:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			return c1 << (uint32(c2) % 32), nil
+		})
+	case opI32ShrS:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			return c1 >> (uint32(c2) % 32), nil
+		})
+	case opI32ShrU:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			return int32(uint32(c1) >> (uint32(c2) % 32)), nil
+		})
+	case opI32Rotl:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			return int32(bits.RotateLeft32(uint32(c1), int(uint32(c2)%32))), nil
+		})
+	case opI32Rotr:
+		return execBinop(inst, func(c1, c2 int32) (int32, error) {
+			return int32(bits.RotateLeft32(uint32(c1), -int(uint32(c2)%32))), nil
+		})
+	case opI32Eqz:
+		return execTestop(inst, func(c int32) bool {
+			return c == 0
+		})
+	case opI32Eq:
+		return execRelop(inst, func(c1, c2 int32) bool {
+			return c1 == c2
+		})
+	case opI32Ne:
+		return execRelop(inst, func(c1, c2 int32) bool {
+			return c1 != c2
+		})
+	case opI32LtS:
+		return execRelop(inst, func(c1, c2 int32) bool {
+			return c1 < c2
+		})
+	case opI32LtU:
+		return execRelop(inst, func(c1, c2 int32) bool {
+			return uint32(c1) < uint32(c2)
+		})
+	case opI32GtS:
+		return execRelop(inst, func(c1, c2 int32) bool {
+			return c1 > c2
+		})
+	case opI32GtU:
+		return execRelop(inst, func(c1, c2 int32) bool {
+			return uint32(c1) > uint32(c2)
+		})
+	case opI32LeS:
+		return execRelop(inst, func(c1, c2 int32) bool {
+			return c1 <= c2
+		})
+	case opI32LeU:
+		return execRelop(inst, func(c1, c2 int32) bool {
+			return uint32(c1) <= uint32(c2)
+		})
+	case opI32GeS:
+		return execRelop(inst, func(c1, c2 int32) bool {
+			return c1 >= c2
+		})
+	case opI32GeU:
+		return execRelop(inst, func(c1, c2 int32) bool {
+			return uint32(c1) >= uint32(c2)
+		})
+	}
+
+	return errUnsupportedInstruction
+}
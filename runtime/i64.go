@@ -0,0 +1,145 @@
+package runtime
+
+import (
+	"math"
+	"math/bits"
+)
+
+func (inst *Instance) execI64(op *operation) error {
+	switch op.code {
+	case opI64Clz:
+		return execUnop(inst, func(c int64) int64 {
+			return int64(bits.LeadingZeros64(uint64(c)))
+		})
+	case opI64Ctz:
+		return execUnop(inst, func(c int64) int64 {
+			return int64(bits.TrailingZeros64(uint64(c)))
+		})
+	case opI64Popcnt:
+		return execUnop(inst, func(c int64) int64 {
+			return int64(bits.OnesCount64(uint64(c)))
+		})
+	case opI64Add:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			return c1 + c2, nil
+		})
+	case opI64Sub:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			return c1 - c2, nil
+		})
+	case opI64Mul:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			return c1 * c2, nil
+		})
+	case opI64DivS:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			if c2 == 0 {
+				return 0, errIntegerDivideByZero
+			}
+			if c1 == math.MinInt64 && c2 == -1 {
+				return 0, errIntegerOverflow
+			}
+			return c1 / c2, nil
+		})
+	case opI64DivU:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			if c2 == 0 {
+				return 0, errIntegerDivideByZero
+			}
+			return int64(uint64(c1) / uint64(c2)), nil
+		})
+	case opI64RemS:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			if c2 == 0 {
+				return 0, errIntegerDivideByZero
+			}
+			// math.MinInt64 % -1 is 0 in Go as required
+			return c1 % c2, nil
+		})
+	case opI64RemU:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			if c2 == 0 {
+				return 0, errIntegerDivideByZero
+			}
+			return int64(uint64(c1) % uint64(c2)), nil
+		})
+	case opI64And:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			return c1 & c2, nil
+		})
+	case opI64Or:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			return c1 | c2, nil
+		})
+	case opI64Xor:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			return c1 ^ c2, nil
+		})
+	case opI64Shl:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			return c1 << (uint64(c2) % 64), nil
+		})
+	case opI64ShrS:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			return c1 >> (uint64(c2) % 64), nil
+		})
+	case opI64ShrU:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			return int64(uint64(c1) >> (uint64(c2) % 64)), nil
+		})
+	case opI64Rotl:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			return int64(bits.RotateLeft64(uint64(c1), int(uint64(c2)%64))), nil
+		})
+	case opI64Rotr:
+		return execBinop(inst, func(c1, c2 int64) (int64, error) {
+			return int64(bits.RotateLeft64(uint64(c1), -int(uint64(c2)%64))), nil
+		})
+	case opI64Eqz:
+		return execTestop(inst, func(c int64) bool {
+			return c == 0
+		})
+	case opI64Eq:
+		return execRelop(inst, func(c1, c2 int64) bool {
+			return c1 == c2
+		})
+	case opI64Ne:
+		return execRelop(inst, func(c1, c2 int64) bool {
+			return c1 != c2
+		})
+	case opI64LtS:
+		return execRelop(inst, func(c1, c2 int64) bool {
+			return c1 < c2
+		})
+	case opI64LtU:
+		return execRelop(inst, func(c1, c2 int64) bool {
+			return uint64(c1) < uint64(c2)
+		})
+	case opI64GtS:
+		return execRelop(inst, func(c1, c2 int64) bool {
+			return c1 > c2
+		})
+	case opI64GtU:
+		return execRelop(inst, func(c1, c2 int64) bool {
+			return uint64(c1) > uint64(c2)
+		})
+	case opI64LeS:
+		return execRelop(inst, func(c1, c2 int64) bool {
+			return c1 <= c2
+		})
+	case opI64LeU:
+		return execRelop(inst, func(c1, c2 int64) bool {
+			return uint64(c1) <= uint64(c2)
+		})
+	case opI64GeS:
+		return execRelop(inst, func(c1, c2 int64) bool {
+			return c1 >= c2
+		})
+	case opI64GeU:
+		return execRelop(inst, func(c1, c2 int64) bool {
+			return uint64(c1) >= uint64(c2)
+		})
+	}
+
+	return errUnsupportedInstruction
+}
@@ -0,0 +1,730 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/kechako/wasmexec/mod"
+	"github.com/kechako/wasmexec/mod/instruction"
+)
+
+// ErrOutOfFuel is the error of a function which runs out of the fuel.
+var ErrOutOfFuel = errors.New("out of fuel")
+
+var (
+	errExportNotFound            = errors.New("export is not found")
+	errExportTargetNotFunction   = errors.New("export target is not a function")
+	errFunctionNotFound          = errors.New("function is not found")
+	errTypeNotFound              = errors.New("type is not found")
+	errBlockNotFound             = errors.New("block is not found")
+	errLabelNotFound             = errors.New("label is not found")
+	errStackInconsistent         = errors.New("stack is inconsistent")
+	errStackOverflow             = errors.New("stack overflow")
+	errCallStackExhausted        = errors.New("call stack exhausted")
+	errPanic                     = errors.New("panic in execution")
+	errLocalVariableInconsistent = errors.New("local variables are inconsistent")
+	errUnreachable               = errors.New("unreachable")
+	errIntegerDivideByZero       = errors.New("integer divide by zero")
+	errIntegerOverflow           = errors.New("integer overflow")
+	errInvalidConversion         = errors.New("invalid conversion to integer")
+	errMemoryNotFound            = errors.New("memory is not found")
+	errDataNotFound              = errors.New("data segment is not found")
+	errGlobalNotFound            = errors.New("global is not found")
+	errExportTargetNotGlobal     = errors.New("export target is not a global")
+	errImmutableGlobal           = errors.New("global is immutable")
+	errGlobalTypeMismatch        = errors.New("value does not match the type of the global")
+	errOutOfBoundsMemoryAccess   = errors.New("out of bounds memory access")
+	errMemoryLimitExceeded       = errors.New("memory size exceeds the limit")
+	errTableNotFound             = errors.New("table is not found")
+	errElementNotFound           = errors.New("elem segment is not found")
+	errExportTargetNotTable      = errors.New("export target is not a table")
+	errOutOfBoundsTableAccess    = errors.New("out of bounds table access")
+	errTableLimitExceeded        = errors.New("table size exceeds the limit")
+	errUndefinedElement          = errors.New("undefined element")
+	errUninitializedElement      = errors.New("uninitialized element")
+	errIndirectCallTypeMismatch  = errors.New("indirect call type mismatch")
+	errUnknownImport             = errors.New("unknown import")
+	errIncompatibleImportType    = errors.New("incompatible import type")
+	errArgumentCountMismatch     = errors.New("wrong number of arguments")
+	errArgumentTypeMismatch      = errors.New("argument type mismatch")
+	errUnsupportedType           = errors.New("unsupported type")
+	errUnsupportedInstruction    = errors.New("unsupported instruction")
+)
+
+// Instance is an instance of a compiled module, which has its own stack,
+// memory, globals and tables isolated from the other instances of the
+// module.
+type Instance struct {
+	module *Module
+	stack  *Stack
+	memory *Memory
+	// data segments, where the dropped segments are nil
+	data [][]byte
+	// elem segments, where the dropped segments are nil
+	elems [][]FuncRef
+
+	// tables and globals by their indices
+	tables  []*Table
+	globals []*Global
+
+	// Go functions of the imported functions
+	hostFuncs map[*mod.Function]*hostFunc
+
+	// maximum number of the function frames on the stack
+	maxCallDepth int
+
+	// references on the stack, which can be of the other instances
+	refs *refTable
+
+	// remaining fuel, or nil if the fuel is not metered
+	fuel      *uint64
+	fuelCosts map[instruction.InstructionName]uint64
+}
+
+// VM is the former name of Instance.
+//
+// Deprecated: Use Instance, which New now returns with an error.
+type VM = Instance
+
+// New compiles and instantiates the module, which is a shorthand for Compile
+// and Module.Instantiate.
+func New(m *mod.Module, opts ...Option) (*Instance, error) {
+	compiled, err := Compile(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return compiled.Instantiate(opts...)
+}
+
+// Instantiate instantiates the module, and returns an error if it fails to
+// resolve the imports in the host modules, or to initialize the globals, the
+// tables with the active elem segments or the memory with the active data
+// segments. The start function runs at last, and its trap is also returned.
+func (m *Module) Instantiate(opts ...Option) (*Instance, error) {
+	instOpts := instanceOptions{
+		stackCapacity: 1024,
+		maxCallDepth:  512,
+		memoryLimit:   defaultMemoryLimit,
+		tableLimit:    defaultTableLimit,
+		hosts:         make(map[string]*HostModule),
+	}
+	for _, opt := range opts {
+		opt.apply(&instOpts)
+	}
+
+	inst := &Instance{
+		module:    m,
+		stack:     NewStack(instOpts.stackCapacity),
+		refs:      newRefTable(),
+		hostFuncs: make(map[*mod.Function]*hostFunc),
+		tables:    make([]*Table, len(m.mod.Tables)),
+		globals:   make([]*Global, len(m.mod.Globals)),
+		fuelCosts: instOpts.fuelCosts,
+
+		maxCallDepth: instOpts.maxCallDepth,
+	}
+	if instOpts.metered {
+		fuel := instOpts.fuel
+		inst.fuel = &fuel
+	}
+	if err := inst.resolveImports(instOpts.hosts); err != nil {
+		return nil, err
+	}
+	if err := inst.makeTables(instOpts.tableLimit); err != nil {
+		return nil, err
+	}
+
+	if mems := m.mod.Memories; len(mems) > 0 && mems[0].Import == nil {
+		mem, err := newMemory(mems[0].Limits, instOpts.memoryLimit)
+		if err != nil {
+			return nil, fmt.Errorf("memory 0: %w", err)
+		}
+		inst.memory = mem
+	}
+
+	if err := inst.initGlobals(); err != nil {
+		return nil, err
+	}
+
+	if err := inst.initElements(); err != nil {
+		return nil, err
+	}
+
+	if err := inst.initData(); err != nil {
+		return nil, err
+	}
+
+	if err := inst.start(); err != nil {
+		return nil, err
+	}
+
+	return inst, nil
+}
+
+// start calls the start function if the module has one.
+func (inst *Instance) start() error {
+	if inst.module.mod.Start == nil {
+		return nil
+	}
+
+	n, ok := inst.module.mod.FunctionIndex(*inst.module.mod.Start)
+	if !ok {
+		return errFunctionNotFound
+	}
+	if err := inst.callFunc(context.Background(), inst.module.funcs[n]); err != nil {
+		return fmt.Errorf("start function: %w", err)
+	}
+
+	return nil
+}
+
+// Memory returns the memory of the instance, or nil if the module has no
+// memory.
+func (inst *Instance) Memory() *Memory {
+	return inst.memory
+}
+
+// Table returns the exported table of the name.
+func (inst *Instance) Table(name string) (*Table, error) {
+	e, ok := inst.module.exports[name]
+	if !ok {
+		return nil, errExportNotFound
+	}
+	if e.Target != mod.ExportTable {
+		return nil, errExportTargetNotTable
+	}
+
+	n, ok := inst.module.mod.TableIndex(e.Index)
+	if !ok {
+		return nil, errTableNotFound
+	}
+
+	return inst.tables[n], nil
+}
+
+// Global returns the exported global of the name, whose value can be read
+// and written from Go.
+func (inst *Instance) Global(name string) (*Global, error) {
+	e, ok := inst.module.exports[name]
+	if !ok {
+		return nil, errExportNotFound
+	}
+	if e.Target != mod.ExportGlobal {
+		return nil, errExportTargetNotGlobal
+	}
+
+	n, ok := inst.module.mod.GlobalIndex(e.Index)
+	if !ok {
+		return nil, errGlobalNotFound
+	}
+
+	return inst.globals[n], nil
+}
+
+// Fuel returns the remaining fuel, and reports false if the fuel is not
+// metered.
+func (inst *Instance) Fuel() (uint64, bool) {
+	if inst.fuel == nil {
+		return 0, false
+	}
+
+	return *inst.fuel, true
+}
+
+// Refuel adds the fuel, which saturates at the maximum of uint64. It does
+// nothing if the fuel is not metered.
+func (inst *Instance) Refuel(amount uint64) {
+	if inst.fuel == nil {
+		return
+	}
+
+	if *inst.fuel > math.MaxUint64-amount {
+		*inst.fuel = math.MaxUint64
+	} else {
+		*inst.fuel += amount
+	}
+}
+
+// consumeFuel consumes the fuel of the instruction, which costs 1 unless
+// the cost is given by FuelCosts.
+func (inst *Instance) consumeFuel(i instruction.Instruction) error {
+	cost, ok := inst.fuelCosts[i.Name()]
+	if !ok {
+		cost = 1
+	}
+	if *inst.fuel < cost {
+		return ErrOutOfFuel
+	}
+	*inst.fuel -= cost
+
+	return nil
+}
+
+// Module returns the compiled module of the instance.
+func (inst *Instance) Module() *Module {
+	return inst.module
+}
+
+// FuncType returns the type of the exported function of the name.
+func (inst *Instance) FuncType(name string) (*mod.FuncType, error) {
+	return inst.module.FuncType(name)
+}
+
+// ExecFunc calls the exported function of the name with the arguments, and
+// returns the results. The arguments must be an int32, an int64, a float32,
+// a float64 or a FuncRef according to the parameter types.
+func (inst *Instance) ExecFunc(ctx context.Context, name string, args ...any) ([]any, error) {
+	fn, err := inst.module.exportedFunc(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f := fn.f
+	if len(args) != len(f.Parameters) {
+		return nil, fmt.Errorf("%w: expected %d but got %d", errArgumentCountMismatch, len(f.Parameters), len(args))
+	}
+	for i, arg := range args {
+		v := NewValue(arg)
+		if typ := f.Parameters[i].Type; v.Type() != typ {
+			return nil, fmt.Errorf("%w: argument %d must be %s but got %T", errArgumentTypeMismatch, i, typ, arg)
+		}
+	}
+	for _, arg := range args {
+		if err := inst.stack.Push(inst.valueBits(arg)); err != nil {
+			inst.stack.clear()
+			return nil, inst.newTrap(err)
+		}
+	}
+
+	err = inst.callFunc(ctx, fn)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := inst.popResults(f.Results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, err
+}
+
+// callFunc calls the function, and returns a *Trap if the execution is
+// aborted, after which the stack is cleared.
+func (inst *Instance) callFunc(ctx context.Context, fn *function) error {
+	if err := inst.recoverExecFunc(ctx, fn); err != nil {
+		trap := inst.newTrap(err)
+		inst.stack.clear()
+		return trap
+	}
+
+	return nil
+}
+
+// recoverExecFunc calls execFunc, and returns an error instead of panicking
+// so that no module can crash the host. Running out of memory cannot be
+// recovered, which MemoryLimit and TableLimit keep the growth from.
+func (inst *Instance) recoverExecFunc(ctx context.Context, fn *function) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errPanic, r)
+		}
+	}()
+
+	return inst.execFunc(ctx, fn)
+}
+
+// execFunc executes the compiled code of the function and the functions it
+// calls in a loop.
+func (inst *Instance) execFunc(ctx context.Context, fn *function) error {
+	if fn.f.Import != nil {
+		return inst.callHost(ctx, fn.f)
+	}
+
+	s := inst.stack
+	// the frames below base belong to the caller
+	base := len(s.frames)
+	if err := inst.initFunction(fn); err != nil {
+		return err
+	}
+	fr := s.topFrame()
+
+	// nil for a context which is never canceled
+	done := ctx.Done()
+
+	for {
+		op := &fr.fn.code[fr.pc]
+		fr.pc++
+
+		if inst.fuel != nil && op.inst != nil {
+			if err := inst.consumeFuel(op.inst); err != nil {
+				return err
+			}
+		}
+
+		switch op.code {
+		case opUnreachable:
+			return errUnreachable
+		case opNop, opBlock, opLoop:
+		case opIf:
+			c, err := pop[int32](inst)
+			if err != nil {
+				return err
+			}
+			if c == 0 {
+				fr.pc = op.br.target
+			}
+		case opJump:
+			fr.pc = op.br.target
+		case opBr:
+			if err := interrupted(ctx, done); err != nil {
+				return err
+			}
+			if err := inst.branch(fr, &op.br); err != nil {
+				return err
+			}
+		case opBrIf:
+			if err := interrupted(ctx, done); err != nil {
+				return err
+			}
+			c, err := pop[int32](inst)
+			if err != nil {
+				return err
+			}
+			if c != 0 {
+				if err := inst.branch(fr, &op.br); err != nil {
+					return err
+				}
+			}
+		case opBrTable:
+			if err := interrupted(ctx, done); err != nil {
+				return err
+			}
+			c, err := pop[int32](inst)
+			if err != nil {
+				return err
+			}
+			// an operand out of range selects the default branch at the end
+			n := uint32(len(op.table) - 1)
+			if uint32(c) < n {
+				n = uint32(c)
+			}
+			if err := inst.branch(fr, &op.table[n]); err != nil {
+				return err
+			}
+		case opReturn:
+			if err := s.unwind(fr.locals, op.br.arity); err != nil {
+				return err
+			}
+			s.popFrame()
+			if len(s.frames) == base {
+				return nil
+			}
+			fr = s.topFrame()
+		case opCall:
+			if err := interrupted(ctx, done); err != nil {
+				return err
+			}
+			if err := inst.call(ctx, op.fn); err != nil {
+				return err
+			}
+			fr = s.topFrame()
+		case opCallIndirect:
+			if err := interrupted(ctx, done); err != nil {
+				return err
+			}
+			ref, err := inst.indirectFunc(op)
+			if err != nil {
+				return err
+			}
+			if err := inst.callRef(ctx, ref); err != nil {
+				return err
+			}
+			fr = s.topFrame()
+		case opDrop:
+			if _, err := s.Pop(); err != nil {
+				return err
+			}
+		case opLocalGet:
+			if err := s.Push(s.values[fr.locals+op.index]); err != nil {
+				return err
+			}
+		case opLocalSet:
+			v, err := s.Pop()
+			if err != nil {
+				return err
+			}
+			s.values[fr.locals+op.index] = v
+		case opLocalTee:
+			if len(s.values) == 0 {
+				return errStackInconsistent
+			}
+			s.values[fr.locals+op.index] = s.values[len(s.values)-1]
+		case opGlobalGet, opGlobalSet:
+			if err := inst.execGlobal(op); err != nil {
+				return err
+			}
+		case opI32Const, opI64Const, opF32Const, opF64Const:
+			if err := s.Push(op.bits); err != nil {
+				return err
+			}
+		default:
+			if err := inst.execOperation(op); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// execOperation executes the operations other than the control and the
+// variable ones by their families, whose opcodes are contiguous.
+func (inst *Instance) execOperation(op *operation) error {
+	switch code := op.code; {
+	case code <= opI32GeU:
+		return inst.execI32(op)
+	case code <= opI64GeU:
+		return inst.execI64(op)
+	case code <= opF32Ge:
+		return inst.execF32(op)
+	case code <= opF64Ge:
+		return inst.execF64(op)
+	case code <= opI64TruncSatF64U:
+		return inst.execConversion(op)
+	case code >= opRefNull && code <= opRefFunc:
+		return inst.execReference(op)
+	case code >= opTableGet && code <= opElemDrop:
+		return inst.execTable(op)
+	case code >= opI32Load && code <= opDataDrop:
+		return inst.execMemory(op)
+	}
+
+	return errUnsupportedInstruction
+}
+
+// call calls fn from a function, which is executed in the loop of execFunc
+// unless it is imported.
+func (inst *Instance) call(ctx context.Context, fn *function) error {
+	if fn.f.Import != nil {
+		return inst.callHost(ctx, fn.f)
+	}
+
+	return inst.initFunction(fn)
+}
+
+// callRef calls the function of the reference from a function. A function
+// of another instance is executed on the stack of inst, with the globals,
+// the memory and the tables of the other instance.
+func (inst *Instance) callRef(ctx context.Context, ref FuncRef) error {
+	if ref.inst == inst {
+		return inst.call(ctx, ref.fn)
+	}
+
+	// the arguments and the results stay on the stack of inst, and a trap
+	// has the frames of both instances
+	return ref.inst.on(inst).execFunc(ctx, ref.fn)
+}
+
+// on returns a copy of inst which runs on the stack of caller, with the
+// references, the fuel and the call depth of caller. The memory, the tables
+// and the globals are still of inst.
+func (inst *Instance) on(caller *Instance) *Instance {
+	other := *inst
+	other.stack = caller.stack
+	other.refs = caller.refs
+	other.fuel = caller.fuel
+	other.fuelCosts = caller.fuelCosts
+	other.maxCallDepth = caller.maxCallDepth
+
+	return &other
+}
+
+// interrupted returns an error wrapping the error of ctx if done is closed.
+// It is checked at branches and calls, through which a function can run
+// forever, so that the execution can be canceled.
+func interrupted(ctx context.Context, done <-chan struct{}) error {
+	if done == nil {
+		return nil
+	}
+
+	select {
+	case <-done:
+		return fmt.Errorf("execution is interrupted: %w", ctx.Err())
+	default:
+		return nil
+	}
+}
+
+// evalConstExpr evaluates a compiled constant expression, which results in
+// a value. It consumes no fuel.
+func (inst *Instance) evalConstExpr(fn *function) (any, error) {
+	fuel := inst.fuel
+	inst.fuel = nil
+	defer func() {
+		inst.fuel = fuel
+	}()
+
+	if err := inst.callFunc(context.Background(), fn); err != nil {
+		return nil, err
+	}
+
+	results, err := inst.popResults(fn.f.Results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results[0], nil
+}
+
+// initFunction pushes the frame of the function, whose parameters are on
+// the stack, followed by the local variables initialized with zero, which
+// is also the null reference.
+func (inst *Instance) initFunction(fn *function) error {
+	if len(inst.stack.frames) >= inst.maxCallDepth {
+		return errCallStackExhausted
+	}
+
+	locals := inst.stack.Len() - len(fn.f.Parameters)
+	for i := len(fn.f.Parameters); i < fn.locals; i++ {
+		if err := inst.stack.Push(0); err != nil {
+			return err
+		}
+	}
+
+	return inst.stack.pushFrame(frame{
+		fn:     fn,
+		locals: locals,
+	})
+}
+
+// branch takes the branch in the function of fr, which keeps only the top
+// values of the arity at the height of the destination.
+func (inst *Instance) branch(fr *frame, br *branch) error {
+	if err := inst.stack.unwind(fr.locals+br.height, br.arity); err != nil {
+		return err
+	}
+	fr.pc = br.target
+
+	return nil
+}
+
+// popResults pops the results, and returns them in the order of the result
+// types.
+func (inst *Instance) popResults(results []*mod.Result) ([]any, error) {
+	values := make([]any, len(results))
+	for i := len(results) - 1; i >= 0; i-- {
+		bits, err := inst.stack.Pop()
+		if err != nil {
+			return nil, err
+		}
+
+		values[i] = inst.bitsValue(bits, results[i].Type)
+	}
+
+	return values, nil
+}
+
+// popParameters pops the parameters, and returns them in the order of the
+// parameter types.
+func (inst *Instance) popParameters(params []*mod.Local) ([]any, error) {
+	values := make([]any, len(params))
+	for i := len(params) - 1; i >= 0; i-- {
+		bits, err := inst.stack.Pop()
+		if err != nil {
+			return nil, err
+		}
+
+		values[i] = inst.bitsValue(bits, params[i].Type)
+	}
+
+	return values, nil
+}
+
+type instanceOptions struct {
+	stackCapacity int
+	maxCallDepth  int
+	memoryLimit   uint32
+	tableLimit    uint32
+	hosts         map[string]*HostModule
+	metered       bool
+	fuel          uint64
+	fuelCosts     map[instruction.InstructionName]uint64
+}
+
+type Option interface {
+	apply(opts *instanceOptions)
+}
+
+type optionFunc func(opts *instanceOptions)
+
+func (f optionFunc) apply(opts *instanceOptions) {
+	f(opts)
+}
+
+func StackCapacity(cap int) Option {
+	return optionFunc(func(opts *instanceOptions) {
+		opts.stackCapacity = cap
+	})
+}
+
+// MaxCallDepth limits the number of the nested function calls, which is 512
+// by default. A function traps when it calls a function beyond the limit,
+// and so does it when the stack exceeds StackCapacity.
+func MaxCallDepth(depth int) Option {
+	return optionFunc(func(opts *instanceOptions) {
+		opts.maxCallDepth = depth
+	})
+}
+
+// MemoryLimit limits the number of pages of the memory defined in the module,
+// in addition to the maximum of the memory, which is 4096 pages (256 MiB) by
+// default. The instantiation fails if the minimum size of the memory exceeds
+// the limit.
+func MemoryLimit(pages uint32) Option {
+	return optionFunc(func(opts *instanceOptions) {
+		opts.memoryLimit = pages
+	})
+}
+
+// TableLimit limits the number of elements of the tables defined in the
+// module, in addition to the maximum of the tables, which is 1048576 by
+// default. The instantiation fails if the minimum size of a table exceeds the
+// limit.
+func TableLimit(elems uint32) Option {
+	return optionFunc(func(opts *instanceOptions) {
+		opts.tableLimit = elems
+	})
+}
+
+// HostModules provides the host modules, whose fields the module imports by
+// the module names.
+func HostModules(modules ...*HostModule) Option {
+	return optionFunc(func(opts *instanceOptions) {
+		for _, hm := range modules {
+			opts.hosts[hm.name] = hm
+		}
+	})
+}
+
+// Fuel meters the execution with the fuel, which each executed instruction
+// consumes. A function traps with ErrOutOfFuel when the fuel runs out, and
+// Instance.Refuel adds the fuel between the calls.
+func Fuel(amount uint64) Option {
+	return optionFunc(func(opts *instanceOptions) {
+		opts.metered = true
+		opts.fuel = amount
+	})
+}
+
+// FuelCosts changes the fuel the instructions consume, which is 1 for the
+// instructions not in costs. It has no effect without Fuel.
+func FuelCosts(costs map[instruction.InstructionName]uint64) Option {
+	return optionFunc(func(opts *instanceOptions) {
+		opts.fuelCosts = make(map[instruction.InstructionName]uint64, len(costs))
+		for name, cost := range costs {
+			opts.fuelCosts[name] = cost
+		}
+	})
+}
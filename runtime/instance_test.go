@@ -0,0 +1,1212 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kechako/wasmexec/mod"
+	"github.com/kechako/wasmexec/mod/instruction"
+	"github.com/kechako/wasmexec/mod/text"
+	"github.com/kechako/wasmexec/mod/types"
+	"github.com/kechako/wasmexec/mod/validate"
+)
+
+type valueTypes interface {
+	int32 | int64 | float32 | float64
+}
+
+func newResults(values ...any) []any {
+	return values
+}
+
+func newTypedResults[T valueTypes](values ...T) []any {
+	results := make([]any, len(values))
+
+	for i, v := range values {
+		results[i] = v
+	}
+
+	return results
+}
+
+var execFuncTests = map[string]struct {
+	results []any
+	// whether the program runs only once in an instance, because it drops
+	// the segments
+	once bool
+}{
+	"test01.wat": {
+		results: newTypedResults[int32](25, 3, 28, 2),
+	},
+	"test02.wat": {
+		results: newTypedResults[int32](1, 0, 1, 0, 0, 1, 1, 0, 0, 0, 0, 1, 1, 1, 0, 0, 1, 1),
+	},
+	"test03.wat": {
+		results: newTypedResults[int32](4),
+	},
+	"test04.wat": {
+		results: newTypedResults[int32](40, 30, 20, 10, 50, 60, 70, 80, 80, 70, 60, 50),
+	},
+	"test05.wat": {
+		results: newTypedResults[int32](300),
+	},
+	"test06.wat": {
+		results: newTypedResults[int32](180),
+	},
+	"test07.wat": {
+		results: newTypedResults[int32](180),
+	},
+	"test08.wat": {
+		results: newTypedResults[int32](10),
+	},
+	"test09.wat": {
+		results: newResults(
+			int64(0), int64(63), int64(63), int64(8),
+			int64(math.MinInt64), int64(-1), int64(0), int64(-3), int64(math.MaxInt64), int64(-1), int64(5),
+			int64(48), int64(252), int64(204), int64(2), int64(-4), int64(15), int64(3), int64(math.MinInt64), int64(0),
+			int32(1), int32(1), int32(0), int32(1), int32(0), int32(0), int32(1), int32(1), int32(0), int32(0), int32(1),
+			int64(80),
+		),
+	},
+	"test10.wat": {
+		results: newResults(
+			float32(3.75), float32(16777216), negZero32, float32(0), int32(1),
+			math.Float32frombits(0x7f800001), math.Float32frombits(0xffc00000), float32(-1.5),
+			float32(-2), float32(4), negZero32,
+			float32(-1), float32(-2), negZero32, float32(math.Sqrt2),
+			float32(math.Inf(1)), float32(math.Inf(-1)),
+			int32(0), int32(1), int32(1), int32(1), int32(0), int32(1), int32(0),
+			float64(16777217), float64(0.30000000000000004), negZero64, float64(0),
+			math.Float64frombits(0x7ff0000000000001), float64(-2), float64(0), float64(-2), float64(4),
+			negZero64, float64(1e300), float64(0), int32(1),
+			int32(0), int32(1),
+		),
+	},
+	"test11.wat": {
+		results: newResults(
+			int32(1), int32(-3), int32(-1), int64(-1), int64(4294967295),
+			int64(math.MinInt64), int64(-8446744093203103744),
+			int32(0), int32(math.MinInt32), int32(-1), int64(0), int64(math.MaxInt64),
+			float32(4294967296), float64(9223372036854775808), float64(18446744073709551616), float32(16777216),
+			float32(0.1), float64(float32(0.1)),
+			int32(math.MinInt32), math.Float64frombits(0x7ff8000000000001), int64(0x3ff0000000000000), math.Float32frombits(0x7fc00000),
+			int32(-128), int32(32767), int64(-1), int64(-32768), int64(math.MinInt32),
+		),
+	},
+	"test12.wat": {
+		results: newTypedResults[int32](
+			0, 31, 31, 8,
+			2147483644, -1, 1, 0,
+			48, 252, 204, 2, -4, 15, 3, math.MinInt32,
+			0, 1, 1, 0,
+		),
+	},
+	"test13.wat": {
+		results: newTypedResults[int32](
+			1, 7, 10, 20,
+			100, 101, 102, 103, 103, 103,
+			42, 5, 8,
+		),
+	},
+	"test14.wat": {
+		results: newTypedResults[int32](
+			120, 55, -1, 1, 9, 6, 6, 8, 11,
+		),
+	},
+	"test15.wat": {
+		results: newResults(
+			int32(0x78), int32(0x1234), int32(-1), int64(0xfffffffe), int64(-2),
+			float64(1.5), float32(-0.25), int32(1), int32(1), int32(3),
+			int32(-1), int32(7), int64(0x3456),
+		),
+	},
+	"test16.wat": {
+		results: newTypedResults[int32](0x04030201, 'e', 0x6c6c, 0xa9c3, 0),
+		once:    true,
+	},
+	"test17.wat": {
+		results: newResults(int32(17), int32(18), int32(42), float64(3)),
+	},
+	"test18.wat": {
+		results: newTypedResults[int32](10, 25, -5, 1, -7, 4, 15),
+		once:    true,
+	},
+	"test19.wat": {
+		results: newTypedResults[int32](10, 4),
+	},
+	"test20.wat": {
+		results: newTypedResults[int32](53, 3, 11),
+	},
+}
+
+var (
+	negZero32 = float32(math.Copysign(0, -1))
+	negZero64 = math.Copysign(0, -1)
+)
+
+// equateFloatBits compares floats by their bits, which distinguishes signed
+// zeros and NaN payloads.
+var equateFloatBits = cmp.Options{
+	cmp.Comparer(func(x, y float32) bool {
+		return math.Float32bits(x) == math.Float32bits(y)
+	}),
+	cmp.Comparer(func(x, y float64) bool {
+		return math.Float64bits(x) == math.Float64bits(y)
+	}),
+}
+
+func Test_Instance_ExecFunc(t *testing.T) {
+	ctx := context.Background()
+	for name, tt := range execFuncTests {
+		name := name
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			inst, err := createInstance(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := inst.ExecFunc(ctx, "main")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(results, tt.results, equateFloatBits); diff != "" {
+				t.Errorf("Instance.ExecFunc(ctx, \"main\"), differs: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func Benchmark_Instance_ExecFunc(b *testing.B) {
+	names := make([]string, 0, len(execFuncTests))
+	for name, tt := range execFuncTests {
+		// the programs running only once are in Benchmark_Instance_ExecFunc_Once
+		if !tt.once {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	ctx := context.Background()
+	for _, name := range names {
+		b.Run(name, func(b *testing.B) {
+			inst, err := createInstance(name)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := inst.ExecFunc(ctx, "main"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Benchmark_Instance_ExecFunc_Once measures the programs running only once in
+// an instance together with the instantiation.
+func Benchmark_Instance_ExecFunc_Once(b *testing.B) {
+	names := make([]string, 0, len(execFuncTests))
+	for name, tt := range execFuncTests {
+		if tt.once {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	ctx := context.Background()
+	for _, name := range names {
+		b.Run(name, func(b *testing.B) {
+			compiled, err := compileFile(name)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				inst, err := compiled.Instantiate()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := inst.ExecFunc(ctx, "main"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// benchmarks are the workloads of bench.wat, which exercise the calls, the
+// branches, the memory and the indirect calls respectively.
+var benchmarks = []struct {
+	name string
+	arg  int32
+	want any
+}{
+	{name: "fib", arg: 20, want: int32(6765)},
+	{name: "loop", arg: 10000, want: int64(49995000)},
+	{name: "memory", arg: 10000, want: int32(49995000)},
+	{name: "dispatch", arg: 10000, want: int32(-910008452)},
+}
+
+func Benchmark_Instance_Workloads(b *testing.B) {
+	inst, err := createInstance("bench.wat")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				results, err := inst.ExecFunc(ctx, bm.name, bm.arg)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if results[0] != bm.want {
+					b.Fatalf("Instance.ExecFunc(ctx, %q, %d): want: %v, got: %v", bm.name, bm.arg, bm.want, results[0])
+				}
+			}
+		})
+	}
+}
+
+func Test_Instance_ExecFunc_Allocs(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (global $g (mut i64) (i64.const 0))
+  (func $fib (param $n i32) (result i32)
+	(if (result i32) (i32.lt_s (local.get $n) (i32.const 2))
+	  (then (local.get $n))
+	  (else
+		(i32.add
+		  (call $fib (i32.sub (local.get $n) (i32.const 1)))
+		  (call $fib (i32.sub (local.get $n) (i32.const 2)))))))
+  (func $loop (param $n i32)
+	(local $i i32)
+	(block $done
+	  (loop $l
+		(br_if $done (i32.ge_s (local.get $i) (local.get $n)))
+		(global.set $g (i64.add (global.get $g) (i64.extend_i32_s (local.get $i))))
+		(local.set $i (i32.add (local.get $i) (i32.const 1)))
+		br $l)))
+  (export "fib" (func $fib))
+  (export "loop" (func $loop)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Validate(m); err != nil {
+		t.Fatal(err)
+	}
+
+	inst, err := New(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the allocations of the arguments and the results do not depend on
+	// the amount of the execution
+	ctx := context.Background()
+	tests := map[string]struct {
+		small, large any
+	}{
+		"fib":  {small: int32(14), large: int32(20)},
+		"loop": {small: int32(1), large: int32(1000)},
+	}
+	for name, tt := range tests {
+		allocs := func(arg any) float64 {
+			return testing.AllocsPerRun(10, func() {
+				if _, err := inst.ExecFunc(ctx, name, arg); err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+		small, large := allocs(tt.small), allocs(tt.large)
+		if small != large {
+			t.Errorf("%s: allocations: want: %v, got: %v", name, small, large)
+		}
+	}
+}
+
+var execFuncTrapTests = map[string]struct {
+	err error
+}{
+	"trap01.wat": {
+		err: errIntegerOverflow,
+	},
+	"trap02.wat": {
+		err: errIntegerDivideByZero,
+	},
+	"trap03.wat": {
+		err: errInvalidConversion,
+	},
+	"trap04.wat": {
+		err: errIntegerOverflow,
+	},
+	"trap05.wat": {
+		err: errIntegerOverflow,
+	},
+	"trap06.wat": {
+		err: errOutOfBoundsMemoryAccess,
+	},
+	"trap07.wat": {
+		err: errOutOfBoundsMemoryAccess,
+	},
+	"trap08.wat": {
+		err: errIndirectCallTypeMismatch,
+	},
+	"trap09.wat": {
+		err: errUninitializedElement,
+	},
+	"trap10.wat": {
+		err: errUndefinedElement,
+	},
+	"trap11.wat": {
+		err: errOutOfBoundsTableAccess,
+	},
+}
+
+func Test_Instance_ExecFunc_Trap(t *testing.T) {
+	ctx := context.Background()
+	for name, tt := range execFuncTrapTests {
+		name := name
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			inst, err := createInstance(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = inst.ExecFunc(ctx, "main")
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Instance.ExecFunc(ctx, \"main\"): err: want: %v, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func Test_Instance_ExecFunc_Args(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (func $add (param $a i32) (param $b i64) (result i64)
+	(i64.add (i64.extend_i32_s (local.get $a)) (local.get $b)))
+  (export "add" (func $add)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inst, err := New(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	results, err := inst.ExecFunc(ctx, "add", int32(-1), int64(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(results, newTypedResults[int64](9)); diff != "" {
+		t.Errorf("Instance.ExecFunc(ctx, \"add\", -1, 10), differs: (-got +want)\n%s", diff)
+	}
+
+	if _, err := inst.ExecFunc(ctx, "add", int32(1)); !errors.Is(err, errArgumentCountMismatch) {
+		t.Errorf("Instance.ExecFunc(ctx, \"add\", 1): err: want: %v, got: %v", errArgumentCountMismatch, err)
+	}
+	if _, err := inst.ExecFunc(ctx, "add", int32(1), int32(2)); !errors.Is(err, errArgumentTypeMismatch) {
+		t.Errorf("Instance.ExecFunc(ctx, \"add\", 1, int32(2)): err: want: %v, got: %v", errArgumentTypeMismatch, err)
+	}
+
+	typ, err := inst.FuncType("add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &mod.FuncType{
+		Parameters: []types.Type{types.I32, types.I64},
+		Results:    []types.Type{types.I64},
+	}
+	if diff := cmp.Diff(typ, want); diff != "" {
+		t.Errorf("Instance.FuncType(\"add\"), differs: (-got +want)\n%s", diff)
+	}
+}
+
+func Test_Instance_ExecFunc_Context(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (func $loop
+	(loop $l
+	  br $l))
+  (func $call
+	call $call2)
+  (func $call2
+	call $call)
+  (export "loop" (func $loop))
+  (export "call" (func $call)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inst, err := New(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := inst.ExecFunc(ctx, "loop"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Instance.ExecFunc(ctx, \"loop\"): err: want: %v, got: %v", context.DeadlineExceeded, err)
+	}
+
+	inst, err = New(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := inst.ExecFunc(ctx, "call"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Instance.ExecFunc(ctx, \"call\"): err: want: %v, got: %v", context.Canceled, err)
+	}
+}
+
+func Test_Instance_Fuel(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (func $count (param $n i32) (result i32)
+	(local $i i32)
+	(block $done
+	  (loop $l
+		(br_if $done (i32.ge_s (local.get $i) (local.get $n)))
+		(local.set $i (i32.add (local.get $i) (i32.const 1)))
+		br $l))
+	local.get $i)
+  (export "count" (func $count)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// block, loop, 9 instructions * 10 iterations, 4 to exit, and local.get
+	inst, err := New(m, Fuel(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	results, err := inst.ExecFunc(ctx, "count", int32(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(results, newTypedResults[int32](10)); diff != "" {
+		t.Errorf("Instance.ExecFunc(ctx, \"count\", 10), differs: (-got +want)\n%s", diff)
+	}
+	fuel, ok := inst.Fuel()
+	if !ok || fuel != 3 {
+		t.Errorf("Instance.Fuel(): want: 3, true, got: %d, %v", fuel, ok)
+	}
+
+	if _, err := inst.ExecFunc(ctx, "count", int32(10)); !errors.Is(err, ErrOutOfFuel) {
+		t.Errorf("Instance.ExecFunc(ctx, \"count\", 10): err: want: %v, got: %v", ErrOutOfFuel, err)
+	}
+
+	inst, err = New(m, Fuel(10), FuelCosts(map[instruction.InstructionName]uint64{
+		instruction.Br: 0,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst.Refuel(77)
+	if _, err := inst.ExecFunc(ctx, "count", int32(10)); err != nil {
+		t.Fatal(err)
+	}
+	if fuel, _ := inst.Fuel(); fuel != 0 {
+		t.Errorf("Instance.Fuel(): want: 0, got: %d", fuel)
+	}
+
+	inst, err = New(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := inst.Fuel(); ok {
+		t.Error("Instance.Fuel(): want: false, got: true")
+	}
+}
+
+func Test_Instance_ExecFunc_Backtrace(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (func $div (param i32) (result i32)
+	nop
+	(block $b (result i32)
+	  (i32.div_u (i32.const 1) (local.get 0))))
+  (func $main (result i32)
+	i32.const 0
+	call $div)
+  (func $unreachable
+	(block (unreachable)))
+  (export "main" (func $main))
+  (export "unreachable" (func $unreachable)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Validate(m); err != nil {
+		t.Fatal(err)
+	}
+
+	inst, err := New(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	_, err = inst.ExecFunc(ctx, "main")
+	var trap *Trap
+	if !errors.As(err, &trap) {
+		t.Fatalf("Instance.ExecFunc(ctx, \"main\"): err: want: *Trap, got: %v", err)
+	}
+	if trap.Kind != TrapIntegerDivideByZero {
+		t.Errorf("Trap.Kind: want: %v, got: %v", TrapIntegerDivideByZero, trap.Kind)
+	}
+	want := []Frame{
+		{Function: 0, ID: "$div", Block: 0, Label: "$b", Instruction: 2},
+		{Function: 1, ID: "$main", Block: -1, Instruction: 1},
+	}
+	if diff := cmp.Diff(trap.Backtrace, want); diff != "" {
+		t.Errorf("Trap.Backtrace, differs: (-got +want)\n%s", diff)
+	}
+	if msg := "integer divide by zero at func 0 $div (block $b, instruction 2)"; trap.Error() != msg {
+		t.Errorf("Trap.Error(): want: %q, got: %q", msg, trap.Error())
+	}
+
+	_, err = inst.ExecFunc(ctx, "unreachable")
+	if !errors.As(err, &trap) || trap.Kind != TrapUnreachable {
+		t.Errorf("Instance.ExecFunc(ctx, \"unreachable\"): err: want: %v, got: %v", TrapUnreachable, err)
+	}
+	if diff := cmp.Diff(trap.Backtrace, []Frame{{Function: 2, ID: "$unreachable", Block: 0}}); diff != "" {
+		t.Errorf("Trap.Backtrace, differs: (-got +want)\n%s", diff)
+	}
+	if msg := "unreachable at func 2 $unreachable (block 0, instruction 0)"; trap.Error() != msg {
+		t.Errorf("Trap.Error(): want: %q, got: %q", msg, trap.Error())
+	}
+}
+
+func Test_Instance_MaxCallDepth(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (func $depth (param $n i32) (result i32)
+	(if (result i32) (i32.eqz (local.get $n))
+	  (then (i32.const 1))
+	  (else (i32.add (call $depth (i32.sub (local.get $n) (i32.const 1))) (i32.const 1)))))
+  (func $recurse
+	call $recurse)
+  (export "depth" (func $depth))
+  (export "recurse" (func $recurse)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		opts []Option
+	}{
+		"default":        {},
+		"max call depth": {opts: []Option{MaxCallDepth(10)}},
+		"stack capacity": {opts: []Option{StackCapacity(16)}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			inst, err := New(m, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			_, err = inst.ExecFunc(ctx, "recurse")
+			var trap *Trap
+			if !errors.As(err, &trap) || trap.Kind != TrapStackExhausted {
+				t.Fatalf("Instance.ExecFunc(ctx, \"recurse\"): err: want: %v, got: %v", TrapStackExhausted, err)
+			}
+
+			// the instance is still usable after the trap
+			results, err := inst.ExecFunc(ctx, "depth", int32(3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(results, newTypedResults[int32](4)); diff != "" {
+				t.Errorf("Instance.ExecFunc(ctx, \"depth\", 3), differs: (-got +want)\n%s", diff)
+			}
+		})
+	}
+
+	inst, err := New(m, MaxCallDepth(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	results, err := inst.ExecFunc(ctx, "depth", int32(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(results, newTypedResults[int32](10)); diff != "" {
+		t.Errorf("Instance.ExecFunc(ctx, \"depth\", 9), differs: (-got +want)\n%s", diff)
+	}
+	if _, err := inst.ExecFunc(ctx, "depth", int32(10)); !errors.Is(err, errCallStackExhausted) {
+		t.Errorf("Instance.ExecFunc(ctx, \"depth\", 10): err: want: %v, got: %v", errCallStackExhausted, err)
+	}
+}
+
+func Test_Instance_ExecFunc_NoPanic(t *testing.T) {
+	// the function is not validated, so that it pops the empty stack
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (import "env" "panic" (func $panic))
+  (func $drop
+	drop)
+  (export "drop" (func $drop))
+  (export "panic" (func $panic)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host := NewHostModule("env").Func("panic", func() { panic("host") })
+	inst, err := New(m, HostModules(host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := inst.ExecFunc(ctx, "drop"); !errors.Is(err, errStackInconsistent) {
+		t.Errorf("Instance.ExecFunc(ctx, \"drop\"): err: want: %v, got: %v", errStackInconsistent, err)
+	}
+	if _, err := inst.ExecFunc(ctx, "panic"); !errors.Is(err, errPanic) {
+		t.Errorf("Instance.ExecFunc(ctx, \"panic\"): err: want: %v, got: %v", errPanic, err)
+	}
+}
+
+func Test_Instance_MemoryLimit(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (memory 1 4)
+  (func $main (result i32) (result i32) (result i32)
+	(memory.grow (i32.const 2))
+	(memory.grow (i32.const 1))
+	memory.size)
+  (export "main" (func $main)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inst, err := New(m, MemoryLimit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := inst.ExecFunc(context.Background(), "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := newTypedResults[int32](-1, 1, 2)
+	if diff := cmp.Diff(results, want); diff != "" {
+		t.Errorf("Instance.ExecFunc(ctx, \"main\"), differs: (-got +want)\n%s", diff)
+	}
+	if size := len(inst.Memory().Bytes()); size != 2*mod.PageSize {
+		t.Errorf("len(Instance.Memory().Bytes()): want: %d, got: %d", 2*mod.PageSize, size)
+	}
+
+	// the minimum size is also limited
+	if _, err := New(m, MemoryLimit(0)); !errors.Is(err, errMemoryLimitExceeded) {
+		t.Errorf("New(m, MemoryLimit(0)): err: want: %v, got: %v", errMemoryLimitExceeded, err)
+	}
+}
+
+func Test_Instance_ExecFunc_GrowHuge(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (memory 1)
+  (table 1 funcref)
+  (func $main (result i32) (result i32) (result i32) (result i32)
+	(memory.grow (i32.const 0x10000))
+	(table.grow (ref.null func) (i32.const 0x40000000))
+	memory.size
+	table.size)
+  (export "main" (func $main)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the default limits fail the growth before allocating
+	inst, err := New(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := inst.ExecFunc(context.Background(), "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(results, newTypedResults[int32](-1, -1, 1, 1)); diff != "" {
+		t.Errorf("Instance.ExecFunc(ctx, \"main\"), differs: (-got +want)\n%s", diff)
+	}
+}
+
+func Test_Instance_Global(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (global $g (mut i64) (i64.const 1))
+  (global $c f32 (f32.const 0.5))
+  (func $main (result i64)
+	(global.set $g (i64.mul (global.get $g) (i64.const 10)))
+	global.get $g)
+  (export "main" (func $main))
+  (export "g" (global $g))
+  (export "c" (global $c)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inst, err := New(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := inst.Global("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Set(int64(3)); err != nil {
+		t.Fatalf("Global.Set(3): %v", err)
+	}
+	if err := g.Set(int32(3)); !errors.Is(err, errGlobalTypeMismatch) {
+		t.Errorf("Global.Set(int32(3)): err: want: %v, got: %v", errGlobalTypeMismatch, err)
+	}
+
+	results, err := inst.ExecFunc(context.Background(), "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(results, newTypedResults[int64](30)); diff != "" {
+		t.Errorf("Instance.ExecFunc(ctx, \"main\"), differs: (-got +want)\n%s", diff)
+	}
+	if v := g.Get(); v != int64(30) {
+		t.Errorf("Global.Get(): want: 30, got: %v", v)
+	}
+
+	c, err := inst.Global("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.Get(); v != float32(0.5) {
+		t.Errorf("Global.Get(): want: 0.5, got: %v", v)
+	}
+	if err := c.Set(float32(1)); !errors.Is(err, errImmutableGlobal) {
+		t.Errorf("Global.Set(1): err: want: %v, got: %v", errImmutableGlobal, err)
+	}
+
+	if _, err := inst.Global("main"); !errors.Is(err, errExportTargetNotGlobal) {
+		t.Errorf("Instance.Global(\"main\"): err: want: %v, got: %v", errExportTargetNotGlobal, err)
+	}
+}
+
+func Test_New_DataOutOfBounds(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (memory 1)
+  (data (i32.const 0) "ok")
+  (data (i32.const 65535) "out"))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = New(m)
+	var trap *Trap
+	if !errors.As(err, &trap) || trap.Kind != TrapOutOfBoundsMemoryAccess {
+		t.Errorf("New(m): err: want: %v, got: %v", TrapOutOfBoundsMemoryAccess, err)
+	}
+	if !errors.Is(err, errOutOfBoundsMemoryAccess) {
+		t.Errorf("New(m): err: want: %v, got: %v", errOutOfBoundsMemoryAccess, err)
+	}
+}
+
+func Test_New_CompileError(t *testing.T) {
+	tests := map[string]struct {
+		src string
+		err error
+	}{
+		"local": {
+			src: `(module (func local.get 1))`,
+			err: errLocalVariableInconsistent,
+		},
+		"label": {
+			src: `(module (func (block br 2)))`,
+			err: errLabelNotFound,
+		},
+		"global": {
+			src: `(module (func global.get 0 drop))`,
+			err: errGlobalNotFound,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// the functions are not validated, so that the compilation fails
+			m, err := text.NewDecoder(strings.NewReader(tt.src)).Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = New(m)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("New(m): err: want: %v, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func Test_Instance_Table(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (table $t 1 4 funcref)
+  (func $f (result i32)
+	i32.const 42)
+  (func $main (result i32) (result i32) (result i32)
+	(table.grow $t (ref.null func) (i32.const 2))
+	(table.grow $t (ref.func $f) (i32.const 1))
+	(call_indirect $t (result i32) (i32.const 1)))
+  (elem declare func $f)
+  (export "main" (func $main))
+  (export "t" (table $t)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inst, err := New(m, TableLimit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := inst.ExecFunc(context.Background(), "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(results, newTypedResults[int32](-1, 1, 42)); diff != "" {
+		t.Errorf("Instance.ExecFunc(ctx, \"main\"), differs: (-got +want)\n%s", diff)
+	}
+
+	table, err := inst.Table("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := table.Size(); size != 2 {
+		t.Errorf("Table.Size(): want: 2, got: %d", size)
+	}
+	if ref, err := table.Get(0); err != nil || !ref.IsNull() {
+		t.Errorf("Table.Get(0): want: null, got: %v, %v", ref, err)
+	}
+	if _, err := table.Get(2); !errors.Is(err, errOutOfBoundsTableAccess) {
+		t.Errorf("Table.Get(2): err: want: %v, got: %v", errOutOfBoundsTableAccess, err)
+	}
+
+	// the minimum size is also limited
+	if _, err := New(m, TableLimit(0)); !errors.Is(err, errTableLimitExceeded) {
+		t.Errorf("New(m, TableLimit(0)): err: want: %v, got: %v", errTableLimitExceeded, err)
+	}
+}
+
+func Test_New_ElementOutOfBounds(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (table $t 2 funcref)
+  (func $f)
+  (elem (table $t) (i32.const 0) func $f)
+  (elem (table $t) (i32.const 1) func $f $f))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inst, err := New(m)
+	var trap *Trap
+	if !errors.As(err, &trap) || trap.Kind != TrapOutOfBoundsTableAccess {
+		t.Errorf("New(m): err: want: %v, got: %v", TrapOutOfBoundsTableAccess, err)
+	}
+	if !errors.Is(err, errOutOfBoundsTableAccess) {
+		t.Errorf("New(m): err: want: %v, got: %v", errOutOfBoundsTableAccess, err)
+	}
+	if inst != nil {
+		t.Errorf("New(m): want: nil, got: %v", inst)
+	}
+}
+
+func Test_New_Start(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (memory 1)
+  (global $g (mut i32) (i32.const 0))
+  (func $init
+	(global.set $g (i32.load8_u (i32.const 0))))
+  (func $main (result i32)
+	global.get $g)
+  (start $init)
+  (data (i32.const 0) "\2a")
+  (export "main" (func $main)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inst, err := New(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := inst.ExecFunc(context.Background(), "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(results, newTypedResults[int32](42)); diff != "" {
+		t.Errorf("Instance.ExecFunc(ctx, \"main\"), differs: (-got +want)\n%s", diff)
+	}
+}
+
+func Test_New_StartTrap(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (func $init
+	(drop (i32.div_s (i32.const 1) (i32.const 0))))
+  (start $init))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inst, err := New(m)
+	if !errors.Is(err, errIntegerDivideByZero) {
+		t.Errorf("New(m): err: want: %v, got: %v", errIntegerDivideByZero, err)
+	}
+	if inst != nil {
+		t.Errorf("New(m): want: nil, got: %v", inst)
+	}
+}
+
+func Test_Instance_HostModule(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (import "env" "add" (func $add (param i32) (param i32) (result i32)))
+  (import "env" "store" (func $store (param i32) (param i64)))
+  (import "env" "mem" (memory 1))
+  (import "env" "base" (global $base i32))
+  (import "env" "counter" (global $counter (mut i64)))
+  (import "env" "t" (table 1 funcref))
+  (func $main (result i32) (result i64) (result i32)
+	(call $store (i32.const 8) (i64.const 42))
+	(global.set $counter (i64.add (global.get $counter) (i64.const 1)))
+	(call $add (global.get $base) (i32.const 2))
+	(i64.load (i32.const 8))
+	(call_indirect (param i32) (param i32) (result i32) (i32.const 3) (i32.const 4) (i32.const 0)))
+  (elem (i32.const 0) func $add)
+  (export "main" (func $main)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Validate(m); err != nil {
+		t.Fatal(err)
+	}
+
+	type key struct{}
+	mem := NewMemory(mod.Limits{Min: 1})
+	base, err := NewGlobal(int32(10), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter, err := NewGlobal(int64(0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := NewHostModule("env").
+		Func("add", func(ctx context.Context, a, b int32) int32 {
+			if ctx.Value(key{}) == nil {
+				t.Error("add: the context is not passed")
+			}
+			return a + b
+		}).
+		Func("store", func(c *Caller, addr int32, v int64) {
+			c.Memory().Bytes()[addr] = byte(v)
+		}).
+		Memory("mem", mem).
+		Global("base", base).
+		Global("counter", counter).
+		Table("t", NewTable(mod.Limits{Min: 1}))
+
+	inst, err := New(m, HostModules(host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), key{}, true)
+	results, err := inst.ExecFunc(ctx, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(results, newResults(int32(12), int64(42), int32(7))); diff != "" {
+		t.Errorf("Instance.ExecFunc(ctx, \"main\"), differs: (-got +want)\n%s", diff)
+	}
+	if inst.Memory() != mem {
+		t.Error("Instance.Memory(): the imported memory is not shared")
+	}
+	if v := counter.Get(); v != int64(1) {
+		t.Errorf("Global.Get(): want: 1, got: %v", v)
+	}
+}
+
+func Test_Instance_HostModule_SharedTable(t *testing.T) {
+	a, err := text.NewDecoder(strings.NewReader(`(module
+  (import "env" "load" (func $load (result i32)))
+  (memory 1)
+  (global $g i32 (i32.const 111))
+  (table $t 3 funcref)
+  (func $get (result i32)
+	global.get $g)
+  (func $fail
+	unreachable)
+  (elem (i32.const 0) func $get $load $fail)
+  (data (i32.const 0) "\07")
+  (export "t" (table $t)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := text.NewDecoder(strings.NewReader(`(module
+  (import "a" "t" (table $t 3 funcref))
+  (memory 1)
+  (global $g i32 (i32.const 222))
+  (data (i32.const 0) "\09")
+  (func $main (result i32) (result i32)
+	(call_indirect $t (result i32) (i32.const 0))
+	(call_indirect $t (result i32) (i32.const 1)))
+  (func $fail
+	(call_indirect $t (i32.const 2)))
+  (export "main" (func $main))
+  (export "fail" (func $fail)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := NewHostModule("env").
+		Func("load", func(c *Caller) int32 {
+			return int32(c.Memory().Bytes()[0])
+		})
+	instA, err := New(a, HostModules(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := instA.Table("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	instB, err := New(b, HostModules(NewHostModule("a").Table("t", table)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the functions in the table run with the state of a on the stack of b,
+	// and the trap has the frames of both instances
+	ctx := context.Background()
+	var trap *Trap
+	if _, err := instB.ExecFunc(ctx, "fail"); !errors.As(err, &trap) || trap.Kind != TrapUnreachable {
+		t.Fatalf("Instance.ExecFunc(ctx, \"fail\"): err: want: %v, got: %v", TrapUnreachable, err)
+	}
+	want := []Frame{
+		{Function: 2, ID: "$fail", Block: -1, Instruction: 0},
+		{Function: 1, ID: "$fail", Block: -1, Instruction: 1},
+	}
+	if diff := cmp.Diff(trap.Backtrace, want); diff != "" {
+		t.Errorf("Trap.Backtrace, differs: (-got +want)\n%s", diff)
+	}
+	if n := instA.stack.Len(); n != 0 {
+		t.Errorf("Stack.Len(): want: 0, got: %d", n)
+	}
+	results, err := instB.ExecFunc(ctx, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(results, newTypedResults[int32](111, 7)); diff != "" {
+		t.Errorf("Instance.ExecFunc(ctx, \"main\"), differs: (-got +want)\n%s", diff)
+	}
+}
+
+func Test_Instance_HostModule_Error(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (import "env" "fail" (func $fail))
+  (export "main" (func $fail)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errFail := errors.New("fail")
+	host := NewHostModule("env").
+		Func("fail", func() error { return errFail })
+	inst, err := New(m, HostModules(host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.ExecFunc(context.Background(), "main"); !errors.Is(err, errFail) {
+		t.Errorf("Instance.ExecFunc(ctx, \"main\"): err: want: %v, got: %v", errFail, err)
+	}
+}
+
+var newImportTests = map[string]struct {
+	input string
+	host  *HostModule
+	err   error
+}{
+	"unknown module": {
+		input: `(module (import "js" "f" (func)))`,
+		host:  NewHostModule("env").Func("f", func() {}),
+		err:   errUnknownImport,
+	},
+	"unknown name": {
+		input: `(module (import "env" "g" (func)))`,
+		host:  NewHostModule("env").Func("f", func() {}),
+		err:   errUnknownImport,
+	},
+	"function type mismatch": {
+		input: `(module (import "env" "f" (func (param i64))))`,
+		host:  NewHostModule("env").Func("f", func(int32) {}),
+		err:   errIncompatibleImportType,
+	},
+	"memory too small": {
+		input: `(module (import "env" "mem" (memory 2)))`,
+		host:  NewHostModule("env").Memory("mem", NewMemory(mod.Limits{Min: 1})),
+		err:   errIncompatibleImportType,
+	},
+	"memory without maximum": {
+		input: `(module (import "env" "mem" (memory 1 2)))`,
+		host:  NewHostModule("env").Memory("mem", NewMemory(mod.Limits{Min: 1})),
+		err:   errIncompatibleImportType,
+	},
+	"global mutability mismatch": {
+		input: `(module (import "env" "g" (global (mut i32))))`,
+		host:  NewHostModule("env").Global("g", newConstGlobal(int32(0))),
+		err:   errIncompatibleImportType,
+	},
+	"unsupported function": {
+		input: `(module)`,
+		host:  NewHostModule("env").Func("f", func(string) {}),
+		err:   errUnsupportedType,
+	},
+}
+
+func Test_New_Import(t *testing.T) {
+	for name, tt := range newImportTests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			m, err := text.NewDecoder(strings.NewReader(tt.input)).Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = New(m, HostModules(tt.host))
+			if !errors.Is(err, tt.err) {
+				t.Errorf("New(m): err: want: %v, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func newConstGlobal(v any) *Global {
+	g, err := NewGlobal(v, false)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+func createInstance(name string) (*Instance, error) {
+	m, err := decodeFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(m)
+}
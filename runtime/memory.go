@@ -0,0 +1,272 @@
+package runtime
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/kechako/wasmexec/mod"
+)
+
+// defaultMemoryLimit is the number of pages the memory can grow to unless
+// MemoryLimit is given, which is 256 MiB. The pages are allocated when the
+// memory grows, so memory.grow must not allocate up to the maximum of 4 GiB.
+const defaultMemoryLimit = 4096
+
+// Memory is a linear memory, which is a little-endian byte array whose size
+// is a multiple of the page size.
+type Memory struct {
+	data []byte
+	// maximum number of pages the memory can grow to
+	max uint32
+}
+
+// newMemory returns a memory of the minimum size of limits, which can grow
+// up to the maximum of limits or limit pages whichever is smaller. It
+// returns an error without allocating the pages if the minimum size exceeds
+// limit.
+func newMemory(limits mod.Limits, limit uint32) (*Memory, error) {
+	if limits.Min > limit {
+		return nil, fmt.Errorf("%w: minimum %d exceeds %d pages", errMemoryLimitExceeded, limits.Min, limit)
+	}
+
+	max := limit
+	if limits.HasMax && limits.Max < max {
+		max = limits.Max
+	}
+
+	return &Memory{
+		data: make([]byte, int(limits.Min)*mod.PageSize),
+		max:  max,
+	}, nil
+}
+
+// NewMemory returns a memory of the minimum size of limits, which can grow up
+// to the maximum of limits, to be imported by modules. The memory grows at
+// most to the default limit of MemoryLimit or the minimum size whichever is
+// larger.
+func NewMemory(limits mod.Limits) *Memory {
+	limit := uint32(defaultMemoryLimit)
+	if limits.Min > limit {
+		limit = limits.Min
+	}
+	mem, _ := newMemory(limits, limit)
+
+	return mem
+}
+
+// Size returns the number of pages.
+func (mem *Memory) Size() uint32 {
+	return uint32(len(mem.data) / mod.PageSize)
+}
+
+// Grow grows the memory by delta pages, and returns the previous number of
+// pages. It reports false and leaves the memory unchanged if the size
+// exceeds the maximum.
+func (mem *Memory) Grow(delta uint32) (uint32, bool) {
+	size := mem.Size()
+	if uint64(size)+uint64(delta) > uint64(mem.max) {
+		return size, false
+	}
+
+	data := make([]byte, int(size+delta)*mod.PageSize)
+	copy(data, mem.data)
+	mem.data = data
+
+	return size, true
+}
+
+// Bytes returns the content of the memory, which is no longer the content
+// after the memory grows.
+func (mem *Memory) Bytes() []byte {
+	return mem.data
+}
+
+// slice returns the n bytes at the effective address of addr and offset.
+func (mem *Memory) slice(addr, offset, n uint32) ([]byte, error) {
+	ea := uint64(addr) + uint64(offset)
+	if ea+uint64(n) > uint64(len(mem.data)) {
+		return nil, errOutOfBoundsMemoryAccess
+	}
+
+	return mem.data[ea : ea+uint64(n)], nil
+}
+
+// initData copies the active data segments to the memory, and drops them.
+// Nothing is copied if any of the segments is out of bounds.
+func (inst *Instance) initData() error {
+	inst.data = make([][]byte, len(inst.module.mod.Data))
+
+	var dsts [][]byte
+	for i, d := range inst.module.mod.Data {
+		if d.Mode != mod.DataActive {
+			inst.data[i] = d.Init
+			continue
+		}
+
+		if inst.memory == nil {
+			return errMemoryNotFound
+		}
+		offset, err := inst.evalConstExpr(inst.module.dataOffsets[i])
+		if err != nil {
+			return err
+		}
+		b, err := inst.memory.slice(uint32(offset.(int32)), 0, uint32(len(d.Init)))
+		if err != nil {
+			// an active segment out of bounds traps at the instantiation
+			return inst.newTrap(fmt.Errorf("data %d: %w", i, err))
+		}
+		dsts = append(dsts, b)
+	}
+
+	n := 0
+	for _, d := range inst.module.mod.Data {
+		if d.Mode == mod.DataActive {
+			copy(dsts[n], d.Init)
+			n++
+		}
+	}
+
+	return nil
+}
+
+func (inst *Instance) execMemory(op *operation) error {
+	if op.code == opDataDrop {
+		inst.data[op.index] = nil
+		return nil
+	}
+
+	if inst.memory == nil {
+		return errMemoryNotFound
+	}
+
+	le := binary.LittleEndian
+
+	switch op.code {
+	case opI32Load:
+		return execLoad(inst, op, func(b []byte) int32 { return int32(le.Uint32(b)) })
+	case opI64Load:
+		return execLoad(inst, op, func(b []byte) int64 { return int64(le.Uint64(b)) })
+	case opF32Load:
+		return execLoad(inst, op, func(b []byte) float32 { return math.Float32frombits(le.Uint32(b)) })
+	case opF64Load:
+		return execLoad(inst, op, func(b []byte) float64 { return math.Float64frombits(le.Uint64(b)) })
+	case opI32Load8S:
+		return execLoad(inst, op, func(b []byte) int32 { return int32(int8(b[0])) })
+	case opI32Load8U:
+		return execLoad(inst, op, func(b []byte) int32 { return int32(b[0]) })
+	case opI32Load16S:
+		return execLoad(inst, op, func(b []byte) int32 { return int32(int16(le.Uint16(b))) })
+	case opI32Load16U:
+		return execLoad(inst, op, func(b []byte) int32 { return int32(le.Uint16(b)) })
+	case opI64Load8S:
+		return execLoad(inst, op, func(b []byte) int64 { return int64(int8(b[0])) })
+	case opI64Load8U:
+		return execLoad(inst, op, func(b []byte) int64 { return int64(b[0]) })
+	case opI64Load16S:
+		return execLoad(inst, op, func(b []byte) int64 { return int64(int16(le.Uint16(b))) })
+	case opI64Load16U:
+		return execLoad(inst, op, func(b []byte) int64 { return int64(le.Uint16(b)) })
+	case opI64Load32S:
+		return execLoad(inst, op, func(b []byte) int64 { return int64(int32(le.Uint32(b))) })
+	case opI64Load32U:
+		return execLoad(inst, op, func(b []byte) int64 { return int64(le.Uint32(b)) })
+	case opI32Store:
+		return execStore(inst, op, func(b []byte, v int32) { le.PutUint32(b, uint32(v)) })
+	case opI64Store:
+		return execStore(inst, op, func(b []byte, v int64) { le.PutUint64(b, uint64(v)) })
+	case opF32Store:
+		return execStore(inst, op, func(b []byte, v float32) { le.PutUint32(b, math.Float32bits(v)) })
+	case opF64Store:
+		return execStore(inst, op, func(b []byte, v float64) { le.PutUint64(b, math.Float64bits(v)) })
+	case opI32Store8:
+		return execStore(inst, op, func(b []byte, v int32) { b[0] = byte(v) })
+	case opI32Store16:
+		return execStore(inst, op, func(b []byte, v int32) { le.PutUint16(b, uint16(v)) })
+	case opI64Store8:
+		return execStore(inst, op, func(b []byte, v int64) { b[0] = byte(v) })
+	case opI64Store16:
+		return execStore(inst, op, func(b []byte, v int64) { le.PutUint16(b, uint16(v)) })
+	case opI64Store32:
+		return execStore(inst, op, func(b []byte, v int64) { le.PutUint32(b, uint32(v)) })
+	case opMemorySize:
+		return push(inst, int32(inst.memory.Size()))
+	case opMemoryGrow:
+		delta, err := pop[int32](inst)
+		if err != nil {
+			return err
+		}
+		size, ok := inst.memory.Grow(uint32(delta))
+		if !ok {
+			return push(inst, int32(-1))
+		}
+		return push(inst, int32(size))
+	case opMemoryInit:
+		return inst.execMemoryInit(op)
+	}
+
+	return errUnsupportedInstruction
+}
+
+// execMemoryInit pops a size, a source offset in the data segment and a
+// destination address, and copies the bytes to the memory.
+func (inst *Instance) execMemoryInit(op *operation) error {
+	n, err := pop[int32](inst)
+	if err != nil {
+		return err
+	}
+	src, err := pop[int32](inst)
+	if err != nil {
+		return err
+	}
+	dst, err := pop[int32](inst)
+	if err != nil {
+		return err
+	}
+
+	data := inst.data[op.index]
+	if uint64(uint32(src))+uint64(uint32(n)) > uint64(len(data)) {
+		return errOutOfBoundsMemoryAccess
+	}
+	b, err := inst.memory.slice(uint32(dst), 0, uint32(n))
+	if err != nil {
+		return err
+	}
+	copy(b, data[uint32(src):])
+
+	return nil
+}
+
+// execLoad pops an address, and pushes the value loaded from the memory.
+func execLoad[T number](inst *Instance, op *operation, f func(b []byte) T) error {
+	addr, err := pop[int32](inst)
+	if err != nil {
+		return err
+	}
+
+	b, err := inst.memory.slice(uint32(addr), op.offset, op.size)
+	if err != nil {
+		return err
+	}
+	return push(inst, f(b))
+}
+
+// execStore pops a value and an address, and stores the value to the memory.
+func execStore[T number](inst *Instance, op *operation, f func(b []byte, v T)) error {
+	v, err := pop[T](inst)
+	if err != nil {
+		return err
+	}
+	addr, err := pop[int32](inst)
+	if err != nil {
+		return err
+	}
+
+	b, err := inst.memory.slice(uint32(addr), op.offset, op.size)
+	if err != nil {
+		return err
+	}
+	f(b, v)
+
+	return nil
+}
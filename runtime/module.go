@@ -0,0 +1,163 @@
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/kechako/wasmexec/mod"
+	"github.com/kechako/wasmexec/mod/instruction"
+	"github.com/kechako/wasmexec/mod/types"
+)
+
+// Module is a compiled module, which is immutable and can be instantiated
+// many times, even concurrently. The instances share the compiled code, but
+// each of them has its own stack, memory, globals and tables, and must not be
+// used concurrently.
+//
+//	compiled, err := runtime.Compile(m)
+//	inst1, err := compiled.Instantiate()
+//	inst2, err := compiled.Instantiate(runtime.Fuel(1000))
+type Module struct {
+	mod *mod.Module
+	// functions by their indices
+	funcs   []*function
+	exports map[string]*mod.Export
+
+	// constant expressions of the initial values of the globals and the
+	// references of the elem segments, and the offsets of the active
+	// segments, which are nil for the imported globals and the other
+	// segments
+	globalInits []*function
+	elemInits   [][]*function
+	elemOffsets []*function
+	dataOffsets []*function
+}
+
+// Compile compiles the functions of the module, and returns an error if it
+// fails to resolve the indices or the labels in the functions.
+func Compile(m *mod.Module) (*Module, error) {
+	compiled := &Module{
+		mod:     m,
+		funcs:   make([]*function, len(m.Functions)),
+		exports: make(map[string]*mod.Export, len(m.Exports)),
+	}
+
+	// all the functions are made first so that calls can refer to the
+	// functions after them
+	for i, f := range m.Functions {
+		compiled.funcs[i] = newFunction(f, i)
+	}
+	for i, fn := range compiled.funcs {
+		if fn.f.Import != nil {
+			continue
+		}
+		if err := compiled.compile(fn); err != nil {
+			return nil, fmt.Errorf("func %d: %w", i, err)
+		}
+	}
+
+	if err := compiled.compileConstExprs(); err != nil {
+		return nil, err
+	}
+
+	for _, e := range m.Exports {
+		compiled.exports[e.Name] = e
+	}
+
+	return compiled, nil
+}
+
+// compileConstExprs compiles the constant expressions of the globals and the
+// segments, which the instances evaluate at instantiation.
+func (m *Module) compileConstExprs() error {
+	m.globalInits = make([]*function, len(m.mod.Globals))
+	for i, g := range m.mod.Globals {
+		if g.Import != nil {
+			continue
+		}
+		fn, err := m.compileConstExpr(g.Init, g.Type)
+		if err != nil {
+			return fmt.Errorf("global %d: %w", i, err)
+		}
+		m.globalInits[i] = fn
+	}
+
+	m.elemInits = make([][]*function, len(m.mod.Elements))
+	m.elemOffsets = make([]*function, len(m.mod.Elements))
+	for i, e := range m.mod.Elements {
+		m.elemInits[i] = make([]*function, len(e.Init))
+		for n, init := range e.Init {
+			fn, err := m.compileConstExpr(init, e.Type)
+			if err != nil {
+				return fmt.Errorf("elem %d: %w", i, err)
+			}
+			m.elemInits[i][n] = fn
+		}
+		if e.Mode != mod.ElementActive {
+			continue
+		}
+		fn, err := m.compileConstExpr(e.Offset, types.I32)
+		if err != nil {
+			return fmt.Errorf("elem %d: %w", i, err)
+		}
+		m.elemOffsets[i] = fn
+	}
+
+	m.dataOffsets = make([]*function, len(m.mod.Data))
+	for i, d := range m.mod.Data {
+		if d.Mode != mod.DataActive {
+			continue
+		}
+		fn, err := m.compileConstExpr(d.Offset, types.I32)
+		if err != nil {
+			return fmt.Errorf("data %d: %w", i, err)
+		}
+		m.dataOffsets[i] = fn
+	}
+
+	return nil
+}
+
+// compileConstExpr compiles a constant expression as a function which
+// returns a value of typ.
+func (m *Module) compileConstExpr(expr []instruction.Instruction, typ types.Type) (*function, error) {
+	fn := newFunction(&mod.Function{
+		Results:      []*mod.Result{{Type: typ}},
+		Instructions: expr,
+	}, -1)
+	if err := m.compile(fn); err != nil {
+		return nil, err
+	}
+
+	return fn, nil
+}
+
+// FuncType returns the type of the exported function of the name.
+func (m *Module) FuncType(name string) (*mod.FuncType, error) {
+	fn, err := m.exportedFunc(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fn.f.Signature(), nil
+}
+
+func (m *Module) exportedFunc(name string) (*function, error) {
+	// エクスポートを検索
+	e, ok := m.exports[name]
+	if !ok {
+		return nil, errExportNotFound
+	}
+
+	// エクスポートは関数？
+	if e.Target != mod.ExportFunction {
+		return nil, errExportTargetNotFunction
+	}
+
+	// 関数を検索
+	n, ok := m.mod.FunctionIndex(e.Index)
+	if !ok {
+		return nil, errFunctionNotFound
+	}
+
+	return m.funcs[n], nil
+}
@@ -0,0 +1,268 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kechako/wasmexec/mod"
+	"github.com/kechako/wasmexec/mod/text"
+	"github.com/kechako/wasmexec/mod/types"
+	"github.com/kechako/wasmexec/mod/validate"
+)
+
+func Test_Module_Instantiate(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (memory 1)
+  (table 1 funcref)
+  (global $g (mut i32) (i32.const 0))
+  (func $count (result i32)
+	(global.set $g (i32.add (global.get $g) (i32.const 1)))
+	(i32.store (i32.const 0) (global.get $g))
+	global.get $g)
+  (func $load (result i32)
+	(i32.load (i32.const 0)))
+  (func $grow (result i32)
+	(table.grow (ref.func $count) (i32.const 1)))
+  (export "count" (func $count))
+  (export "load" (func $load))
+  (export "grow" (func $grow))
+  (export "g" (global $g))
+  (export "table" (table 0)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Validate(m); err != nil {
+		t.Fatal(err)
+	}
+
+	compiled, err := Compile(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst1, err := compiled.Instantiate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst2, err := compiled.Instantiate(Fuel(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst1.Module() != compiled || inst2.Module() != compiled {
+		t.Error("Instance.Module(): want: the compiled module")
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := inst1.ExecFunc(ctx, "count"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := inst1.ExecFunc(ctx, "grow"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the state of inst1 is not shared with inst2
+	tests := map[string]struct {
+		inst *Instance
+		want any
+	}{
+		"inst1": {inst: inst1, want: int32(3)},
+		"inst2": {inst: inst2, want: int32(0)},
+	}
+	for name, tt := range tests {
+		results, err := tt.inst.ExecFunc(ctx, "load")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(results, newResults(tt.want)); diff != "" {
+			t.Errorf("%s: Instance.ExecFunc(ctx, \"load\"), differs: (-got +want)\n%s", name, diff)
+		}
+
+		g, err := tt.inst.Global("g")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := g.Get(); v != tt.want {
+			t.Errorf("%s: Global.Get(): want: %v, got: %v", name, tt.want, v)
+		}
+	}
+
+	table1, err := inst1.Table("table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table2, err := inst2.Table("table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table1.Size() != 2 || table2.Size() != 1 {
+		t.Errorf("Table.Size(): want: 2, 1, got: %d, %d", table1.Size(), table2.Size())
+	}
+
+	// the options are of each instance
+	if _, ok := inst1.Fuel(); ok {
+		t.Error("Instance.Fuel(): want: false, got: true")
+	}
+	if _, ok := inst2.Fuel(); !ok {
+		t.Error("Instance.Fuel(): want: true, got: false")
+	}
+}
+
+func Test_Module_Instantiate_FuncRef(t *testing.T) {
+	m, err := text.NewDecoder(strings.NewReader(`(module
+  (table 1 funcref)
+  (global $g (mut i32) (i32.const 0))
+  (func $get (result i32)
+	global.get $g)
+  (func $ref (result funcref)
+	ref.func $get)
+  (func $main (param funcref) (result i32)
+	(table.set (i32.const 0) (local.get 0))
+	(call_indirect (result i32) (i32.const 0)))
+  (elem declare func $get)
+  (export "ref" (func $ref))
+  (export "main" (func $main))
+  (export "g" (global $g)))`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Validate(m); err != nil {
+		t.Fatal(err)
+	}
+
+	compiled, err := Compile(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	insts := make([]*Instance, 2)
+	for i, v := range []int32{111, 222} {
+		inst, err := compiled.Instantiate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := inst.Global("g")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Set(v); err != nil {
+			t.Fatal(err)
+		}
+		insts[i] = inst
+	}
+
+	results, err := insts[0].ExecFunc(ctx, "ref")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the reference calls the function of the first instance, on the stack
+	// of the second one while the first one runs
+	ref := results[0]
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			if _, err := insts[0].ExecFunc(ctx, "ref"); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		results, err = insts[1].ExecFunc(ctx, "main", ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(results, newResults(int32(111))); diff != "" {
+			t.Fatalf("Instance.ExecFunc(ctx, \"main\", ref), differs: (-got +want)\n%s", diff)
+		}
+	}
+	wg.Wait()
+}
+
+func Test_Module_FuncType(t *testing.T) {
+	compiled, err := compileFile("bench.wat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	typ, err := compiled.FuncType("loop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &mod.FuncType{
+		Parameters: []types.Type{types.I32},
+		Results:    []types.Type{types.I64},
+	}
+	if diff := cmp.Diff(typ, want); diff != "" {
+		t.Errorf("Module.FuncType(\"loop\"), differs: (-got +want)\n%s", diff)
+	}
+
+	if _, err := compiled.FuncType("unknown"); !errors.Is(err, errExportNotFound) {
+		t.Errorf("Module.FuncType(\"unknown\"): err: want: %v, got: %v", errExportNotFound, err)
+	}
+}
+
+func Benchmark_New(b *testing.B) {
+	m, err := decodeFile("bench.wat")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := New(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_Module_Instantiate(b *testing.B) {
+	compiled, err := compileFile("bench.wat")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := compiled.Instantiate(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func decodeFile(name string) (*mod.Module, error) {
+	file, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	m, err := text.NewDecoder(file).Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validate.Validate(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func compileFile(name string) (*Module, error) {
+	m, err := decodeFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return Compile(m)
+}
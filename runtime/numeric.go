@@ -0,0 +1,91 @@
+package runtime
+
+// number is the Go types of the WebAssembly number types.
+type number interface {
+	int32 | int64 | float32 | float64
+}
+
+func pop[T number](inst *Instance) (T, error) {
+	bits, err := inst.stack.Pop()
+	if err != nil {
+		var v T
+		return v, err
+	}
+
+	return fromBits[T](bits), nil
+}
+
+func push[T number](inst *Instance, v T) error {
+	return inst.stack.Push(toBits(v))
+}
+
+func execUnop[T number](inst *Instance, f func(c T) T) error {
+	c, err := pop[T](inst)
+	if err != nil {
+		return err
+	}
+
+	return push(inst, f(c))
+}
+
+// execBinop executes a binary operator, which may trap.
+func execBinop[T number](inst *Instance, f func(c1, c2 T) (T, error)) error {
+	c2, err := pop[T](inst)
+	if err != nil {
+		return err
+	}
+	c1, err := pop[T](inst)
+	if err != nil {
+		return err
+	}
+
+	v, err := f(c1, c2)
+	if err != nil {
+		return err
+	}
+	return push(inst, v)
+}
+
+func execTestop[T number](inst *Instance, f func(c T) bool) error {
+	c, err := pop[T](inst)
+	if err != nil {
+		return err
+	}
+
+	return push(inst, boolToI32(f(c)))
+}
+
+func execRelop[T number](inst *Instance, f func(c1, c2 T) bool) error {
+	c2, err := pop[T](inst)
+	if err != nil {
+		return err
+	}
+	c1, err := pop[T](inst)
+	if err != nil {
+		return err
+	}
+
+	return push(inst, boolToI32(f(c1, c2)))
+}
+
+// execCvtop executes a conversion operator, which may trap.
+func execCvtop[F, T number](inst *Instance, f func(c F) (T, error)) error {
+	c, err := pop[F](inst)
+	if err != nil {
+		return err
+	}
+
+	v, err := f(c)
+	if err != nil {
+		return err
+	}
+	return push(inst, v)
+}
+
+func boolToI32(b bool) int32 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,409 @@
+package runtime
+
+import "github.com/kechako/wasmexec/mod/instruction"
+
+// opcode is the operation code of the compiled code, which has one for each
+// instruction and the ones the compiler adds.
+type opcode uint16
+
+// The opcodes of each family of the instructions are contiguous, so that the
+// interpreter dispatches them to the family by their ranges.
+const (
+	opI32Const opcode = iota
+	opI32Clz
+	opI32Ctz
+	opI32Popcnt
+	opI32Add
+	opI32Sub
+	opI32Mul
+	opI32DivS
+	opI32DivU
+	opI32RemS
+	opI32RemU
+	opI32And
+	opI32Or
+	opI32Xor
+	opI32Shl
+	opI32ShrS
+	opI32ShrU
+	opI32Rotl
+	opI32Rotr
+	opI32Eqz
+	opI32Eq
+	opI32Ne
+	opI32LtS
+	opI32LtU
+	opI32GtS
+	opI32GtU
+	opI32LeS
+	opI32LeU
+	opI32GeS
+	opI32GeU
+	opI64Const
+	opI64Clz
+	opI64Ctz
+	opI64Popcnt
+	opI64Add
+	opI64Sub
+	opI64Mul
+	opI64DivS
+	opI64DivU
+	opI64RemS
+	opI64RemU
+	opI64And
+	opI64Or
+	opI64Xor
+	opI64Shl
+	opI64ShrS
+	opI64ShrU
+	opI64Rotl
+	opI64Rotr
+	opI64Eqz
+	opI64Eq
+	opI64Ne
+	opI64LtS
+	opI64LtU
+	opI64GtS
+	opI64GtU
+	opI64LeS
+	opI64LeU
+	opI64GeS
+	opI64GeU
+	opF32Const
+	opF32Abs
+	opF32Neg
+	opF32Ceil
+	opF32Floor
+	opF32Trunc
+	opF32Nearest
+	opF32Sqrt
+	opF32Add
+	opF32Sub
+	opF32Mul
+	opF32Div
+	opF32Min
+	opF32Max
+	opF32Copysign
+	opF32Eq
+	opF32Ne
+	opF32Lt
+	opF32Gt
+	opF32Le
+	opF32Ge
+	opF64Const
+	opF64Abs
+	opF64Neg
+	opF64Ceil
+	opF64Floor
+	opF64Trunc
+	opF64Nearest
+	opF64Sqrt
+	opF64Add
+	opF64Sub
+	opF64Mul
+	opF64Div
+	opF64Min
+	opF64Max
+	opF64Copysign
+	opF64Eq
+	opF64Ne
+	opF64Lt
+	opF64Gt
+	opF64Le
+	opF64Ge
+	opI32WrapI64
+	opI32TruncF32S
+	opI32TruncF32U
+	opI32TruncF64S
+	opI32TruncF64U
+	opI64ExtendI32S
+	opI64ExtendI32U
+	opI64TruncF32S
+	opI64TruncF32U
+	opI64TruncF64S
+	opI64TruncF64U
+	opF32ConvertI32S
+	opF32ConvertI32U
+	opF32ConvertI64S
+	opF32ConvertI64U
+	opF32DemoteF64
+	opF64ConvertI32S
+	opF64ConvertI32U
+	opF64ConvertI64S
+	opF64ConvertI64U
+	opF64PromoteF32
+	opI32ReinterpretF32
+	opI64ReinterpretF64
+	opF32ReinterpretI32
+	opF64ReinterpretI64
+	opI32Extend8S
+	opI32Extend16S
+	opI64Extend8S
+	opI64Extend16S
+	opI64Extend32S
+	opI32TruncSatF32S
+	opI32TruncSatF32U
+	opI32TruncSatF64S
+	opI32TruncSatF64U
+	opI64TruncSatF32S
+	opI64TruncSatF32U
+	opI64TruncSatF64S
+	opI64TruncSatF64U
+	opDrop
+	opRefNull
+	opRefIsNull
+	opRefFunc
+	opLocalGet
+	opLocalSet
+	opLocalTee
+	opGlobalGet
+	opGlobalSet
+	opTableGet
+	opTableSet
+	opTableSize
+	opTableGrow
+	opTableFill
+	opTableCopy
+	opTableInit
+	opElemDrop
+	opI32Load
+	opI64Load
+	opF32Load
+	opF64Load
+	opI32Load8S
+	opI32Load8U
+	opI32Load16S
+	opI32Load16U
+	opI64Load8S
+	opI64Load8U
+	opI64Load16S
+	opI64Load16U
+	opI64Load32S
+	opI64Load32U
+	opI32Store
+	opI64Store
+	opF32Store
+	opF64Store
+	opI32Store8
+	opI32Store16
+	opI64Store8
+	opI64Store16
+	opI64Store32
+	opMemorySize
+	opMemoryGrow
+	opMemoryInit
+	opDataDrop
+	opUnreachable
+	opNop
+	opBlock
+	opLoop
+	opIf
+	opBr
+	opBrIf
+	opBrTable
+	opReturn
+	opCall
+	opCallIndirect
+
+	// opJump jumps to the end of an if block at the end of the then branch.
+	opJump
+)
+
+// opcodes are the opcodes of the instructions.
+var opcodes = map[instruction.InstructionName]opcode{
+	instruction.I32Const:          opI32Const,
+	instruction.I32Clz:            opI32Clz,
+	instruction.I32Ctz:            opI32Ctz,
+	instruction.I32Popcnt:         opI32Popcnt,
+	instruction.I32Add:            opI32Add,
+	instruction.I32Sub:            opI32Sub,
+	instruction.I32Mul:            opI32Mul,
+	instruction.I32DivS:           opI32DivS,
+	instruction.I32DivU:           opI32DivU,
+	instruction.I32RemS:           opI32RemS,
+	instruction.I32RemU:           opI32RemU,
+	instruction.I32And:            opI32And,
+	instruction.I32Or:             opI32Or,
+	instruction.I32Xor:            opI32Xor,
+	instruction.I32Shl:            opI32Shl,
+	instruction.I32ShrS:           opI32ShrS,
+	instruction.I32ShrU:           opI32ShrU,
+	instruction.I32Rotl:           opI32Rotl,
+	instruction.I32Rotr:           opI32Rotr,
+	instruction.I32Eqz:            opI32Eqz,
+	instruction.I32Eq:             opI32Eq,
+	instruction.I32Ne:             opI32Ne,
+	instruction.I32LtS:            opI32LtS,
+	instruction.I32LtU:            opI32LtU,
+	instruction.I32GtS:            opI32GtS,
+	instruction.I32GtU:            opI32GtU,
+	instruction.I32LeS:            opI32LeS,
+	instruction.I32LeU:            opI32LeU,
+	instruction.I32GeS:            opI32GeS,
+	instruction.I32GeU:            opI32GeU,
+	instruction.I64Const:          opI64Const,
+	instruction.I64Clz:            opI64Clz,
+	instruction.I64Ctz:            opI64Ctz,
+	instruction.I64Popcnt:         opI64Popcnt,
+	instruction.I64Add:            opI64Add,
+	instruction.I64Sub:            opI64Sub,
+	instruction.I64Mul:            opI64Mul,
+	instruction.I64DivS:           opI64DivS,
+	instruction.I64DivU:           opI64DivU,
+	instruction.I64RemS:           opI64RemS,
+	instruction.I64RemU:           opI64RemU,
+	instruction.I64And:            opI64And,
+	instruction.I64Or:             opI64Or,
+	instruction.I64Xor:            opI64Xor,
+	instruction.I64Shl:            opI64Shl,
+	instruction.I64ShrS:           opI64ShrS,
+	instruction.I64ShrU:           opI64ShrU,
+	instruction.I64Rotl:           opI64Rotl,
+	instruction.I64Rotr:           opI64Rotr,
+	instruction.I64Eqz:            opI64Eqz,
+	instruction.I64Eq:             opI64Eq,
+	instruction.I64Ne:             opI64Ne,
+	instruction.I64LtS:            opI64LtS,
+	instruction.I64LtU:            opI64LtU,
+	instruction.I64GtS:            opI64GtS,
+	instruction.I64GtU:            opI64GtU,
+	instruction.I64LeS:            opI64LeS,
+	instruction.I64LeU:            opI64LeU,
+	instruction.I64GeS:            opI64GeS,
+	instruction.I64GeU:            opI64GeU,
+	instruction.F32Const:          opF32Const,
+	instruction.F32Abs:            opF32Abs,
+	instruction.F32Neg:            opF32Neg,
+	instruction.F32Ceil:           opF32Ceil,
+	instruction.F32Floor:          opF32Floor,
+	instruction.F32Trunc:          opF32Trunc,
+	instruction.F32Nearest:        opF32Nearest,
+	instruction.F32Sqrt:           opF32Sqrt,
+	instruction.F32Add:            opF32Add,
+	instruction.F32Sub:            opF32Sub,
+	instruction.F32Mul:            opF32Mul,
+	instruction.F32Div:            opF32Div,
+	instruction.F32Min:            opF32Min,
+	instruction.F32Max:            opF32Max,
+	instruction.F32Copysign:       opF32Copysign,
+	instruction.F32Eq:             opF32Eq,
+	instruction.F32Ne:             opF32Ne,
+	instruction.F32Lt:             opF32Lt,
+	instruction.F32Gt:             opF32Gt,
+	instruction.F32Le:             opF32Le,
+	instruction.F32Ge:             opF32Ge,
+	instruction.F64Const:          opF64Const,
+	instruction.F64Abs:            opF64Abs,
+	instruction.F64Neg:            opF64Neg,
+	instruction.F64Ceil:           opF64Ceil,
+	instruction.F64Floor:          opF64Floor,
+	instruction.F64Trunc:          opF64Trunc,
+	instruction.F64Nearest:        opF64Nearest,
+	instruction.F64Sqrt:           opF64Sqrt,
+	instruction.F64Add:            opF64Add,
+	instruction.F64Sub:            opF64Sub,
+	instruction.F64Mul:            opF64Mul,
+	instruction.F64Div:            opF64Div,
+	instruction.F64Min:            opF64Min,
+	instruction.F64Max:            opF64Max,
+	instruction.F64Copysign:       opF64Copysign,
+	instruction.F64Eq:             opF64Eq,
+	instruction.F64Ne:             opF64Ne,
+	instruction.F64Lt:             opF64Lt,
+	instruction.F64Gt:             opF64Gt,
+	instruction.F64Le:             opF64Le,
+	instruction.F64Ge:             opF64Ge,
+	instruction.I32WrapI64:        opI32WrapI64,
+	instruction.I32TruncF32S:      opI32TruncF32S,
+	instruction.I32TruncF32U:      opI32TruncF32U,
+	instruction.I32TruncF64S:      opI32TruncF64S,
+	instruction.I32TruncF64U:      opI32TruncF64U,
+	instruction.I64ExtendI32S:     opI64ExtendI32S,
+	instruction.I64ExtendI32U:     opI64ExtendI32U,
+	instruction.I64TruncF32S:      opI64TruncF32S,
+	instruction.I64TruncF32U:      opI64TruncF32U,
+	instruction.I64TruncF64S:      opI64TruncF64S,
+	instruction.I64TruncF64U:      opI64TruncF64U,
+	instruction.F32ConvertI32S:    opF32ConvertI32S,
+	instruction.F32ConvertI32U:    opF32ConvertI32U,
+	instruction.F32ConvertI64S:    opF32ConvertI64S,
+	instruction.F32ConvertI64U:    opF32ConvertI64U,
+	instruction.F32DemoteF64:      opF32DemoteF64,
+	instruction.F64ConvertI32S:    opF64ConvertI32S,
+	instruction.F64ConvertI32U:    opF64ConvertI32U,
+	instruction.F64ConvertI64S:    opF64ConvertI64S,
+	instruction.F64ConvertI64U:    opF64ConvertI64U,
+	instruction.F64PromoteF32:     opF64PromoteF32,
+	instruction.I32ReinterpretF32: opI32ReinterpretF32,
+	instruction.I64ReinterpretF64: opI64ReinterpretF64,
+	instruction.F32ReinterpretI32: opF32ReinterpretI32,
+	instruction.F64ReinterpretI64: opF64ReinterpretI64,
+	instruction.I32Extend8S:       opI32Extend8S,
+	instruction.I32Extend16S:      opI32Extend16S,
+	instruction.I64Extend8S:       opI64Extend8S,
+	instruction.I64Extend16S:      opI64Extend16S,
+	instruction.I64Extend32S:      opI64Extend32S,
+	instruction.I32TruncSatF32S:   opI32TruncSatF32S,
+	instruction.I32TruncSatF32U:   opI32TruncSatF32U,
+	instruction.I32TruncSatF64S:   opI32TruncSatF64S,
+	instruction.I32TruncSatF64U:   opI32TruncSatF64U,
+	instruction.I64TruncSatF32S:   opI64TruncSatF32S,
+	instruction.I64TruncSatF32U:   opI64TruncSatF32U,
+	instruction.I64TruncSatF64S:   opI64TruncSatF64S,
+	instruction.I64TruncSatF64U:   opI64TruncSatF64U,
+	instruction.Drop:              opDrop,
+	instruction.RefNull:           opRefNull,
+	instruction.RefIsNull:         opRefIsNull,
+	instruction.RefFunc:           opRefFunc,
+	instruction.LocalGet:          opLocalGet,
+	instruction.LocalSet:          opLocalSet,
+	instruction.LocalTee:          opLocalTee,
+	instruction.GlobalGet:         opGlobalGet,
+	instruction.GlobalSet:         opGlobalSet,
+	instruction.TableGet:          opTableGet,
+	instruction.TableSet:          opTableSet,
+	instruction.TableSize:         opTableSize,
+	instruction.TableGrow:         opTableGrow,
+	instruction.TableFill:         opTableFill,
+	instruction.TableCopy:         opTableCopy,
+	instruction.TableInit:         opTableInit,
+	instruction.ElemDrop:          opElemDrop,
+	instruction.I32Load:           opI32Load,
+	instruction.I64Load:           opI64Load,
+	instruction.F32Load:           opF32Load,
+	instruction.F64Load:           opF64Load,
+	instruction.I32Load8S:         opI32Load8S,
+	instruction.I32Load8U:         opI32Load8U,
+	instruction.I32Load16S:        opI32Load16S,
+	instruction.I32Load16U:        opI32Load16U,
+	instruction.I64Load8S:         opI64Load8S,
+	instruction.I64Load8U:         opI64Load8U,
+	instruction.I64Load16S:        opI64Load16S,
+	instruction.I64Load16U:        opI64Load16U,
+	instruction.I64Load32S:        opI64Load32S,
+	instruction.I64Load32U:        opI64Load32U,
+	instruction.I32Store:          opI32Store,
+	instruction.I64Store:          opI64Store,
+	instruction.F32Store:          opF32Store,
+	instruction.F64Store:          opF64Store,
+	instruction.I32Store8:         opI32Store8,
+	instruction.I32Store16:        opI32Store16,
+	instruction.I64Store8:         opI64Store8,
+	instruction.I64Store16:        opI64Store16,
+	instruction.I64Store32:        opI64Store32,
+	instruction.MemorySize:        opMemorySize,
+	instruction.MemoryGrow:        opMemoryGrow,
+	instruction.MemoryInit:        opMemoryInit,
+	instruction.DataDrop:          opDataDrop,
+	instruction.Unreachable:       opUnreachable,
+	instruction.Nop:               opNop,
+	instruction.Block:             opBlock,
+	instruction.Loop:              opLoop,
+	instruction.If:                opIf,
+	instruction.Br:                opBr,
+	instruction.BrIf:              opBrIf,
+	instruction.BrTable:           opBrTable,
+	instruction.Return:            opReturn,
+	instruction.Call:              opCall,
+	instruction.CallIndirect:      opCallIndirect,
+}
@@ -1,76 +1,104 @@
 package runtime
 
-import "container/list"
-
-type ElementType byte
-
-const (
-	ValueElement ElementType = iota
-	LabelElement
-	ActivationElement
-)
-
-type Element struct {
-	Type  ElementType
-	Value Value
+// Stack is the stack of a module instance, which consists of the operand
+// stack of the values and the frame stack of the functions being executed.
+// The values are stored by their bit patterns, which the instructions
+// interpret according to their static types. The local variables of a
+// function are stored in the operand stack below the operands of the
+// function.
+type Stack struct {
+	values []uint64
+	frames []frame
 }
 
-func newValueElement(v any) *Element {
-	return &Element{
-		Type:  ValueElement,
-		Value: NewValue(v),
-	}
+// frame is a function being executed.
+type frame struct {
+	fn *function
+	// position of the next operation in the code of the function
+	pc int
+	// base of the local variables of the function in the operand stack,
+	// which the heights of the branches are relative to
+	locals int
 }
 
-func newActivationElement(ctx VMContext) *Element {
-	return &Element{
-		Type:  ActivationElement,
-		Value: NewValue(ctx),
+// NewStack returns a stack which can hold cap values, including the local
+// variables.
+func NewStack(cap int) *Stack {
+	return &Stack{
+		values: make([]uint64, 0, cap),
 	}
 }
 
-func (elm *Element) Int32() (int32, bool) {
-	return getElementValue[int32](elm, ValueElement)
+// Len returns the number of the values.
+func (s *Stack) Len() int {
+	return len(s.values)
 }
 
-func (elm *Element) VMContext() (VMContext, bool) {
-	return getElementValue[VMContext](elm, ActivationElement)
+// Push pushes the value, and returns an error if the stack is full.
+func (s *Stack) Push(v uint64) error {
+	if len(s.values) == cap(s.values) {
+		return errStackOverflow
+	}
+
+	s.values = append(s.values, v)
+
+	return nil
 }
 
-func getElementValue[T any](elm *Element, typ ElementType) (value T, ok bool) {
-	if elm.Type != typ {
-		return value, false
+// Pop pops a value, and returns an error if the stack is empty.
+func (s *Stack) Pop() (uint64, error) {
+	n := len(s.values)
+	if n == 0 {
+		return 0, errStackInconsistent
 	}
+	v := s.values[n-1]
+	s.values = s.values[:n-1]
 
-	return GetValue[T](elm.Value)
+	return v, nil
 }
 
-type Stack struct {
-	l   *list.List
-	cap int
+// pushFrame pushes the frame, whose local variables must be on the operand
+// stack.
+func (s *Stack) pushFrame(fr frame) error {
+	if fr.locals < 0 || fr.locals+fr.fn.locals > len(s.values) {
+		return errStackInconsistent
+	}
+
+	s.frames = append(s.frames, fr)
+
+	return nil
 }
 
-func NewStack(cap int) *Stack {
-	return &Stack{
-		l:   list.New(),
-		cap: cap,
-	}
+// popFrame pops the frame at the top.
+func (s *Stack) popFrame() {
+	s.frames = s.frames[:len(s.frames)-1]
 }
 
-func (s *Stack) Push(elm *Element) {
-	if s.l.Len() == s.cap {
-		panic("stack overflow")
+// topFrame returns the frame at the top, or nil if there are no frames.
+// The frame is valid until a frame is pushed.
+func (s *Stack) topFrame() *frame {
+	if len(s.frames) == 0 {
+		return nil
 	}
 
-	s.l.PushBack(elm)
+	return &s.frames[len(s.frames)-1]
 }
 
-func (s *Stack) Pop() *Element {
-	e := s.l.Back()
-	if e == nil {
-		panic("stack is empty")
+// unwind removes the values above height, keeping only the top arity values
+// at height.
+func (s *Stack) unwind(height, arity int) error {
+	top := len(s.values)
+	if height < 0 || top-arity < height {
+		return errStackInconsistent
 	}
-	s.l.Remove(e)
+	copy(s.values[height:], s.values[top-arity:top])
+	s.values = s.values[:height+arity]
+
+	return nil
+}
 
-	return e.Value.(*Element)
+// clear removes all the values and the frames.
+func (s *Stack) clear() {
+	s.values = s.values[:0]
+	s.frames = s.frames[:0]
 }
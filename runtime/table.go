@@ -0,0 +1,330 @@
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/kechako/wasmexec/mod"
+)
+
+// defaultTableLimit is the number of elements the tables can grow to unless
+// TableLimit is given, which keeps table.grow from allocating too much.
+const defaultTableLimit = 1 << 20
+
+// Table is a table of function references, which call_indirect calls the
+// functions through.
+type Table struct {
+	elems []FuncRef
+	// maximum number of elements the table can grow to
+	max uint32
+}
+
+// newTable returns a table of the minimum size of limits filled with null,
+// which can grow up to the maximum of limits or limit elements whichever is
+// smaller. It returns an error without allocating the elements if the
+// minimum size exceeds limit.
+func newTable(limits mod.Limits, limit uint32) (*Table, error) {
+	if limits.Min > limit {
+		return nil, fmt.Errorf("%w: minimum %d exceeds %d elements", errTableLimitExceeded, limits.Min, limit)
+	}
+
+	max := limit
+	if limits.HasMax && limits.Max < max {
+		max = limits.Max
+	}
+
+	return &Table{
+		elems: make([]FuncRef, limits.Min),
+		max:   max,
+	}, nil
+}
+
+// NewTable returns a table of the minimum size of limits filled with null,
+// which can grow up to the maximum of limits, to be imported by modules. The
+// table grows at most to the default limit of TableLimit or the minimum size
+// whichever is larger.
+func NewTable(limits mod.Limits) *Table {
+	limit := uint32(defaultTableLimit)
+	if limits.Min > limit {
+		limit = limits.Min
+	}
+	t, _ := newTable(limits, limit)
+
+	return t
+}
+
+// Size returns the number of elements.
+func (t *Table) Size() uint32 {
+	return uint32(len(t.elems))
+}
+
+// Grow grows the table by delta elements of init, and returns the previous
+// number of elements. It reports false and leaves the table unchanged if the
+// size exceeds the maximum.
+func (t *Table) Grow(delta uint32, init FuncRef) (uint32, bool) {
+	size := t.Size()
+	if uint64(size)+uint64(delta) > uint64(t.max) {
+		return size, false
+	}
+
+	elems := make([]FuncRef, size+delta)
+	copy(elems, t.elems)
+	if !init.IsNull() {
+		for i := size; i < size+delta; i++ {
+			elems[i] = init
+		}
+	}
+	t.elems = elems
+
+	return size, true
+}
+
+// Get returns the element at i.
+func (t *Table) Get(i uint32) (FuncRef, error) {
+	if i >= t.Size() {
+		return FuncRef{}, errOutOfBoundsTableAccess
+	}
+
+	return t.elems[i], nil
+}
+
+// Set changes the element at i.
+func (t *Table) Set(i uint32, ref FuncRef) error {
+	if i >= t.Size() {
+		return errOutOfBoundsTableAccess
+	}
+	t.elems[i] = ref
+
+	return nil
+}
+
+// slice returns the n elements at offset.
+func (t *Table) slice(offset, n uint32) ([]FuncRef, error) {
+	if uint64(offset)+uint64(n) > uint64(len(t.elems)) {
+		return nil, errOutOfBoundsTableAccess
+	}
+
+	return t.elems[offset : offset+n], nil
+}
+
+// makeTables makes the tables defined in the module, which follow the
+// imported tables.
+func (inst *Instance) makeTables(limit uint32) error {
+	for i, t := range inst.module.mod.Tables {
+		if t.Import != nil {
+			continue
+		}
+		table, err := newTable(t.Limits, limit)
+		if err != nil {
+			return fmt.Errorf("table %d: %w", i, err)
+		}
+		inst.tables[i] = table
+	}
+
+	return nil
+}
+
+// initElements evaluates the references of the element segments, copies the
+// active segments to the tables, and drops them and the declarative
+// segments. Nothing is copied if any of the segments is out of bounds.
+func (inst *Instance) initElements() error {
+	inst.elems = make([][]FuncRef, len(inst.module.mod.Elements))
+
+	type activeElement struct {
+		dst  []FuncRef
+		refs []FuncRef
+	}
+	var actives []activeElement
+	for i, e := range inst.module.mod.Elements {
+		refs := make([]FuncRef, len(e.Init))
+		for n, init := range inst.module.elemInits[i] {
+			v, err := inst.evalConstExpr(init)
+			if err != nil {
+				return err
+			}
+			refs[n] = v.(FuncRef)
+		}
+
+		switch e.Mode {
+		case mod.ElementPassive:
+			inst.elems[i] = refs
+			continue
+		case mod.ElementDeclarative:
+			continue
+		}
+
+		n, ok := inst.module.mod.TableIndex(e.Table)
+		if !ok {
+			return errTableNotFound
+		}
+		table := inst.tables[n]
+		offset, err := inst.evalConstExpr(inst.module.elemOffsets[i])
+		if err != nil {
+			return err
+		}
+		dst, err := table.slice(uint32(offset.(int32)), uint32(len(refs)))
+		if err != nil {
+			// an active segment out of bounds traps at the instantiation
+			return inst.newTrap(fmt.Errorf("elem %d: %w", i, err))
+		}
+		actives = append(actives, activeElement{dst: dst, refs: refs})
+	}
+
+	for _, e := range actives {
+		copy(e.dst, e.refs)
+	}
+
+	return nil
+}
+
+// indirectFunc pops an index in the table, and returns the reference of the
+// element, whose function type must match the call_indirect operation.
+func (inst *Instance) indirectFunc(op *operation) (FuncRef, error) {
+	table := inst.tables[op.index]
+	idx, err := pop[int32](inst)
+	if err != nil {
+		return FuncRef{}, err
+	}
+	if uint32(idx) >= table.Size() {
+		return FuncRef{}, errUndefinedElement
+	}
+
+	ref := table.elems[idx]
+	if ref.IsNull() {
+		return FuncRef{}, errUninitializedElement
+	}
+	if !ref.fn.typ.Equal(op.typ) {
+		return FuncRef{}, errIndirectCallTypeMismatch
+	}
+
+	return ref, nil
+}
+
+func (inst *Instance) execReference(op *operation) error {
+	switch op.code {
+	case opRefNull:
+		return inst.stack.Push(inst.refBits(FuncRef{}))
+	case opRefIsNull:
+		ref, err := popRef(inst)
+		if err != nil {
+			return err
+		}
+		return push(inst, boolToI32(ref.IsNull()))
+	case opRefFunc:
+		return inst.stack.Push(inst.refBits(FuncRef{inst: inst, fn: op.fn}))
+	}
+
+	return errUnsupportedInstruction
+}
+
+func (inst *Instance) execTable(op *operation) error {
+	if op.code == opElemDrop {
+		inst.elems[op.index] = nil
+		return nil
+	}
+
+	table := inst.tables[op.index]
+	switch op.code {
+	case opTableGet:
+		idx, err := pop[int32](inst)
+		if err != nil {
+			return err
+		}
+		ref, err := table.Get(uint32(idx))
+		if err != nil {
+			return err
+		}
+		return inst.stack.Push(inst.refBits(ref))
+	case opTableSet:
+		ref, err := popRef(inst)
+		if err != nil {
+			return err
+		}
+		idx, err := pop[int32](inst)
+		if err != nil {
+			return err
+		}
+		return table.Set(uint32(idx), ref)
+	case opTableSize:
+		return push(inst, int32(table.Size()))
+	case opTableGrow:
+		delta, err := pop[int32](inst)
+		if err != nil {
+			return err
+		}
+		init, err := popRef(inst)
+		if err != nil {
+			return err
+		}
+		size, ok := table.Grow(uint32(delta), init)
+		if !ok {
+			return push(inst, int32(-1))
+		}
+		return push(inst, int32(size))
+	case opTableFill:
+		n, err := pop[int32](inst)
+		if err != nil {
+			return err
+		}
+		ref, err := popRef(inst)
+		if err != nil {
+			return err
+		}
+		offset, err := pop[int32](inst)
+		if err != nil {
+			return err
+		}
+		dst, err := table.slice(uint32(offset), uint32(n))
+		if err != nil {
+			return err
+		}
+		for n := range dst {
+			dst[n] = ref
+		}
+	case opTableCopy:
+		return execTableCopy(inst, table, inst.tables[op.source])
+	case opTableInit:
+		return execTableCopy(inst, table, &Table{elems: inst.elems[op.source]})
+	default:
+		return errUnsupportedInstruction
+	}
+
+	return nil
+}
+
+// execTableCopy pops a size, a source offset and a destination offset, and
+// copies the elements from src to dst, which may overlap.
+func execTableCopy(inst *Instance, dst, src *Table) error {
+	n, err := pop[int32](inst)
+	if err != nil {
+		return err
+	}
+	s, err := pop[int32](inst)
+	if err != nil {
+		return err
+	}
+	d, err := pop[int32](inst)
+	if err != nil {
+		return err
+	}
+
+	srcElems, err := src.slice(uint32(s), uint32(n))
+	if err != nil {
+		return err
+	}
+	dstElems, err := dst.slice(uint32(d), uint32(n))
+	if err != nil {
+		return err
+	}
+	copy(dstElems, srcElems)
+
+	return nil
+}
+
+func popRef(inst *Instance) (FuncRef, error) {
+	bits, err := inst.stack.Pop()
+	if err != nil {
+		return FuncRef{}, err
+	}
+
+	return inst.bitsRef(bits), nil
+}
@@ -0,0 +1,73 @@
+(module
+  (memory 1)
+  (table 4 funcref)
+  (elem (i32.const 0) func $inc $dec $double $triple)
+  (type $unop (func (param i32) (result i32)))
+  (func $fib (param $n i32) (result i32)
+	(if (result i32) (i32.lt_s (local.get $n) (i32.const 2))
+	  (then (local.get $n))
+	  (else
+		(i32.add
+		  (call $fib (i32.sub (local.get $n) (i32.const 1)))
+		  (call $fib (i32.sub (local.get $n) (i32.const 2)))))))
+  (func $loop (param $n i32) (result i64)
+	(local $i i32)
+	(local $sum i64)
+	(block $done
+	  (loop $l
+		(br_if $done (i32.ge_s (local.get $i) (local.get $n)))
+		(local.set $sum (i64.add (local.get $sum) (i64.extend_i32_s (local.get $i))))
+		(local.set $i (i32.add (local.get $i) (i32.const 1)))
+		br $l))
+	local.get $sum)
+  (func $memory (param $n i32) (result i32)
+	(local $i i32)
+	(local $sum i32)
+	(block $filled
+	  (loop $fill
+		(br_if $filled (i32.ge_s (local.get $i) (local.get $n)))
+		(i32.store (i32.shl (local.get $i) (i32.const 2)) (local.get $i))
+		(local.set $i (i32.add (local.get $i) (i32.const 1)))
+		br $fill))
+	(local.set $i (i32.const 0))
+	(block $added
+	  (loop $add
+		(br_if $added (i32.ge_s (local.get $i) (local.get $n)))
+		(local.set $sum
+		  (i32.add (local.get $sum) (i32.load (i32.shl (local.get $i) (i32.const 2)))))
+		(local.set $i (i32.add (local.get $i) (i32.const 1)))
+		br $add))
+	local.get $sum)
+  (func $dispatch (param $n i32) (result i32)
+	(local $i i32)
+	(local $v i32)
+	(block $done
+	  (loop $l
+		(br_if $done (i32.ge_s (local.get $i) (local.get $n)))
+		(block $indirect
+		  (block $c
+			(block $b
+			  (block $a
+				(br_table $a $b $c $indirect (i32.and (local.get $i) (i32.const 3))))
+			  (local.set $v (i32.add (local.get $v) (i32.const 1)))
+			  br $indirect)
+			(local.set $v (i32.sub (local.get $v) (i32.const 1)))
+			br $indirect)
+		  (local.set $v (i32.xor (local.get $v) (local.get $i))))
+		(local.set $v
+		  (call_indirect (type $unop)
+			(local.get $v) (i32.and (local.get $i) (i32.const 3))))
+		(local.set $i (i32.add (local.get $i) (i32.const 1)))
+		br $l))
+	local.get $v)
+
+  (func $inc (type $unop) (i32.add (local.get 0) (i32.const 1)))
+  (func $dec (type $unop) (i32.sub (local.get 0) (i32.const 1)))
+  (func $double (type $unop) (i32.shl (local.get 0) (i32.const 1)))
+  (func $triple (type $unop) (i32.mul (local.get 0) (i32.const 3)))
+
+  (export "fib" (func $fib))
+  (export "loop" (func $loop))
+  (export "memory" (func $memory))
+  (export "dispatch" (func $dispatch))
+)
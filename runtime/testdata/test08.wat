@@ -0,0 +1,19 @@
+(module
+  (func $main
+	(result i32)
+
+	i32.const 20
+	i32.const 10
+	call $sub
+	)
+  (func $sub
+	(param i32)
+	(param i32)
+
+	(result i32)
+
+	local.get 0
+	local.get 1
+	i32.sub
+	)
+  (export "main" (func $main)))
@@ -0,0 +1,121 @@
+(module
+  (func $main
+	(result i64) (result i64) (result i64) (result i64) (result i64)
+	(result i64) (result i64) (result i64) (result i64) (result i64)
+	(result i64) (result i64) (result i64) (result i64) (result i64)
+	(result i64) (result i64) (result i64) (result i64) (result i64)
+	(result i32) (result i32) (result i32) (result i32) (result i32)
+	(result i32) (result i32) (result i32) (result i32) (result i32)
+	(result i32)
+	(result i64)
+
+	i64.const 0xffff_ffff_ffff_ffff
+	i64.clz
+	i64.const 1
+	i64.clz
+	i64.const 0x8000000000000000
+	i64.ctz
+	i64.const 0xff
+	i64.popcnt
+
+	i64.const 0x7fffffffffffffff
+	i64.const 1
+	i64.add
+	i64.const 1
+	i64.const 2
+	i64.sub
+	i64.const 0x100000000
+	i64.const 0x100000000
+	i64.mul
+	i64.const -7
+	i64.const 2
+	i64.div_s
+	i64.const -1
+	i64.const 2
+	i64.div_u
+	i64.const -7
+	i64.const 2
+	i64.rem_s
+	i64.const -1
+	i64.const 10
+	i64.rem_u
+
+	i64.const 0xf0
+	i64.const 0x3c
+	i64.and
+	i64.const 0xf0
+	i64.const 0x3c
+	i64.or
+	i64.const 0xf0
+	i64.const 0x3c
+	i64.xor
+	i64.const 1
+	i64.const 65
+	i64.shl
+	i64.const -16
+	i64.const 2
+	i64.shr_s
+	i64.const -16
+	i64.const 60
+	i64.shr_u
+	i64.const 0x8000000000000001
+	i64.const 1
+	i64.rotl
+	i64.const 1
+	i64.const 1
+	i64.rotr
+	i64.const 0x8000000000000000
+	i64.const -1
+	i64.rem_s
+
+	i64.const 0
+	i64.eqz
+	i64.const 1
+	i64.const 1
+	i64.eq
+	i64.const 1
+	i64.const 1
+	i64.ne
+	i64.const -1
+	i64.const 1
+	i64.lt_s
+	i64.const -1
+	i64.const 1
+	i64.lt_u
+	i64.const -1
+	i64.const 1
+	i64.gt_s
+	i64.const -1
+	i64.const 1
+	i64.gt_u
+	i64.const 1
+	i64.const 1
+	i64.le_s
+	i64.const -1
+	i64.const 1
+	i64.le_u
+	i64.const -1
+	i64.const 1
+	i64.ge_s
+	i64.const -1
+	i64.const 1
+	i64.ge_u
+
+	i64.const 40
+	i32.const 2
+	call $mul
+	)
+  (func $mul
+	(param $a i64)
+	(param $b i32)
+	(result i64)
+
+	(local $l i64)
+
+	local.get $a
+	local.set $l
+	local.get $l
+	local.get $l
+	i64.add
+	)
+  (export "main" (func $main)))
@@ -0,0 +1,129 @@
+(module
+  (func $main
+	(result f32) (result f32) (result f32) (result f32) (result i32)
+	(result f32) (result f32) (result f32)
+	(result f32) (result f32) (result f32)
+	(result f32) (result f32) (result f32) (result f32)
+	(result f32) (result f32)
+	(result i32) (result i32) (result i32) (result i32) (result i32) (result i32) (result i32)
+	(result f64) (result f64) (result f64) (result f64)
+	(result f64) (result f64) (result f64) (result f64) (result f64)
+	(result f64) (result f64) (result f64) (result i32)
+	(result i32) (result i32)
+	(local $x f32) (local $y f64)
+
+	f32.const 1.5
+	f32.const 2.25
+	f32.add
+	f32.const 16777216
+	f32.const 1
+	f32.add
+	f32.const -0.0
+	f32.const 0.0
+	f32.min
+	f32.const 0.0
+	f32.const -0.0
+	f32.max
+	f32.const nan
+	f32.const 1
+	f32.min
+	local.tee $x
+	local.get $x
+	f32.ne
+
+	f32.const -nan:0x1
+	f32.abs
+	f32.const nan
+	f32.neg
+	f32.const 1.5
+	f32.const -0.0
+	f32.copysign
+
+	f32.const -2.5
+	f32.nearest
+	f32.const 3.5
+	f32.nearest
+	f32.const -0.5
+	f32.nearest
+	f32.const -1.5
+	f32.ceil
+	f32.const -1.5
+	f32.floor
+	f32.const -0.7
+	f32.trunc
+	f32.const 2
+	f32.sqrt
+	f32.const 1
+	f32.const 0
+	f32.div
+	f32.const -1
+	f32.const 0.0
+	f32.div
+
+	f32.const nan
+	f32.const nan
+	f32.eq
+	f32.const nan
+	f32.const nan
+	f32.ne
+	f32.const -0.0
+	f32.const 0.0
+	f32.eq
+	f32.const 1
+	f32.const 2
+	f32.lt
+	f32.const 1
+	f32.const 2
+	f32.gt
+	f32.const 2
+	f32.const 2
+	f32.le
+	f32.const nan
+	f32.const 1
+	f32.ge
+
+	f64.const 16777216
+	f64.const 1
+	f64.add
+	f64.const 0.1
+	f64.const 0.2
+	f64.add
+	f64.const -0.0
+	f64.const 0.0
+	f64.min
+	f64.const 0.0
+	f64.const -0.0
+	f64.max
+	f64.const -nan:0x1
+	f64.abs
+	f64.const 2
+	f64.const -inf
+	f64.copysign
+	f64.const 0.5
+	f64.nearest
+	f64.const -1.5
+	f64.nearest
+	f64.const 16
+	f64.sqrt
+	f64.const -0.0
+	f64.floor
+	f64.const 1e300
+	f64.trunc
+	f64.const 1
+	f64.const inf
+	f64.div
+	f64.const nan
+	f64.const 1
+	f64.max
+	local.tee $y
+	local.get $y
+	f64.ne
+	f64.const nan
+	f64.const 1
+	f64.lt
+	f64.const inf
+	f64.const inf
+	f64.ge
+  )
+  (export "main" (func $main))
+)
@@ -0,0 +1,73 @@
+(module
+  (func $main
+	(result i32) (result i32) (result i32) (result i64) (result i64)
+	(result i64) (result i64)
+	(result i32) (result i32) (result i32) (result i64) (result i64)
+	(result f32) (result f64) (result f64) (result f32)
+	(result f32) (result f64)
+	(result i32) (result f64) (result i64) (result f32)
+	(result i32) (result i32) (result i64) (result i64) (result i64)
+
+	i64.const 0x1_0000_0001
+	i32.wrap_i64
+	f32.const -3.9
+	i32.trunc_f32_s
+	f64.const 4294967295.9
+	i32.trunc_f64_u
+	i32.const -1
+	i64.extend_i32_s
+	i32.const -1
+	i64.extend_i32_u
+
+	f64.const -9223372036854775808
+	i64.trunc_f64_s
+	f32.const 1e19
+	i64.trunc_f32_u
+
+	f64.const nan
+	i32.trunc_sat_f64_s
+	f64.const -1e10
+	i32.trunc_sat_f64_s
+	f64.const 1e10
+	i32.trunc_sat_f64_u
+	f32.const -1
+	i64.trunc_sat_f32_u
+	f64.const inf
+	i64.trunc_sat_f64_s
+
+	i32.const -1
+	f32.convert_i32_u
+	i64.const 0x7fffffffffffffff
+	f64.convert_i64_s
+	i64.const -1
+	f64.convert_i64_u
+	i64.const 16777217
+	f32.convert_i64_s
+
+	f64.const 0.1
+	f32.demote_f64
+	f32.const 0.1
+	f64.promote_f32
+
+	f32.const -0.0
+	i32.reinterpret_f32
+	i64.const 0x7ff8000000000001
+	f64.reinterpret_i64
+	f64.const 1
+	i64.reinterpret_f64
+	i32.const 0x7fc00000
+	f32.reinterpret_i32
+
+	i32.const 0x80
+	i32.extend8_s
+	i32.const 0x7fff
+	i32.extend16_s
+	i64.const 0xff
+	i64.extend8_s
+	i64.const 0x8000
+	i64.extend16_s
+	i64.const 0x80000000
+	i64.extend32_s
+  )
+  (export "main" (func $main))
+)
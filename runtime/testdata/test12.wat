@@ -0,0 +1,69 @@
+(module
+  (func $main
+	(result i32) (result i32) (result i32) (result i32) (result i32)
+	(result i32) (result i32) (result i32) (result i32) (result i32)
+	(result i32) (result i32) (result i32) (result i32) (result i32)
+	(result i32) (result i32) (result i32) (result i32) (result i32)
+
+	i32.const 0xffff_ffff
+	i32.clz
+	i32.const 1
+	i32.clz
+	i32.const 0x80000000
+	i32.ctz
+	i32.const 0xff
+	i32.popcnt
+
+	i32.const -7
+	i32.const 2
+	i32.div_u
+	i32.const -7
+	i32.const 2
+	i32.rem_s
+	i32.const -7
+	i32.const 2
+	i32.rem_u
+	i32.const 0x80000000
+	i32.const -1
+	i32.rem_s
+
+	i32.const 0xf0
+	i32.const 0x3c
+	i32.and
+	i32.const 0xf0
+	i32.const 0x3c
+	i32.or
+	i32.const 0xf0
+	i32.const 0x3c
+	i32.xor
+	i32.const 1
+	i32.const 33
+	i32.shl
+	i32.const -8
+	i32.const 1
+	i32.shr_s
+	i32.const -8
+	i32.const 28
+	i32.shr_u
+	i32.const 0x80000001
+	i32.const 1
+	i32.rotl
+	i32.const 1
+	i32.const 1
+	i32.rotr
+
+	i32.const -1
+	i32.const 1
+	i32.lt_u
+	i32.const -1
+	i32.const 1
+	i32.gt_u
+	i32.const 1
+	i32.const 1
+	i32.le_u
+	i32.const 0
+	i32.const -1
+	i32.ge_u
+  )
+  (export "main" (func $main))
+)
@@ -0,0 +1,94 @@
+(module
+  (func $main
+	(result i32) (result i32) (result i32) (result i32) (result i32)
+	(result i32) (result i32) (result i32) (result i32) (result i32)
+	(result i32) (result i32) (result i32)
+
+	call $br_depth
+	call $br_id
+	i32.const 1
+	call $br_if
+	i32.const 0
+	call $br_if
+	i32.const 0
+	call $br_table
+	i32.const 1
+	call $br_table
+	i32.const 2
+	call $br_table
+	i32.const 3
+	call $br_table
+	i32.const 7
+	call $br_table
+	i32.const -1
+	call $br_table
+	call $return_in_block
+	call $call_in_block
+	call $br_func
+  )
+  (func $br_depth (result i32)
+	(block $a (result i32)
+	  (block (result i32)
+		i32.const 1
+		br 1)
+	  drop
+	  i32.const 2)
+  )
+  (func $br_id (result i32)
+	(block $outer (result i32)
+	  (block $inner
+		i32.const 3
+		i32.const 7
+		br $outer)
+	  i32.const 9)
+  )
+  (func $br_if (param $c i32) (result i32)
+	(block (result i32)
+	  i32.const 10
+	  local.get $c
+	  br_if 0
+	  drop
+	  i32.const 20)
+  )
+  (func $br_table (param $n i32) (result i32)
+	(block $d
+	  (block $c
+		(block $b
+		  (block $a
+			local.get $n
+			br_table $a $b 2 $d)
+		  i32.const 100
+		  return)
+		i32.const 101
+		return)
+	  i32.const 102
+	  return)
+	i32.const 103
+  )
+  (func $return_in_block (result i32)
+	(block
+	  (block
+		i32.const 42
+		return))
+	i32.const 0
+  )
+  (func $call_in_block (result i32)
+	(block (result i32)
+	  i32.const 2
+	  call $double
+	  i32.const 1
+	  i32.add)
+  )
+  (func $double (param i32) (result i32)
+	local.get 0
+	i32.const 2
+	i32.mul
+  )
+  (func $br_func (result i32)
+	(block
+	  i32.const 8
+	  br 1)
+	i32.const 0
+  )
+  (export "main" (func $main))
+)
@@ -0,0 +1,98 @@
+(module
+  (func $main
+	(result i32) (result i32) (result i32) (result i32) (result i32)
+	(result i32) (result i32) (result i32) (result i32)
+
+	i32.const 5
+	call $factorial
+	i32.const 10
+	call $sum
+	i32.const -3
+	call $sign
+	i32.const 3
+	call $sign
+	i32.const 4
+	i32.const 9
+	call $max
+	i32.const -6
+	call $abs
+	i32.const 6
+	call $abs
+	i32.const 50
+	call $isqrt_above
+	call $if_param
+  )
+  (func $factorial (param $n i32) (result i32)
+	(local $acc i32)
+	i32.const 1
+	local.set $acc
+	(loop $continue
+	  (if (i32.gt_s (local.get $n) (i32.const 1))
+		(then
+		  (local.set $acc (i32.mul (local.get $acc) (local.get $n)))
+		  (local.set $n (i32.sub (local.get $n) (i32.const 1)))
+		  br $continue)))
+	local.get $acc
+  )
+  (func $sum (param $n i32) (result i32)
+	i32.const 0
+	loop $l (param i32) (result i32)
+	  local.get $n
+	  i32.add
+	  local.get $n
+	  i32.const 1
+	  i32.sub
+	  local.tee $n
+	  br_if $l
+	end
+  )
+  (func $sign (param i32) (result i32)
+	(if (result i32) (i32.lt_s (local.get 0) (i32.const 0))
+	  (then (i32.const -1))
+	  (else (i32.const 1)))
+  )
+  (func $max (param i32) (param i32) (result i32)
+	local.get 0
+	local.get 1
+	i32.gt_s
+	if $cmp (result i32)
+	  local.get 0
+	else $cmp
+	  local.get 1
+	end $cmp
+  )
+  (func $abs (param i32) (result i32)
+	local.get 0
+	i32.const 0
+	i32.lt_s
+	if
+	  i32.const 0
+	  local.get 0
+	  i32.sub
+	  local.set 0
+	end
+	local.get 0
+  )
+  (func $isqrt_above (param $limit i32) (result i32)
+	(local $i i32)
+	(block $done
+	  (loop $next
+		(local.set $i (i32.add (local.get $i) (i32.const 1)))
+		(if (i32.gt_s (i32.mul (local.get $i) (local.get $i)) (local.get $limit))
+		  (then (br $done)))
+		(br $next)))
+	local.get $i
+  )
+  (func $if_param (result i32)
+	i32.const 10
+	i32.const 1
+	(if (param i32) (result i32)
+	  (then
+		i32.const 1
+		i32.add)
+	  (else
+		i32.const 1
+		i32.sub))
+  )
+  (export "main" (func $main))
+)
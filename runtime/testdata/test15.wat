@@ -0,0 +1,29 @@
+(module
+  (memory 1 3)
+  (func $main
+	(result i32) (result i32) (result i32) (result i64) (result i64)
+	(result f64) (result f32) (result i32) (result i32) (result i32)
+	(result i32) (result i32) (result i64)
+
+	(i32.store (i32.const 0) (i32.const 0x12345678))
+	(i32.load8_u (i32.const 0))
+	(i32.load16_s (i32.const 2))
+	(i32.store8 (i32.const 10) (i32.const 0xff))
+	(i32.load8_s (i32.const 10))
+	(i64.store offset=16 (i32.const 0) (i64.const -2))
+	(i64.load32_u offset=16 (i32.const 0))
+	(i64.load32_s (i32.const 16))
+	(f64.store (i32.const 24) (f64.const 1.5))
+	(f64.load (i32.const 24))
+	(f32.store offset=32 align=1 (i32.const 0) (f32.const -0.25))
+	(f32.load offset=32 (i32.const 0))
+	memory.size
+	(memory.grow (i32.const 2))
+	memory.size
+	(memory.grow (i32.const 1))
+	(i32.store8 (i32.const 196607) (i32.const 7))
+	(i32.load8_u (i32.const 196607))
+	(i64.load16_u offset=1 (i32.const 0))
+  )
+  (export "main" (func $main))
+)
@@ -0,0 +1,19 @@
+(module
+  (memory 1)
+  (data (i32.const 4) "\01\02\03\04")
+  (data $hello "hello")
+  (data (offset (i32.const 100)) "\u{e9}")
+  (func $main
+	(result i32) (result i32) (result i32) (result i32) (result i32)
+
+	(i32.load (i32.const 4))
+	(memory.init $hello (i32.const 10) (i32.const 1) (i32.const 3))
+	(i32.load8_u (i32.const 10))
+	(i32.load16_u (i32.const 11))
+	(i32.load16_u (i32.const 100))
+	data.drop $hello
+	(memory.init $hello (i32.const 0) (i32.const 0) (i32.const 0))
+	(i32.load8_u (i32.const 13))
+  )
+  (export "main" (func $main))
+)
@@ -0,0 +1,21 @@
+(module
+  (memory 1)
+  (global $base i32 (i32.const 16))
+  (global $counter (mut i32) (global.get $base))
+  (global $scale (mut f64) (f64.const 1.5))
+  (data (global.get $base) "\2a")
+  (func $next (result i32)
+	(global.set $counter (i32.add (global.get $counter) (i32.const 1)))
+	global.get $counter
+  )
+  (func $main
+	(result i32) (result i32) (result i32) (result f64)
+
+	call $next
+	call $next
+	(i32.load8_u (global.get $base))
+	(global.set $scale (f64.mul (global.get $scale) (f64.const 2)))
+	global.get $scale
+  )
+  (export "main" (func $main))
+)
@@ -0,0 +1,31 @@
+(module
+  (table $t 4 funcref)
+  (table $u 2 funcref)
+  (func $double (param i32) (result i32)
+	(i32.mul (local.get 0) (i32.const 2))
+  )
+  (func $square (param i32) (result i32)
+	(i32.mul (local.get 0) (local.get 0))
+  )
+  (func $negate (param i32) (result i32)
+	(i32.sub (i32.const 0) (local.get 0))
+  )
+  (elem (table $t) (i32.const 0) func $double $square)
+  (elem $passive funcref (item ref.func $negate) (item ref.null func))
+  (func $main
+	(result i32) (result i32) (result i32) (result i32) (result i32) (result i32) (result i32)
+
+	(call_indirect $t (param i32) (result i32) (i32.const 5) (i32.const 0))
+	(call_indirect $t (param i32) (result i32) (i32.const 5) (i32.const 1))
+	(table.init $t $passive (i32.const 2) (i32.const 0) (i32.const 2))
+	elem.drop $passive
+	(call_indirect $t (param i32) (result i32) (i32.const 5) (i32.const 2))
+	(ref.is_null (table.get $t (i32.const 3)))
+	(table.copy $u $t (i32.const 0) (i32.const 1) (i32.const 2))
+	(call_indirect $u (param i32) (result i32) (i32.const 7) (i32.const 1))
+	(table.grow $t (ref.func $double) (i32.const 2))
+	(table.fill $t (i32.const 0) (ref.func $square) (i32.const 6))
+	(i32.add (table.size $t) (call_indirect $t (param i32) (result i32) (i32.const 3) (i32.const 5)))
+  )
+  (export "main" (func $main))
+)
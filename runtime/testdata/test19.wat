@@ -0,0 +1,19 @@
+(module
+  (type $binop (func (param i32 i32) (result i32)))
+  (table 2 funcref)
+  (func $add (type $binop)
+	(i32.add (local.get 0) (local.get 1))
+  )
+  (func $sub (type $binop) (param $a i32) (param $b i32) (result i32)
+	(i32.sub (local.get $a) (local.get $b))
+  )
+  (elem (i32.const 0) func $add $sub)
+  (func $main (result i32) (result i32)
+	(call_indirect (type $binop) (i32.const 7) (i32.const 3) (i32.const 0))
+	(call_indirect (type $binop) (i32.const 7) (i32.const 3) (i32.const 0))
+	i32.const 6
+	(block (type $binop)
+	  (call_indirect (type $binop) (i32.const 1)))
+  )
+  (export "main" (func $main))
+)
@@ -0,0 +1,30 @@
+(module
+  (func $loops (result i32) (local $i i32) (local $j i32)
+	(loop $l
+	  (local.set $i (i32.add (local.get $i) (i32.const 1)))
+	  (br_if $l (i32.lt_u (local.get $i) (i32.const 4))))
+	(loop $l
+	  (local.set $j (i32.add (local.get $j) (i32.const 7)))
+	  (br_if $l (i32.lt_u (local.get $j) (i32.const 49))))
+	(i32.add (local.get $i) (local.get $j))
+  )
+  (func $blocks (result i32)
+	(block $b (result i32)
+	  (i32.const 1))
+	(block $b (result i32)
+	  (i32.const 2))
+	i32.add
+  )
+  (func $anonymous (result i32)
+	(block (result i32)
+	  (block $#block0 (result i32)
+		(br $#block0 (i32.const 10)))
+	  (i32.add (i32.const 1)))
+  )
+  (func $main (result i32) (result i32) (result i32)
+	call $loops
+	call $blocks
+	call $anonymous
+  )
+  (export "main" (func $main))
+)
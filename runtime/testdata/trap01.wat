@@ -0,0 +1,9 @@
+(module
+  (func $main
+	(result i64)
+
+	i64.const 0x8000000000000000
+	i64.const -1
+	i64.div_s
+	)
+  (export "main" (func $main)))
@@ -0,0 +1,9 @@
+(module
+  (func $main
+	(result i64)
+
+	i64.const 1
+	i64.const 0
+	i64.rem_u
+	)
+  (export "main" (func $main)))
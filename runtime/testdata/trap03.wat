@@ -0,0 +1,8 @@
+(module
+  (func $main
+	(result i32)
+
+	f32.const nan
+	i32.trunc_f32_s
+	)
+  (export "main" (func $main)))
@@ -0,0 +1,8 @@
+(module
+  (func $main
+	(result i32)
+
+	f64.const 2147483648
+	i32.trunc_f64_s
+	)
+  (export "main" (func $main)))
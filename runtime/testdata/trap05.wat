@@ -0,0 +1,9 @@
+(module
+  (func $main
+	(result i32)
+
+	i32.const 0x80000000
+	i32.const -1
+	i32.div_s
+	)
+  (export "main" (func $main)))
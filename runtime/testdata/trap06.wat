@@ -0,0 +1,7 @@
+(module
+  (memory 1)
+  (func $main (result i32)
+	(i32.load offset=4 (i32.const 65532))
+  )
+  (export "main" (func $main))
+)
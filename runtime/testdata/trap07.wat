@@ -0,0 +1,9 @@
+(module
+  (memory 1)
+  (data $hello "hello")
+  (func $main
+	data.drop $hello
+	(memory.init $hello (i32.const 0) (i32.const 0) (i32.const 1))
+  )
+  (export "main" (func $main))
+)
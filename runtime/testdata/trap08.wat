@@ -0,0 +1,11 @@
+(module
+  (table 1 funcref)
+  (func $f (param i64) (result i64)
+	local.get 0
+  )
+  (elem (i32.const 0) func $f)
+  (func $main (result i32)
+	(call_indirect (param i32) (result i32) (i32.const 1) (i32.const 0))
+  )
+  (export "main" (func $main))
+)
@@ -0,0 +1,7 @@
+(module
+  (table 2 funcref)
+  (func $main
+	(call_indirect (i32.const 1))
+  )
+  (export "main" (func $main))
+)
@@ -0,0 +1,7 @@
+(module
+  (table 2 funcref)
+  (func $main
+	(table.fill 0 (i32.const 1) (ref.null func) (i32.const 2))
+  )
+  (export "main" (func $main))
+)
@@ -0,0 +1,225 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/kechako/wasmexec/mod/types"
+)
+
+// TrapKind is the kind of a trap.
+type TrapKind int
+
+const (
+	// TrapUnknown is the kind of the errors which the specification does
+	// not define as traps, such as an inconsistent stack.
+	TrapUnknown TrapKind = iota
+	TrapUnreachable
+	TrapIntegerDivideByZero
+	TrapIntegerOverflow
+	TrapInvalidConversion
+	TrapOutOfBoundsMemoryAccess
+	TrapOutOfBoundsTableAccess
+	TrapUndefinedElement
+	TrapUninitializedElement
+	TrapIndirectCallTypeMismatch
+	// TrapStackExhausted is the kind of the execution exceeding the call
+	// depth or the capacity of the stack.
+	TrapStackExhausted
+	TrapOutOfFuel
+	// TrapInterrupted is the kind of the execution canceled by the context.
+	TrapInterrupted
+	// TrapHost is the kind of the errors returned by host functions.
+	TrapHost
+)
+
+var trapKindNames = map[TrapKind]string{
+	TrapUnknown:                  "unknown",
+	TrapUnreachable:              "unreachable",
+	TrapIntegerDivideByZero:      "integer divide by zero",
+	TrapIntegerOverflow:          "integer overflow",
+	TrapInvalidConversion:        "invalid conversion to integer",
+	TrapOutOfBoundsMemoryAccess:  "out of bounds memory access",
+	TrapOutOfBoundsTableAccess:   "out of bounds table access",
+	TrapUndefinedElement:         "undefined element",
+	TrapUninitializedElement:     "uninitialized element",
+	TrapIndirectCallTypeMismatch: "indirect call type mismatch",
+	TrapStackExhausted:           "call stack exhausted",
+	TrapOutOfFuel:                "out of fuel",
+	TrapInterrupted:              "interrupted",
+	TrapHost:                     "host function error",
+}
+
+func (k TrapKind) String() string {
+	if s, ok := trapKindNames[k]; ok {
+		return s
+	}
+
+	return "TrapKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// trapErrors are the errors of the kinds, which are checked in order.
+var trapErrors = []struct {
+	err  error
+	kind TrapKind
+}{
+	{errUnreachable, TrapUnreachable},
+	{errIntegerDivideByZero, TrapIntegerDivideByZero},
+	{errIntegerOverflow, TrapIntegerOverflow},
+	{errInvalidConversion, TrapInvalidConversion},
+	{errOutOfBoundsMemoryAccess, TrapOutOfBoundsMemoryAccess},
+	{errOutOfBoundsTableAccess, TrapOutOfBoundsTableAccess},
+	{errUndefinedElement, TrapUndefinedElement},
+	{errUninitializedElement, TrapUninitializedElement},
+	{errIndirectCallTypeMismatch, TrapIndirectCallTypeMismatch},
+	{errCallStackExhausted, TrapStackExhausted},
+	{errStackOverflow, TrapStackExhausted},
+	{ErrOutOfFuel, TrapOutOfFuel},
+	{context.Canceled, TrapInterrupted},
+	{context.DeadlineExceeded, TrapInterrupted},
+}
+
+// trapKind returns the kind of err. The errors of host functions are of
+// TrapHost even if they wrap the other errors.
+func trapKind(err error) TrapKind {
+	var herr *hostError
+	if errors.As(err, &herr) {
+		return TrapHost
+	}
+
+	for _, e := range trapErrors {
+		if errors.Is(err, e.err) {
+			return e.kind
+		}
+	}
+
+	return TrapUnknown
+}
+
+// Frame is the position in a function being executed.
+type Frame struct {
+	// Function is the index of the function, or -1 for a constant
+	// expression.
+	Function int
+	// ID is the ID of the function, which can be empty.
+	ID types.ID
+	// Block is the number of the innermost block at the position, or -1
+	// for the body of the function.
+	Block int
+	// Label is the label of the block, which can be empty.
+	Label types.ID
+	// Instruction is the position of the instruction in the block.
+	Instruction int
+}
+
+// String returns the position like "func 1 $f (block $b, instruction 2)",
+// where an anonymous block is shown by its number.
+func (f Frame) String() string {
+	var b strings.Builder
+	if f.Function < 0 {
+		b.WriteString("constant expression")
+	} else {
+		b.WriteString("func " + strconv.Itoa(f.Function))
+	}
+	if !f.ID.IsEmpty() {
+		b.WriteString(" " + string(f.ID))
+	}
+	b.WriteString(" (")
+	if !f.Label.IsEmpty() {
+		b.WriteString("block " + string(f.Label) + ", ")
+	} else if f.Block >= 0 {
+		b.WriteString("block " + strconv.Itoa(f.Block) + ", ")
+	}
+	b.WriteString("instruction " + strconv.Itoa(f.Instruction) + ")")
+
+	return b.String()
+}
+
+// Trap is the error of an execution aborted by a trap.
+type Trap struct {
+	Kind TrapKind
+	// Backtrace is the frames of the functions being executed from the
+	// innermost one, whose first frame is the position of the trap.
+	Backtrace []Frame
+	// Err is the cause of the trap.
+	Err error
+}
+
+// Position returns the position of the trap, or false if the trap occurred
+// outside the functions.
+func (t *Trap) Position() (Frame, bool) {
+	if len(t.Backtrace) == 0 {
+		return Frame{}, false
+	}
+
+	return t.Backtrace[0], true
+}
+
+func (t *Trap) Error() string {
+	pos, ok := t.Position()
+	if !ok {
+		return t.Err.Error()
+	}
+
+	return t.Err.Error() + " at " + pos.String()
+}
+
+func (t *Trap) Unwrap() error {
+	return t.Err
+}
+
+// newTrap returns the trap of err, whose backtrace is reconstructed from the
+// frames on the stack. err is returned as is if it is already a trap.
+func (inst *Instance) newTrap(err error) error {
+	var trap *Trap
+	if errors.As(err, &trap) {
+		return err
+	}
+
+	return &Trap{
+		Kind:      trapKind(err),
+		Backtrace: inst.backtrace(),
+		Err:       err,
+	}
+}
+
+// backtrace returns the frames of the functions on the stack from the top.
+func (inst *Instance) backtrace() []Frame {
+	frames := make([]Frame, 0, len(inst.stack.frames))
+	for n := len(inst.stack.frames) - 1; n >= 0; n-- {
+		fr := &inst.stack.frames[n]
+		frame := Frame{
+			Function: fr.fn.index,
+			ID:       fr.fn.f.ID,
+			Block:    -1,
+		}
+		// the position is the instruction of the operation fetched last
+		if fr.pc > 0 {
+			pos := fr.fn.pos[fr.pc-1]
+			if block, ok := fr.fn.f.Block(pos.block); ok {
+				frame.Block = pos.block
+				frame.Label = block.Label
+			}
+			frame.Instruction = pos.instruction
+		}
+		frames = append(frames, frame)
+	}
+
+	return frames
+}
+
+// hostError is the error returned by a host function.
+type hostError struct {
+	name string
+	err  error
+}
+
+func (e *hostError) Error() string {
+	return e.name + ": " + e.err.Error()
+}
+
+func (e *hostError) Unwrap() error {
+	return e.err
+}
@@ -1,9 +1,38 @@
 package runtime
 
 import (
+	"math"
+
 	"github.com/kechako/wasmexec/mod/types"
 )
 
+// FuncRef is a reference to a function, whose zero value is the null
+// reference. The function runs with the memory, the tables and the globals
+// of the instance which made the reference, even if it is called through a
+// table or a global of another instance. It runs on the stack of the caller,
+// but the state of the instance is shared, so a function which changes the
+// state must not run concurrently with the instance.
+type FuncRef struct {
+	inst *Instance
+	fn   *function
+}
+
+// IsNull reports whether the reference is null.
+func (ref FuncRef) IsNull() bool {
+	return ref.fn == nil
+}
+
+// String returns the reference in the text format.
+func (ref FuncRef) String() string {
+	if ref.IsNull() {
+		return "ref.null func"
+	}
+	if ref.fn.f.ID.IsEmpty() {
+		return "ref.func"
+	}
+	return "ref.func " + string(ref.fn.f.ID)
+}
+
 type Value struct {
 	Value any
 }
@@ -24,14 +53,39 @@ func DefaultValue(typ types.Type) Value {
 		return NewValue(float32(0))
 	case types.F64:
 		return NewValue(float64(0))
+	case types.FuncRef:
+		return NewValue(FuncRef{})
 	}
 	panic("unsupported type")
 }
 
+// Type returns the type of the value, or types.Unkown if it is neither a
+// number nor a reference.
+func (value Value) Type() types.Type {
+	switch value.Value.(type) {
+	case int32:
+		return types.I32
+	case int64:
+		return types.I64
+	case float32:
+		return types.F32
+	case float64:
+		return types.F64
+	case FuncRef:
+		return types.FuncRef
+	}
+
+	return types.Unkown
+}
+
 func (value Value) Int32() (int32, bool) {
 	return GetValue[int32](value)
 }
 
+func (value Value) Int64() (int64, bool) {
+	return GetValue[int64](value)
+}
+
 func GetValue[T any](value Value) (v T, ok bool) {
 	v, ok = value.Value.(T)
 	if !ok {
@@ -40,3 +94,132 @@ func GetValue[T any](value Value) (v T, ok bool) {
 
 	return v, true
 }
+
+// toBits returns the bit pattern of a number on the stack.
+func toBits[T number](v T) uint64 {
+	switch v := any(v).(type) {
+	case int32:
+		return uint64(uint32(v))
+	case int64:
+		return uint64(v)
+	case float32:
+		return uint64(math.Float32bits(v))
+	case float64:
+		return math.Float64bits(v)
+	}
+
+	return 0
+}
+
+// fromBits returns the number of the bit pattern on the stack.
+func fromBits[T number](bits uint64) T {
+	var v T
+	switch p := any(&v).(type) {
+	case *int32:
+		*p = int32(uint32(bits))
+	case *int64:
+		*p = int64(bits)
+	case *float32:
+		*p = math.Float32frombits(uint32(bits))
+	case *float64:
+		*p = math.Float64frombits(bits)
+	}
+
+	return v
+}
+
+// refTable holds the references on a stack, which is shared by the
+// instances running on the stack.
+type refTable struct {
+	refs  []FuncRef
+	index map[FuncRef]uint64
+}
+
+func newRefTable() *refTable {
+	return &refTable{
+		index: make(map[FuncRef]uint64),
+	}
+}
+
+// refBits returns the bit pattern of a reference on the stack, which is the
+// position of the reference in inst.refs plus one, or zero for the null
+// reference.
+func (inst *Instance) refBits(ref FuncRef) uint64 {
+	if ref.IsNull() {
+		return 0
+	}
+	t := inst.refs
+	if bits, ok := t.index[ref]; ok {
+		return bits
+	}
+
+	t.refs = append(t.refs, ref)
+	bits := uint64(len(t.refs))
+	t.index[ref] = bits
+
+	return bits
+}
+
+// bitsRef returns the reference of the bit pattern on the stack.
+func (inst *Instance) bitsRef(bits uint64) FuncRef {
+	refs := inst.refs.refs
+	if bits == 0 || bits > uint64(len(refs)) {
+		return FuncRef{}
+	}
+
+	return refs[bits-1]
+}
+
+// numberBits returns the bit pattern of v, and reports false if v is not a
+// number.
+func numberBits(v any) (uint64, bool) {
+	switch v := v.(type) {
+	case int32:
+		return toBits(v), true
+	case int64:
+		return toBits(v), true
+	case float32:
+		return toBits(v), true
+	case float64:
+		return toBits(v), true
+	}
+
+	return 0, false
+}
+
+// bitsNumber returns the number of the type of the bit pattern, or nil if
+// the type is not a number type.
+func bitsNumber(bits uint64, typ types.Type) any {
+	switch typ {
+	case types.I32:
+		return fromBits[int32](bits)
+	case types.I64:
+		return fromBits[int64](bits)
+	case types.F32:
+		return fromBits[float32](bits)
+	case types.F64:
+		return fromBits[float64](bits)
+	}
+
+	return nil
+}
+
+// valueBits returns the bit pattern of a number or a FuncRef.
+func (inst *Instance) valueBits(v any) uint64 {
+	if ref, ok := v.(FuncRef); ok {
+		return inst.refBits(ref)
+	}
+	bits, _ := numberBits(v)
+
+	return bits
+}
+
+// bitsValue returns the number or the FuncRef of the type of the bit
+// pattern.
+func (inst *Instance) bitsValue(bits uint64, typ types.Type) any {
+	if typ == types.FuncRef {
+		return inst.bitsRef(bits)
+	}
+
+	return bitsNumber(bits, typ)
+}